@@ -10,17 +10,27 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/leshachaplin/datalog/app/waiter"
+	"github.com/leshachaplin/datalog/internal/auth"
 	"github.com/leshachaplin/datalog/internal/config"
+	"github.com/leshachaplin/datalog/internal/erasure"
+	"github.com/leshachaplin/datalog/internal/geoip"
+	"github.com/leshachaplin/datalog/internal/privacy"
+	"github.com/leshachaplin/datalog/internal/ratelimit"
+	"github.com/leshachaplin/datalog/internal/schema"
 	appServer "github.com/leshachaplin/datalog/internal/server/http"
 	"github.com/leshachaplin/datalog/internal/service"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
 	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+	"github.com/leshachaplin/datalog/internal/useragent"
 	"github.com/leshachaplin/datalog/internal/worker"
+	"github.com/leshachaplin/datalog/internal/worker/deadletter"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
 )
 
 const (
-	defaultAddr = ":8080"
+	defaultAddr            = ":8080"
+	defaultShutdownTimeout = time.Minute
 )
 
 type LoadConfigFn func() (config.Config, error)
@@ -32,6 +42,16 @@ type App struct {
 	waiter   waiter.Waiter
 	ctx      context.Context
 	cancelFn context.CancelFunc
+	// middlewares guard the public API.
+	middlewares []func(http.Handler) http.Handler
+
+	// Stopped by shutdown.
+	eventProcessor     *service.Service
+	eventProducer      *producer.Producer
+	deadLetterProducer *producer.Producer
+	eventWorker        worker.WorkerPool
+	eventConsumer      *consumer.Consumer
+	eventStorage       *clickhouse.Clickhouse
 }
 
 func New(loadConfigFn LoadConfigFn) *App {
@@ -63,7 +83,6 @@ func (a *App) Start() {
 	if err != nil {
 		a.logger.Fatal().Err(err).Msg("Could not setup event consumer.")
 	}
-	defer eventConsumer.Close()
 
 	eventProducer, err := producer.NewProducer(
 		a.ctx,
@@ -73,25 +92,98 @@ func (a *App) Start() {
 	if err != nil {
 		a.logger.Fatal().Err(err).Msg("Could not setup event producer.")
 	}
-	defer eventProducer.Close()
+
+	deadLetterProducer, err := producer.NewProducer(
+		a.ctx,
+		a.cfg.DeadLetterProducer,
+		a.logger.With().Str("dead letter producer", "Publish").Logger(),
+	)
+	if err != nil {
+		a.logger.Fatal().Err(err).Msg("Could not setup dead letter producer.")
+	}
 
 	eventQueue := worker.NewRedpandaQueue(eventProducer, eventConsumer)
+	deadLetterQueue := deadletter.NewQueue(deadLetterProducer)
 	l := a.logger.With().Str("WORKER", "EVENT").Logger()
-	eventWorker := worker.New(a.ctx, a.cfg.EventWorker, eventQueue, l)
+	eventWorker := worker.New(a.ctx, a.cfg.EventWorker, eventQueue, deadLetterQueue, l)
 
 	eventStorage, err := clickhouse.New(a.ctx, a.cfg.Clickhouse)
 	if err != nil {
 		a.logger.Fatal().Err(err).Msg("Could not setup event storage.")
 	}
-	defer eventStorage.Close()
 
-	eventProcessor := service.New(eventWorker, eventStorage)
-	handler := appServer.NewHandler(eventProcessor, a.logger)
+	if a.cfg.Clickhouse.MigrateOnStart {
+		if err = eventStorage.Migrate(a.ctx); err != nil {
+			a.logger.Fatal().Err(err).Msg("Could not migrate event storage.")
+		}
+	}
+
+	var serviceOptions []service.Option
+	if a.cfg.Schema.Path != "" {
+		schemas, err := schema.NewRegistry(a.cfg.Schema)
+		if err != nil {
+			a.logger.Fatal().Err(err).Msg("Could not load event schemas.")
+		}
+		a.waitForSchemas(schemas)
+		serviceOptions = append(serviceOptions, service.WithSchemas(schemas))
+	}
+	if a.cfg.GeoIP.Enabled() {
+		locator, err := geoip.New(a.cfg.GeoIP)
+		if err != nil {
+			a.logger.Fatal().Err(err).Msg("Could not load GeoIP databases.")
+		}
+		a.waiter.Add(func(ctx context.Context) error {
+			locator.Watch(ctx)
+			return nil
+		})
+		serviceOptions = append(serviceOptions, service.WithStages(pipeline.Enrich(locator)))
+	}
+	if a.cfg.Pipeline.Path != "" {
+		stages, err := pipeline.Load(a.cfg.Pipeline.Path)
+		if err != nil {
+			a.logger.Fatal().Err(err).Msg("Could not load the event pipeline.")
+		}
+		serviceOptions = append(serviceOptions, service.WithStages(stages...))
+	}
+	// deviceID turns the device IDs of erasure requests into their stored form.
+	var deviceID func(id string) string
+	if a.cfg.Privacy.Rules != "" {
+		rules, err := privacy.Load(a.cfg.Privacy.Rules)
+		if err != nil {
+			a.logger.Fatal().Err(err).Msg("Could not load privacy rules.")
+		}
+		deviceID = rules.DeviceID
+		serviceOptions = append(serviceOptions, service.WithPrivacy(rules))
+	}
+	userAgents, err := useragent.New(a.cfg.UserAgent)
+	if err != nil {
+		a.logger.Fatal().Err(err).Msg("Could not load bot rules.")
+	}
+	serviceOptions = append(serviceOptions, service.WithUserAgents(userAgents))
+
+	eraser := a.erasures(eventStorage, deviceID)
+	eventProcessor := service.New(a.cfg.Service, eventWorker, eraser.Guard(eventStorage), serviceOptions...)
+	handler := appServer.NewHandler(a.cfg.Server, eventProcessor, a.logger)
 
 	a.server = appServer.New(handler)
+	var limiter *ratelimit.Limiter
+	if a.cfg.RateLimit.Enabled() {
+		limiter = ratelimit.New(a.cfg.RateLimit)
+		a.middlewares = append(a.middlewares, handler.RateLimit(limiter))
+	}
+	a.authenticate(handler, eventStorage)
+	if limiter != nil && a.cfg.Auth.Enabled() && a.cfg.RateLimit.PerKey.Enabled() {
+		a.middlewares = append(a.middlewares, handler.RateLimitKey(limiter))
+	}
+	a.admin(handler, eraser)
+	a.eventProcessor = eventProcessor
+	a.eventProducer = eventProducer
+	a.deadLetterProducer = deadLetterProducer
+	a.eventWorker = eventWorker
+	a.eventConsumer = eventConsumer
+	a.eventStorage = eventStorage
 
 	a.waitForServer()
-	a.waitForWorker(eventWorker)
 
 	if err = a.waiter.Wait(); err != nil {
 		a.logger.Fatal().Err(err).Msg("App crash.")
@@ -110,7 +202,7 @@ func (a *App) waitForServer() {
 		group.Go(func() error {
 			defer a.logger.Debug().Msg("public server exited")
 			a.logger.Info().Str("starting server at: ", defaultAddr).Send()
-			err := a.server.ServePublic(defaultAddr)
+			err := a.server.ServePublic(defaultAddr, a.middlewares...)
 			if err != nil && err != http.ErrServerClosed {
 				return err
 			}
@@ -119,13 +211,7 @@ func (a *App) waitForServer() {
 
 		group.Go(func() error {
 			<-gCtx.Done()
-			log.Debug().Msg("shutting down the server")
-			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-			defer cancel()
-
-			if err := a.server.ShutdownPublic(ctx); err != nil {
-				a.logger.Warn().Err(err).Msg("error while shutting down the server")
-			}
+			a.shutdown()
 			return nil
 		})
 
@@ -133,14 +219,91 @@ func (a *App) waitForServer() {
 	})
 }
 
-func (a *App) waitForWorker(eventWorker worker.WorkerPool) {
+// shutdown stops taking requests, waits for the events taken so far to be queued and
+// then stops the rest of the pipeline, each part after the parts that feed it. The
+// server, the wait for the events and the producer flush share ShutdownTimeout; the
+// worker pool then stores whatever it has consumed.
+func (a *App) shutdown() {
+	timeout := a.cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Debug().Msg("shutting down the server")
+	if err := a.server.ShutdownPublic(ctx); err != nil {
+		a.logger.Warn().Err(err).Msg("error while shutting down the server")
+	}
+
+	if dropped := a.eventProcessor.Drain(ctx); dropped > 0 {
+		a.logger.Error().Int("events", dropped).Msg("Events were not queued before the shutdown timeout and are lost.")
+	}
+	if err := a.eventProducer.Flush(ctx); err != nil {
+		a.logger.Warn().Err(err).Msg("error while flushing the event producer")
+	}
+
+	a.eventWorker.GracefulStop()
+	if unacked := a.eventConsumer.Unacked(); unacked > 0 {
+		a.logger.Warn().Int("records", unacked).Msg("Consumed records were not stored and will be consumed again.")
+	}
+	_ = a.eventConsumer.Close()
+	_ = a.eventProducer.Close()
+	_ = a.deadLetterProducer.Close()
+	_ = a.eventStorage.Close()
+	a.logger.Debug().Msg("pipeline has been shutdown")
+}
+
+// authenticate requires API keys on the public API when they are configured.
+func (a *App) authenticate(handler *appServer.Handler, eventStorage *clickhouse.Clickhouse) {
+	if !a.cfg.Auth.Enabled() {
+		a.logger.Warn().Msg("No API keys are configured, anyone can ingest events.")
+		return
+	}
+
+	var source auth.Source = auth.NewFile(a.cfg.Auth.KeysFile)
+	if a.cfg.Auth.KeysTable != "" {
+		source = clickhouse.NewKeysTable(eventStorage, a.cfg.Auth.KeysTable)
+	}
+	keys, err := auth.NewKeys(a.ctx, source, a.cfg.Auth.ReloadInterval)
+	if err != nil {
+		a.logger.Fatal().Err(err).Msg("Could not load API keys.")
+	}
+
 	a.waiter.Add(func(ctx context.Context) error {
-		group, gCtx := errgroup.WithContext(ctx)
-		group.Go(func() error {
-			<-gCtx.Done()
-			eventWorker.GracefulStop()
-			return nil
-		})
-		return group.Wait()
+		keys.Watch(ctx)
+		return nil
+	})
+	a.middlewares = append(a.middlewares, handler.Authenticate(keys))
+}
+
+// erasures suppresses the queued events of erased devices, including those erased
+// by other instances and by the CLI, and tracks running erasures to completion.
+func (a *App) erasures(eventStorage *clickhouse.Clickhouse, deviceID func(id string) string) *erasure.Eraser {
+	eraser := erasure.New(a.cfg.Erasure, eventStorage, deviceID)
+	if _, err := eraser.Reload(a.ctx); err != nil {
+		a.logger.Error().Err(err).Msg("Could not load erasures, the queued events of erased devices are stored until they are.")
+	}
+	a.waiter.Add(func(ctx context.Context) error {
+		eraser.Watch(ctx)
+		return nil
+	})
+	return eraser
+}
+
+// admin serves the admin API when admin tokens are configured.
+func (a *App) admin(handler *appServer.Handler, eraser *erasure.Eraser) {
+	if len(a.cfg.Server.AdminTokens) == 0 {
+		a.logger.Info().Msg("No admin tokens are configured, the admin API is disabled.")
+		return
+	}
+	handler.SetEraser(eraser)
+	a.server.EnableAdmin(handler.AuthenticateAdmin(a.cfg.Server.AdminTokens))
+}
+
+func (a *App) waitForSchemas(schemas *schema.Registry) {
+	a.waiter.Add(func(ctx context.Context) error {
+		schemas.Watch(ctx)
+		return nil
 	})
 }
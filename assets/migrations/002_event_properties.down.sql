@@ -0,0 +1,7 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS properties_string,
+    DROP COLUMN IF EXISTS properties_int,
+    DROP COLUMN IF EXISTS properties_float,
+    DROP COLUMN IF EXISTS properties_bool,
+    DROP COLUMN IF EXISTS properties_timestamp,
+    DROP COLUMN IF EXISTS properties_array;
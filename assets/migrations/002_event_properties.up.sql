@@ -0,0 +1,7 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS properties_string    Map(String, String),
+    ADD COLUMN IF NOT EXISTS properties_int       Map(String, Int64),
+    ADD COLUMN IF NOT EXISTS properties_float     Map(String, Float64),
+    ADD COLUMN IF NOT EXISTS properties_bool      Map(String, Bool),
+    ADD COLUMN IF NOT EXISTS properties_timestamp Map(String, DateTime64(3, 'UTC')),
+    ADD COLUMN IF NOT EXISTS properties_array     Map(String, Array(String));
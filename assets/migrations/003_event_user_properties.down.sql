@@ -0,0 +1,3 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS user_properties_string,
+    DROP COLUMN IF EXISTS user_properties_float;
@@ -0,0 +1,3 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS user_properties_string Map(String, String),
+    ADD COLUMN IF NOT EXISTS user_properties_float  Map(String, Float64);
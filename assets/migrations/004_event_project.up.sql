@@ -0,0 +1,2 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS project_id LowCardinality(String) DEFAULT '';
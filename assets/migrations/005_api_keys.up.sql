@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    key        String,
+    project_id String,
+    revoked    Bool DEFAULT false,
+    updated_at DateTime DEFAULT now()
+) Engine = ReplacingMergeTree(updated_at)
+      ORDER BY key;
@@ -0,0 +1,13 @@
+-- IPv6 clients become 0.0.0.0.
+-- The backfill is a mutation, which must finish on every replica before the old
+-- column is dropped.
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS ip_v4 IPv4 DEFAULT toIPv4OrDefault(replaceOne(IPv6NumToString(ip), '::ffff:', '')) AFTER ip;
+ALTER TABLE events
+    MATERIALIZE COLUMN ip_v4 SETTINGS mutations_sync = 2;
+ALTER TABLE events
+    MODIFY COLUMN ip_v4 REMOVE DEFAULT;
+ALTER TABLE events
+    DROP COLUMN ip;
+ALTER TABLE events
+    RENAME COLUMN ip_v4 TO ip;
@@ -0,0 +1,14 @@
+-- Existing IPv4 addresses are backfilled as IPv4-mapped IPv6 (::ffff:a.b.c.d),
+-- which is also how new IPv4 clients are stored.
+-- The backfill is a mutation, which must finish on every replica before the old
+-- column is dropped.
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS ip_v6 IPv6 DEFAULT toIPv6(IPv4NumToString(ip)) AFTER ip;
+ALTER TABLE events
+    MATERIALIZE COLUMN ip_v6 SETTINGS mutations_sync = 2;
+ALTER TABLE events
+    MODIFY COLUMN ip_v6 REMOVE DEFAULT;
+ALTER TABLE events
+    DROP COLUMN ip;
+ALTER TABLE events
+    RENAME COLUMN ip_v6 TO ip;
@@ -0,0 +1,6 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS geo_country,
+    DROP COLUMN IF EXISTS geo_region,
+    DROP COLUMN IF EXISTS geo_city,
+    DROP COLUMN IF EXISTS geo_asn,
+    DROP COLUMN IF EXISTS geo_as_org;
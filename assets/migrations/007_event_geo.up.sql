@@ -0,0 +1,6 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS geo_country LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS geo_region LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS geo_city LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS geo_asn UInt32 DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS geo_as_org LowCardinality(String) DEFAULT '';
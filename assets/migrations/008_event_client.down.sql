@@ -0,0 +1,8 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS client_browser,
+    DROP COLUMN IF EXISTS client_browser_version,
+    DROP COLUMN IF EXISTS client_os,
+    DROP COLUMN IF EXISTS client_os_version,
+    DROP COLUMN IF EXISTS client_device,
+    DROP COLUMN IF EXISTS client_language,
+    DROP COLUMN IF EXISTS client_bot;
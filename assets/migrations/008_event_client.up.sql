@@ -0,0 +1,8 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS client_browser         LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_browser_version LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_os              LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_os_version      LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_device          LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_language        LowCardinality(String) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_bot             LowCardinality(String) DEFAULT '';
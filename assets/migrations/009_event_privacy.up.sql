@@ -0,0 +1,2 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS privacy_version LowCardinality(String) DEFAULT '';
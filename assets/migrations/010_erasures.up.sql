@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS erasures
+(
+    id           String,
+    device_id    String,
+    requested_by String,
+    reason       String,
+    tables       Array(String),
+    status       LowCardinality(String),
+    error        String,
+    requested_at DateTime64(3, 'UTC'),
+    updated_at   DateTime64(3, 'UTC')
+) Engine = ReplacingMergeTree(updated_at)
+      ORDER BY id;
@@ -0,0 +1,4 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS client_time_flag,
+    DROP COLUMN IF EXISTS clock_skew_ms,
+    MODIFY COLUMN client_time DATETIME;
@@ -0,0 +1,4 @@
+ALTER TABLE events
+    MODIFY COLUMN client_time DateTime64(3, 'UTC'),
+    ADD COLUMN IF NOT EXISTS clock_skew_ms    Int64 DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS client_time_flag LowCardinality(String) DEFAULT '';
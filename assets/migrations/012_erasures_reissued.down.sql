@@ -0,0 +1,2 @@
+ALTER TABLE erasures
+    DROP COLUMN IF EXISTS reissued;
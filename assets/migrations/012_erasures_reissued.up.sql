@@ -0,0 +1,2 @@
+ALTER TABLE erasures
+    ADD COLUMN IF NOT EXISTS reissued Bool DEFAULT false AFTER status;
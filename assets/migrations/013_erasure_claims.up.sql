@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS erasure_claims
+(
+    id         String,
+    claim      String,
+    claimed_at DateTime64(9, 'UTC') DEFAULT now64(9)
+) Engine = MergeTree
+      ORDER BY (id, claimed_at, claim);
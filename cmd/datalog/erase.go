@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/leshachaplin/datalog/app"
+	"github.com/leshachaplin/datalog/internal/config"
+	"github.com/leshachaplin/datalog/internal/erasure"
+	"github.com/leshachaplin/datalog/internal/privacy"
+	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+)
+
+// erase deletes the events of a device from ClickHouse and records the request in
+// the audit table, or reports an earlier erasure. Running servers suppress the
+// queued events of the device once they pick the erasure up.
+func erase(args []string) int {
+	fs := pflag.NewFlagSet("datalog erase", pflag.ContinueOnError)
+	deviceID := fs.String("device-id", "", "device whose events are erased")
+	hashed := fs.Bool("hashed", false, "the device ID is in its stored form, the privacy rules are not applied to it")
+	requestedBy := fs.String("requested-by", os.Getenv("USER"), "who asked for the erasure, recorded in the audit table")
+	reason := fs.String("reason", "", "why the events are erased, recorded in the audit table")
+	status := fs.String("status", "", "report the erasure with this ID instead of erasing")
+	wait := fs.Bool("wait", true, "wait until the deletes complete")
+
+	cfg, err := config.Parse(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	errs := []error{cfg.ValidateClickhouse(), cfg.ValidateErasure()}
+	if (*deviceID == "") == (*status == "") {
+		errs = append(errs, errors.New("exactly one of --device-id and --status is required"))
+	}
+	if err = errors.Join(errs...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	logger := app.NewZeroLogger(app.Level(cfg.LogLevel))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var storedID func(id string) string
+	if cfg.Privacy.Rules != "" && !*hashed {
+		rules, err := privacy.Load(cfg.Privacy.Rules)
+		if err != nil {
+			logger.Error().Err(err).Msg("Could not load privacy rules.")
+			return 1
+		}
+		storedID = rules.DeviceID
+	}
+
+	eventStorage, err := clickhouse.New(ctx, cfg.Clickhouse)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not setup event storage.")
+		return 1
+	}
+	defer eventStorage.Close()
+
+	eraser := erasure.New(cfg.Erasure, eventStorage, storedID)
+	var result erasure.Erasure
+	if *status != "" {
+		result, err = eraser.Erasure(ctx, *status)
+	} else {
+		result, err = eraser.Erase(ctx, erasure.Request{
+			DeviceID:    *deviceID,
+			Hashed:      *hashed,
+			RequestedBy: *requestedBy,
+			Reason:      *reason,
+		})
+		if err == nil && *wait {
+			result, err = eraser.Wait(ctx, result)
+		}
+	}
+	if result.ID != "" {
+		printErasure(os.Stdout, result)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Erasure failed.")
+		return 1
+	}
+	if result.Status == erasure.StatusFailed {
+		return 1
+	}
+	return 0
+}
+
+func printErasure(w io.Writer, e erasure.Erasure) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "id\t%s\n", e.ID)
+	fmt.Fprintf(tw, "device_id\t%s\n", e.DeviceID)
+	fmt.Fprintf(tw, "requested_by\t%s\n", e.RequestedBy)
+	fmt.Fprintf(tw, "requested_at\t%s\n", e.RequestedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "tables\t%s\n", strings.Join(e.Tables, ", "))
+	fmt.Fprintf(tw, "status\t%s\n", e.Status)
+	if e.Error != "" {
+		fmt.Fprintf(tw, "error\t%s\n", e.Error)
+	}
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"os"
+
 	"github.com/leshachaplin/datalog/app"
 	"github.com/leshachaplin/datalog/internal/config"
 )
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(migrate(args[1:]))
+		case "replay":
+			os.Exit(replay(args[1:]))
+		case "erase":
+			os.Exit(erase(args[1:]))
+		case "serve":
+			args = args[1:]
+		}
+	}
+
 	app.New(func() (config.Config, error) {
-		return config.Config{}, nil
+		return config.Load(args)
 	}).Start()
 }
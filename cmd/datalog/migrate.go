@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/leshachaplin/datalog/app"
+	"github.com/leshachaplin/datalog/assets"
+	"github.com/leshachaplin/datalog/internal/config"
+	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+)
+
+const migrateUsage = "usage: datalog migrate up|down|status|to VERSION|force VERSION [flags]"
+
+// migrate applies or rolls back the embedded ClickHouse migrations.
+func migrate(args []string) int {
+	fs := pflag.NewFlagSet("datalog migrate", pflag.ContinueOnError)
+	cfg, err := config.Parse(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	command, version, err := parseMigrateArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err = cfg.ValidateClickhouse(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	logger := app.NewZeroLogger(app.Level(cfg.LogLevel))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	eventStorage, err := clickhouse.New(ctx, cfg.Clickhouse)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not setup event storage.")
+		return 1
+	}
+	defer eventStorage.Close()
+
+	migrator, err := clickhouse.NewMigrator(eventStorage, assets.Migrations())
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not load migrations.")
+		return 1
+	}
+
+	switch command {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "to":
+		err = migrator.To(ctx, version)
+	case "force":
+		err = migrator.Force(ctx, version)
+	case "status":
+		var statuses []clickhouse.MigrationStatus
+		if statuses, err = migrator.Status(ctx); err == nil {
+			printStatus(os.Stdout, statuses)
+		}
+	}
+
+	if errors.Is(err, clickhouse.ErrNoChange) {
+		logger.Info().Msg("No migrations to apply.")
+		return 0
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("command", command).Msg("Migration failed.")
+		return 1
+	}
+	return 0
+}
+
+func parseMigrateArgs(args []string) (string, uint32, error) {
+	if len(args) == 0 {
+		return "", 0, errors.New("missing migrate command")
+	}
+
+	switch command := args[0]; command {
+	case "up", "down", "status":
+		if len(args) != 1 {
+			return "", 0, fmt.Errorf("%s takes no arguments", command)
+		}
+		return command, 0, nil
+	case "to", "force":
+		if len(args) != 2 {
+			return "", 0, fmt.Errorf("%s takes a version", command)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: invalid version %q", command, args[1])
+		}
+		return command, uint32(version), nil
+	default:
+		return "", 0, fmt.Errorf("unknown migrate command %q", command)
+	}
+}
+
+func printStatus(w io.Writer, statuses []clickhouse.MigrationStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tUPDATED AT")
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Dirty:
+			state = fmt.Sprintf("dirty, %d statements done", s.Done)
+		case s.Applied:
+			state = "applied"
+		}
+
+		updatedAt := "-"
+		if !s.UpdatedAt.IsZero() {
+			updatedAt = s.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, updatedAt)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/leshachaplin/datalog/app"
+	"github.com/leshachaplin/datalog/internal/config"
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/erasure"
+	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+	"github.com/leshachaplin/datalog/internal/worker/deadletter"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
+)
+
+const (
+	replayTargetQueue   = "queue"
+	replayTargetStorage = "storage"
+)
+
+// replay puts dead-lettered batches back into the pipeline: it reads the dead-letter
+// topic from the start up to its current end and re-publishes, or stores, every
+// batch matching the filters.
+func replay(args []string) int {
+	fs := pflag.NewFlagSet("datalog replay", pflag.ContinueOnError)
+	from := fs.String("from", "", "replay batches that failed at or after this RFC3339 time")
+	to := fs.String("to", "", "replay batches that failed before this RFC3339 time")
+	reason := fs.String("reason", "", "replay batches whose error reason contains this string")
+	batchIDs := fs.StringSlice("batch-id", nil, "replay only these batch IDs")
+	target := fs.String("target", replayTargetQueue, "where to replay batches: queue re-publishes to the event topic, storage writes to ClickHouse")
+	dryRun := fs.Bool("dry-run", false, "only report what would be replayed")
+
+	cfg, err := config.Parse(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	filter, err := parseFilter(*from, *to, *reason, *batchIDs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	errs := []error{cfg.ValidateDeadLetterConsumer()}
+	switch *target {
+	case replayTargetQueue:
+		errs = append(errs, cfg.ValidateEventProducer())
+	case replayTargetStorage:
+		errs = append(errs, cfg.ValidateClickhouse())
+	default:
+		errs = append(errs, fmt.Errorf("target: must be %q or %q, got %q", replayTargetQueue, replayTargetStorage, *target))
+	}
+	if err = errors.Join(errs...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	logger := app.NewZeroLogger(app.Level(cfg.LogLevel))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The whole topic is scanned on every run, so filters decide what is replayed
+	// and offsets are never committed.
+	cfg.DeadLetterConsumer.ConsumerGroup = ""
+	consumerErrorChan := make(chan error, 1)
+	go func() {
+		for err := range consumerErrorChan {
+			logger.Warn().Err(err).Msg("dead letter consumer")
+		}
+	}()
+	defer close(consumerErrorChan)
+
+	deadLetterConsumer, err := consumer.NewConsumer(cfg.DeadLetterConsumer, consumerErrorChan)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not setup dead letter consumer.")
+		return 1
+	}
+	defer deadLetterConsumer.Close()
+
+	var replayFn deadletter.ReplayFn
+	if !*dryRun {
+		switch *target {
+		case replayTargetQueue:
+			eventProducer, err := producer.NewProducer(ctx, cfg.EventProducer, logger.With().Str("event producer", "Publish").Logger())
+			if err != nil {
+				logger.Error().Err(err).Msg("Could not setup event producer.")
+				return 1
+			}
+			defer eventProducer.Close()
+			replayFn = func(ctx context.Context, eventBatch domain.EventBatch) error {
+				return eventProducer.Publish(ctx, eventBatch.ID, eventBatch)
+			}
+		case replayTargetStorage:
+			eventStorage, err := clickhouse.New(ctx, cfg.Clickhouse)
+			if err != nil {
+				logger.Error().Err(err).Msg("Could not setup event storage.")
+				return 1
+			}
+			defer eventStorage.Close()
+			// Batches are stored without the events of the devices erased since.
+			eraser := erasure.New(cfg.Erasure, eventStorage, nil)
+			if _, err = eraser.Reload(ctx); err != nil {
+				logger.Error().Err(err).Msg("Could not load erasures.")
+				return 1
+			}
+			replayFn = eraser.Guard(eventStorage).StoreEvents
+		}
+	}
+
+	report, err := deadletter.Replay(ctx, deadletter.NewReader(deadLetterConsumer), filter, *dryRun, replayFn)
+	printReport(os.Stdout, report)
+	if err != nil {
+		logger.Error().Err(err).Msg("Replay interrupted.")
+		return 1
+	}
+	if len(report.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseFilter(from, to, reason string, batchIDs []string) (deadletter.Filter, error) {
+	filter := deadletter.Filter{
+		Reason:   reason,
+		BatchIDs: batchIDs,
+	}
+
+	var err error
+	if from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return deadletter.Filter{}, fmt.Errorf("from: %w", err)
+		}
+	}
+	if to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return deadletter.Filter{}, fmt.Errorf("to: %w", err)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return deadletter.Filter{}, errors.New("from: must be before to")
+	}
+	return filter, nil
+}
+
+func printReport(w io.Writer, report deadletter.Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	if report.DryRun {
+		fmt.Fprintln(tw, "DRY RUN: nothing was replayed")
+	}
+	fmt.Fprintf(tw, "read\t%d\n", report.Read)
+	fmt.Fprintf(tw, "invalid\t%d\n", report.Invalid)
+	fmt.Fprintf(tw, "matched\t%d\n", report.Matched)
+	fmt.Fprintf(tw, "replayed\t%d\n", report.Replayed)
+	fmt.Fprintf(tw, "events\t%d\n", report.Events)
+
+	if report.DryRun && len(report.Batches) > 0 {
+		fmt.Fprintln(tw, "\nBATCH ID")
+		for _, id := range report.Batches {
+			fmt.Fprintln(tw, id)
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		fmt.Fprintln(tw, "\nPARTITION\tOFFSET\tBATCH ID\tERROR")
+		for _, f := range report.Failures {
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%v\n", f.Partition, f.Offset, f.BatchID, f.Err)
+		}
+	}
+}
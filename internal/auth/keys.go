@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+type Config struct {
+	// KeysFile is a YAML file of API keys, see File.
+	KeysFile string `mapstructure:"keys_file"`
+	// KeysTable is a ClickHouse table of API keys, shaped like api_keys in
+	// assets/migrations. At most one of KeysFile and KeysTable may be set; without
+	// either, anyone can ingest events.
+	KeysTable string `mapstructure:"keys_table"`
+	// ReloadInterval is how often the keys are read again.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// Enabled reports whether requests must carry an API key.
+func (c Config) Enabled() bool {
+	return c.KeysFile != "" || c.KeysTable != ""
+}
+
+// Source loads API keys, mapped to their project IDs.
+type Source interface {
+	Keys(ctx context.Context) (map[string]string, error)
+}
+
+// File reads keys from a YAML file:
+//
+//	keys:
+//	  - key: 3f1c...
+//	    project_id: shop
+type File struct {
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+type file struct {
+	Keys []struct {
+		Key       string `yaml:"key"`
+		ProjectID string `yaml:"project_id"`
+	} `yaml:"keys"`
+}
+
+func (f *File) Keys(context.Context) (map[string]string, error) {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("read keys %s: %w", f.path, err)
+	}
+
+	var parsed file
+	if err = yaml.Unmarshal(b, &parsed); err != nil {
+		return nil, fmt.Errorf("decode keys %s: %w", f.path, err)
+	}
+
+	keys := make(map[string]string, len(parsed.Keys))
+	for i, k := range parsed.Keys {
+		if k.Key == "" || k.ProjectID == "" {
+			return nil, fmt.Errorf("keys %s: key %d: key and project_id are required", f.path, i)
+		}
+		if _, ok := keys[k.Key]; ok {
+			return nil, fmt.Errorf("keys %s: key %d is declared twice", f.path, i)
+		}
+		keys[k.Key] = k.ProjectID
+	}
+	return keys, nil
+}
+
+// Keys maps API keys to project IDs. It is safe for concurrent use and can be
+// reloaded while requests are checked. Keys are held as SHA-256 digests, so that
+// lookups do not depend on how much of a guessed key is right.
+type Keys struct {
+	source         Source
+	reloadInterval time.Duration
+
+	mu       sync.RWMutex
+	projects map[[sha256.Size]byte]string
+}
+
+func NewKeys(ctx context.Context, source Source, reloadInterval time.Duration) (*Keys, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	k := &Keys{
+		source:         source,
+		reloadInterval: reloadInterval,
+	}
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+	// An empty source at startup is most likely a mistake. Later on, revoking every
+	// key is how ingestion is closed.
+	if k.len() == 0 {
+		return nil, errors.New("no API keys")
+	}
+	return k, nil
+}
+
+// Reload reads the keys again. The current keys are kept when the source fails.
+func (k *Keys) Reload(ctx context.Context) error {
+	keys, err := k.source.Keys(ctx)
+	if err != nil {
+		return err
+	}
+
+	projects := make(map[[sha256.Size]byte]string, len(keys))
+	for key, projectID := range keys {
+		projects[sha256.Sum256([]byte(key))] = projectID
+	}
+
+	k.mu.Lock()
+	k.projects = projects
+	k.mu.Unlock()
+	return nil
+}
+
+// Project returns the project ID of the key.
+func (k *Keys) Project(key string) (string, bool) {
+	digest := sha256.Sum256([]byte(key))
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	projectID, ok := k.projects[digest]
+	return projectID, ok
+}
+
+func (k *Keys) len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.projects)
+}
+
+// Watch reloads the keys every ReloadInterval until ctx is done, so that keys can
+// be issued and revoked without a restart.
+func (k *Keys) Watch(ctx context.Context) {
+	ticker := time.NewTicker(k.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to reload API keys, keeping the previous ones.")
+			}
+		}
+	}
+}
+
+type (
+	projectKey struct{}
+	apiKey     struct{}
+)
+
+// WithProject returns a context carrying the project ID of the request.
+func WithProject(ctx context.Context, projectID string) context.Context {
+	return context.WithValue(ctx, projectKey{}, projectID)
+}
+
+// Project returns the project ID of the request, empty without authentication.
+func Project(ctx context.Context) string {
+	projectID, _ := ctx.Value(projectKey{}).(string)
+	return projectID
+}
+
+// WithKey returns a context carrying the API key of the request.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKey{}, key)
+}
+
+// Key returns the API key of the request, empty without authentication.
+func Key(ctx context.Context) string {
+	key, _ := ctx.Value(apiKey{}).(string)
+	return key
+}
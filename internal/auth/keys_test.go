@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeys(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestKeys_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, `
+keys:
+  - key: shop-key
+    project_id: shop
+  - key: blog-key
+    project_id: blog
+`)
+
+	keys, err := NewKeys(context.Background(), NewFile(path), 0)
+	require.NoError(t, err)
+
+	projectID, ok := keys.Project("shop-key")
+	require.True(t, ok)
+	require.Equal(t, "shop", projectID)
+	_, ok = keys.Project("unknown")
+	require.False(t, ok)
+
+	// A revoked key stops working on reload, and a broken file keeps the current keys.
+	writeKeys(t, path, "keys:\n  - key: blog-key\n    project_id: blog\n")
+	require.NoError(t, keys.Reload(context.Background()))
+	_, ok = keys.Project("shop-key")
+	require.False(t, ok)
+
+	writeKeys(t, path, "keys:\n  - key: blog-key\n")
+	require.ErrorContains(t, keys.Reload(context.Background()), "project_id")
+	_, ok = keys.Project("blog-key")
+	require.True(t, ok)
+
+	// Revoking every key closes ingestion.
+	writeKeys(t, path, "keys: []\n")
+	require.NoError(t, keys.Reload(context.Background()))
+	_, ok = keys.Project("blog-key")
+	require.False(t, ok)
+}
+
+func TestKeys_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, "keys: []\n")
+
+	_, err := NewKeys(context.Background(), NewFile(path), 0)
+	require.EqualError(t, err, "no API keys")
+}
+
+func TestKeys_Duplicate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, `
+keys:
+  - {key: k, project_id: shop}
+  - {key: k, project_id: blog}
+`)
+
+	_, err := NewKeys(context.Background(), NewFile(path), 0)
+	require.ErrorContains(t, err, "declared twice")
+}
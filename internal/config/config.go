@@ -1,7 +1,19 @@
 package config
 
 import (
+	"time"
+
+	"github.com/leshachaplin/datalog/internal/auth"
+	"github.com/leshachaplin/datalog/internal/erasure"
+	"github.com/leshachaplin/datalog/internal/geoip"
+	"github.com/leshachaplin/datalog/internal/privacy"
+	"github.com/leshachaplin/datalog/internal/ratelimit"
+	"github.com/leshachaplin/datalog/internal/schema"
+	appServer "github.com/leshachaplin/datalog/internal/server/http"
+	"github.com/leshachaplin/datalog/internal/service"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
 	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+	"github.com/leshachaplin/datalog/internal/useragent"
 	"github.com/leshachaplin/datalog/internal/worker"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
@@ -9,9 +21,26 @@ import (
 
 // Config is the main config for the application
 type Config struct {
-	LogLevel      string            `mapstructure:"log_level"`
-	Clickhouse    clickhouse.Config `mapstructure:"clickhouse"`
-	EventWorker   worker.Config     `mapstructure:"auth_url"`
-	EventProducer producer.Config   `mapstructure:"event_producer"`
-	EventConsumer consumer.Config   `mapstructure:"event_consumer"`
+	LogLevel string `mapstructure:"log_level"`
+	// ShutdownTimeout bounds the graceful shutdown, from closing the server to
+	// waiting for the events taken before it to be queued.
+	ShutdownTimeout time.Duration     `mapstructure:"shutdown_timeout"`
+	Auth            auth.Config       `mapstructure:"auth"`
+	RateLimit       ratelimit.Config  `mapstructure:"rate_limit"`
+	Server          appServer.Config  `mapstructure:"server"`
+	Service         service.Config    `mapstructure:"service"`
+	Schema          schema.Config     `mapstructure:"schema"`
+	GeoIP           geoip.Config      `mapstructure:"geoip"`
+	UserAgent       useragent.Config  `mapstructure:"user_agent"`
+	Pipeline        pipeline.Config   `mapstructure:"pipeline"`
+	Privacy         privacy.Config    `mapstructure:"privacy"`
+	Erasure         erasure.Config    `mapstructure:"erasure"`
+	Clickhouse      clickhouse.Config `mapstructure:"clickhouse"`
+	EventWorker     worker.Config     `mapstructure:"event_worker"`
+	EventProducer   producer.Config   `mapstructure:"event_producer"`
+	EventConsumer   consumer.Config   `mapstructure:"event_consumer"`
+	// DeadLetterProducer publishes batches that could not be published or stored.
+	DeadLetterProducer producer.Config `mapstructure:"dead_letter_producer"`
+	// DeadLetterConsumer reads the dead-letter topic back for inspection and replay.
+	DeadLetterConsumer consumer.Config `mapstructure:"dead_letter_consumer"`
 }
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/auth"
+	"github.com/leshachaplin/datalog/internal/erasure"
+	"github.com/leshachaplin/datalog/internal/storage/event/clickhouse"
+	"github.com/leshachaplin/datalog/internal/worker"
+)
+
+const testConfigFile = `
+log_level: DEBUG
+clickhouse:
+  addr: clickhouse:9000
+  db: datalog
+event_worker:
+  num_workers: 10
+event_producer:
+  brokers: [redpanda:9092]
+  topic: event
+event_consumer:
+  brokers: [redpanda:9092]
+  consumer_group: event-cg
+  topics: [event]
+  poll_fetches_timeout: 5s
+dead_letter_producer:
+  brokers: [redpanda:9092]
+  topic: event-dlq
+`
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "datalog.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConfigFile), 0o600))
+
+	t.Setenv("DATALOG_CLICKHOUSE_DB", "from_env")
+	t.Setenv("DATALOG_LOG_LEVEL", "WARN")
+	t.Setenv("DATALOG_EVENT_PRODUCER_BROKERS", "a:9092,b:9092")
+
+	cfg, err := Load([]string{"--config", path, "--log_level", "ERROR"})
+	require.NoError(t, err)
+
+	require.Equal(t, "ERROR", cfg.LogLevel)
+	require.Equal(t, "from_env", cfg.Clickhouse.DB)
+	require.Equal(t, "clickhouse:9000", cfg.Clickhouse.Addr)
+	require.Equal(t, []string{"a:9092", "b:9092"}, cfg.EventProducer.Brokers)
+	require.Equal(t, []string{"event"}, cfg.EventConsumer.Topics)
+	require.Equal(t, 10, cfg.EventWorker.NumWorkers)
+	require.Equal(t, 5*time.Second, cfg.EventConsumer.PollFetchesTimeout)
+	require.Equal(t, 5, cfg.EventProducer.RetryAttempts)
+}
+
+func TestValidate(t *testing.T) {
+	err := Config{
+		Clickhouse:  clickhouse.Config{Addr: "clickhouse"},
+		EventWorker: worker.Config{NumWorkers: 0},
+		Auth:        auth.Config{KeysTable: "api_keys; DROP TABLE events"},
+		Erasure:     erasure.Config{Tables: []string{"events", "other_db.sessions"}},
+	}.Validate()
+	require.Error(t, err)
+
+	for _, key := range []string{
+		"clickhouse.addr",
+		"event_worker.num_workers",
+		"event_producer.brokers",
+		"event_producer.topic",
+		"event_consumer.brokers",
+		"event_consumer.consumer_group",
+		"event_consumer.topics",
+		"dead_letter_producer.brokers",
+		"dead_letter_producer.topic",
+		"auth.keys_table",
+		"erasure.tables[1]",
+	} {
+		require.Contains(t, err.Error(), key)
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	envPrefix     = "DATALOG"
+	configFileEnv = envPrefix + "_CONFIG"
+)
+
+// Load fills Config from a YAML/TOML file, environment variables and command-line
+// flags, in that order of precedence, and validates the result.
+//
+// The file is taken from the --config flag or the DATALOG_CONFIG variable.
+// Environment variables are named after the mapstructure keys, e.g.
+// DATALOG_EVENT_PRODUCER_BROKERS=host1:9092,host2:9092.
+func Load(args []string) (Config, error) {
+	cfg, err := Parse(pflag.NewFlagSet("datalog", pflag.ContinueOnError), args)
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// Parse is Load without validation. The config flags are added to fs, so commands
+// can declare their own flags next to them and validate only what they use.
+func Parse(fs *pflag.FlagSet, args []string) (Config, error) {
+	configFile := fs.StringP("config", "c", os.Getenv(configFileEnv), "path to a YAML or TOML config file")
+	keys := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	registerFlags(keys)
+	fs.AddFlagSet(keys)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, fmt.Errorf("parse flags: %w", err)
+	}
+
+	v := viper.New()
+	setDefaults(v)
+
+	if *configFile != "" {
+		v.SetConfigFile(*configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("read config file %q: %w", *configFile, err)
+		}
+	}
+
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	var bindErr error
+	keys.VisitAll(func(f *pflag.Flag) {
+		if bindErr != nil {
+			return
+		}
+		bindErr = v.BindPFlag(f.Name, f)
+	})
+	if bindErr != nil {
+		return Config{}, fmt.Errorf("bind flags: %w", bindErr)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return Config{}, fmt.Errorf("decode config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("log_level", "INFO")
+	v.SetDefault("shutdown_timeout", time.Minute)
+	v.SetDefault("event_producer.retry_attempts", 5)
+	v.SetDefault("event_producer.retry_delay", time.Second)
+	v.SetDefault("event_producer.encoding", "json")
+	v.SetDefault("dead_letter_producer.retry_attempts", 5)
+	v.SetDefault("dead_letter_producer.retry_delay", time.Second)
+}
+
+// registerFlags declares a flag for every config key. Flag names are the dotted
+// mapstructure keys, so --clickhouse.addr sets Config.Clickhouse.Addr.
+func registerFlags(fs *pflag.FlagSet) {
+	fs.String("log_level", "", "log level: TRACE, DEBUG, INFO, WARN, ERROR or PANIC")
+	fs.Duration("shutdown_timeout", 0, "longest graceful shutdown, including waiting for accepted events to be queued")
+
+	fs.String("auth.keys_file", "", "YAML file of API keys and their projects")
+	fs.String("auth.keys_table", "", "ClickHouse table of API keys and their projects")
+	fs.Duration("auth.reload_interval", 0, "how often API keys are read again")
+
+	for _, scope := range []string{"global", "per_key", "per_ip"} {
+		for _, resource := range []string{"requests", "events"} {
+			key := "rate_limit." + scope + "." + resource
+			fs.Float64(key+".per_second", 0, resource+" per second, 0 disables the limit")
+			fs.Int(key+".burst", 0, resource+" allowed at once, per_second by default")
+		}
+	}
+
+	fs.Bool("server.sync_ack", false, "reply to POST /v1/event only after the events are queued")
+	fs.Duration("server.sync_timeout", 0, "longest wait for the queue in the synchronous mode")
+	fs.Int64("server.max_body_size", 0, "largest event request body in bytes, after decompression")
+	fs.Duration("server.retry_after", 0, "Retry-After sent with 429 and 503 responses")
+	fs.StringSlice("server.trusted_proxies", nil, "addresses and CIDR prefixes of proxies whose forwarding headers are believed")
+	fs.StringSlice("server.admin_tokens", nil, "bearer tokens of the admin API, empty disables it")
+
+	fs.Int("service.validation.max_length", 0, "longest device_id, device_os, session and event")
+	fs.Int("service.validation.max_param_str_length", 0, "longest param_str")
+	fs.Int("service.validation.max_properties", 0, "most keys in properties and user_properties")
+	fs.StringSlice("service.validation.event_names", nil, "accepted event names, empty accepts any")
+	fs.Int("service.validation.param_int_min", 0, "smallest accepted param_int")
+	fs.Int("service.validation.param_int_max", 0, "largest accepted param_int")
+	fs.Duration("service.client_time.max_future", 0, "how far ahead of the server a client_time may be")
+	fs.Duration("service.client_time.max_past", 0, "how far behind the server a client_time may be")
+	fs.String("service.client_time.out_of_bounds", "", "what to do with client_time out of bounds: flag or clamp")
+	fs.Int("service.max_line_size", 0, "longest NDJSON line, or event in other formats, in bytes")
+	fs.Int("service.chunk_size", 0, "events queued together while a request body is read")
+	fs.Int64("service.max_in_flight_bytes", 0, "memory for decoded events waiting to be queued, across all requests")
+	fs.Int("service.ingest_workers", 0, "goroutines queueing events in the asynchronous mode")
+	fs.Int("service.ingest_queue_depth", 0, "event chunks waiting for an ingest worker before requests are turned away")
+
+	fs.String("schema.path", "", "event schema file or directory, empty disables the registry")
+	fs.String("schema.unknown_events", "", "what to do with events without a schema: allow, reject or quarantine")
+	fs.Duration("schema.reload_interval", 0, "how often schema files are checked for changes")
+
+	fs.String("geoip.city_db", "", "MaxMind DB file of countries, regions and cities")
+	fs.String("geoip.asn_db", "", "MaxMind DB file of autonomous systems")
+	fs.String("geoip.ip", "", "what to do with client IPs once located: keep, truncate or drop")
+	fs.Duration("geoip.reload_interval", 0, "how often GeoIP databases are checked for changes")
+
+	fs.String("user_agent.bot_rules", "", "YAML file of bot rules replacing the built-in ones")
+	fs.String("user_agent.bots", "", "what to do with events of bots: flag or drop")
+
+	fs.String("pipeline.path", "", "YAML file of stages transforming events before they are queued")
+
+	fs.String("privacy.rules", "", "YAML file of privacy rules applied to events before they are queued")
+
+	fs.StringSlice("erasure.tables", nil, "tables with a device_id column that erasures delete from, events by default")
+	fs.Bool("erasure.lightweight", false, "erase with lightweight DELETE FROM instead of ALTER TABLE DELETE mutations")
+	fs.Duration("erasure.poll_interval", 0, "how often running erasures are checked and new ones picked up")
+	fs.Duration("erasure.suppress_for", 0, "how long queued events of erased devices are dropped, at least the event topic retention")
+
+	fs.String("clickhouse.addr", "", "ClickHouse native protocol address, host:port")
+	fs.String("clickhouse.db", "", "ClickHouse database")
+	fs.String("clickhouse.username", "", "ClickHouse user")
+	fs.String("clickhouse.password", "", "ClickHouse password")
+	fs.Bool("clickhouse.migrate_on_start", false, "apply pending ClickHouse migrations on startup")
+
+	fs.Int("event_worker.num_workers", 0, "number of workers storing events")
+	fs.Int("event_worker.batch_size", 0, "number of events stored in one insert")
+	fs.Int("event_worker.batch_bytes", 0, "encoded size of events stored in one insert, 0 for no limit")
+	fs.Duration("event_worker.batch_linger", 0, "longest time an incomplete batch waits before it is stored")
+	fs.Int("event_worker.store_attempts", 0, "store attempts before a batch goes to the dead-letter topic")
+	fs.Duration("event_worker.store_retry_delay", 0, "base delay between store attempts")
+
+	fs.Int("event_producer.retry_attempts", 0, "publish attempts before giving up")
+	fs.Duration("event_producer.retry_delay", 0, "base delay between publish attempts")
+	fs.Duration("event_producer.sleep_duration", 0, "producer sleep duration")
+	fs.StringSlice("event_producer.brokers", nil, "Redpanda brokers for publishing events")
+	fs.String("event_producer.topic", "", "topic events are published to")
+	fs.String("event_producer.encoding", "", "event payload encoding: json or protobuf")
+
+	fs.StringSlice("event_consumer.brokers", nil, "Redpanda brokers for consuming events")
+	fs.String("event_consumer.consumer_group", "", "consumer group of the event workers")
+	fs.StringSlice("event_consumer.topics", nil, "topics events are consumed from")
+	fs.Int("event_consumer.retry_count", 0, "consumer retry count")
+	fs.Duration("event_consumer.poll_fetches_timeout", 0, "timeout of a single poll")
+
+	fs.Int("dead_letter_producer.retry_attempts", 0, "dead-letter publish attempts before giving up")
+	fs.Duration("dead_letter_producer.retry_delay", 0, "base delay between dead-letter publish attempts")
+	fs.Duration("dead_letter_producer.sleep_duration", 0, "dead-letter producer sleep duration")
+	fs.StringSlice("dead_letter_producer.brokers", nil, "Redpanda brokers for publishing failed batches")
+	fs.String("dead_letter_producer.topic", "", "topic failed batches are published to")
+
+	fs.StringSlice("dead_letter_consumer.brokers", nil, "Redpanda brokers for reading failed batches")
+	fs.String("dead_letter_consumer.consumer_group", "", "consumer group reading failed batches, empty to read from the start")
+	fs.StringSlice("dead_letter_consumer.topics", nil, "dead-letter topics to read")
+	fs.Int("dead_letter_consumer.retry_count", 0, "dead-letter consumer retry count")
+	fs.Duration("dead_letter_consumer.poll_fetches_timeout", 0, "timeout of a single dead-letter poll")
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/geoip"
+	"github.com/leshachaplin/datalog/internal/ratelimit"
+	appServer "github.com/leshachaplin/datalog/internal/server/http"
+	"github.com/leshachaplin/datalog/internal/useragent"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
+)
+
+// identifier is a ClickHouse table name, optionally qualified by its database.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Validate reports every problem with the config at once, so that a broken
+// deployment fails on startup instead of inside the first client that uses it.
+func (c Config) Validate() error {
+	var errs []error
+
+	errs = append(errs, c.ValidateClickhouse())
+
+	if c.Auth.KeysFile != "" && c.Auth.KeysTable != "" {
+		errs = append(errs, errors.New("auth: keys_file and keys_table are mutually exclusive"))
+	}
+	if c.Auth.KeysTable != "" && !identifier.MatchString(c.Auth.KeysTable) {
+		errs = append(errs, fmt.Errorf("auth.keys_table: must be a table name, got %q", c.Auth.KeysTable))
+	}
+
+	for i, token := range c.Server.AdminTokens {
+		if token == "" {
+			errs = append(errs, fmt.Errorf("server.admin_tokens[%d]: token is empty", i))
+		}
+	}
+	if _, err := appServer.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies: %w", err))
+	}
+
+	switch c.GeoIP.IP {
+	case "", geoip.IPKeep, geoip.IPTruncate, geoip.IPDrop:
+	default:
+		errs = append(errs, fmt.Errorf("geoip.ip: must be %s, %s or %s, got %q",
+			geoip.IPKeep, geoip.IPTruncate, geoip.IPDrop, c.GeoIP.IP))
+	}
+
+	switch c.Service.ClientTime.OutOfBounds {
+	case "", domain.TimeFlag, domain.TimeClamp:
+	default:
+		errs = append(errs, fmt.Errorf("service.client_time.out_of_bounds: must be %s or %s, got %q",
+			domain.TimeFlag, domain.TimeClamp, c.Service.ClientTime.OutOfBounds))
+	}
+
+	switch c.UserAgent.Bots {
+	case "", useragent.BotsFlag, useragent.BotsDrop:
+	default:
+		errs = append(errs, fmt.Errorf("user_agent.bots: must be %s or %s, got %q",
+			useragent.BotsFlag, useragent.BotsDrop, c.UserAgent.Bots))
+	}
+
+	errs = append(errs, c.ValidateErasure())
+
+	errs = append(errs, validateLimit("rate_limit.global", c.RateLimit.Global)...)
+	errs = append(errs, validateLimit("rate_limit.per_key", c.RateLimit.PerKey)...)
+	errs = append(errs, validateLimit("rate_limit.per_ip", c.RateLimit.PerIP)...)
+
+	if c.EventWorker.NumWorkers <= 0 {
+		errs = append(errs, fmt.Errorf("event_worker.num_workers: must be greater than 0, got %d", c.EventWorker.NumWorkers))
+	}
+
+	errs = append(errs, c.ValidateEventProducer())
+
+	errs = append(errs, validateBrokers("event_consumer.brokers", c.EventConsumer.Brokers)...)
+	if c.EventConsumer.ConsumerGroup == "" {
+		errs = append(errs, errors.New("event_consumer.consumer_group: is required"))
+	}
+	errs = append(errs, validateTopics("event_consumer.topics", c.EventConsumer.Topics)...)
+
+	errs = append(errs, validateBrokers("dead_letter_producer.brokers", c.DeadLetterProducer.Brokers)...)
+	if c.DeadLetterProducer.Topic == "" {
+		errs = append(errs, errors.New("dead_letter_producer.topic: is required"))
+	} else if c.DeadLetterProducer.Topic == c.EventProducer.Topic {
+		errs = append(errs, errors.New("dead_letter_producer.topic: must differ from event_producer.topic"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateClickhouse checks the settings needed to connect to ClickHouse.
+func (c Config) ValidateClickhouse() error {
+	if err := validateAddr(c.Clickhouse.Addr); err != nil {
+		return fmt.Errorf("clickhouse.addr: %w", err)
+	}
+	return nil
+}
+
+// ValidateErasure checks the tables erasures delete from. They belong to the
+// database of the connection, where their deletes are tracked.
+func (c Config) ValidateErasure() error {
+	var errs []error
+	for i, table := range c.Erasure.Tables {
+		if !identifier.MatchString(table) || strings.Contains(table, ".") {
+			errs = append(errs, fmt.Errorf("erasure.tables[%d]: must be a table name without a database, got %q", i, table))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateEventProducer checks the settings needed to publish to the event topic.
+func (c Config) ValidateEventProducer() error {
+	errs := validateBrokers("event_producer.brokers", c.EventProducer.Brokers)
+	if c.EventProducer.Topic == "" {
+		errs = append(errs, errors.New("event_producer.topic: is required"))
+	}
+	switch c.EventProducer.Encoding {
+	case "", producer.EncodingJSON, producer.EncodingProtobuf:
+	default:
+		errs = append(errs, fmt.Errorf("event_producer.encoding: must be %s or %s, got %q",
+			producer.EncodingJSON, producer.EncodingProtobuf, c.EventProducer.Encoding))
+	}
+	return errors.Join(errs...)
+}
+
+func validateLimit(key string, limit ratelimit.Limit) []error {
+	var errs []error
+	if limit.Requests.PerSecond < 0 || limit.Requests.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%s.requests: must not be negative", key))
+	}
+	if limit.Events.PerSecond < 0 || limit.Events.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%s.events: must not be negative", key))
+	}
+	return errs
+}
+
+func validateBrokers(key string, brokers []string) []error {
+	if len(brokers) == 0 {
+		return []error{fmt.Errorf("%s: at least one broker is required", key)}
+	}
+
+	var errs []error
+	for i, broker := range brokers {
+		if err := validateAddr(broker); err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d]: %w", key, i, err))
+		}
+	}
+	return errs
+}
+
+func validateTopics(key string, topics []string) []error {
+	if len(topics) == 0 {
+		return []error{fmt.Errorf("%s: at least one topic is required", key)}
+	}
+
+	var errs []error
+	for i, topic := range topics {
+		if topic == "" {
+			errs = append(errs, fmt.Errorf("%s[%d]: topic name is empty", key, i))
+		}
+	}
+	return errs
+}
+
+func validateAddr(addr string) error {
+	if addr == "" {
+		return errors.New("is required")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("address %q has no host", addr)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("address %q has invalid port", addr)
+	}
+	return nil
+}
+
+// ValidateDeadLetterConsumer checks the settings needed to read the dead-letter
+// topic back. They are optional for the server, so Validate does not check them.
+func (c Config) ValidateDeadLetterConsumer() error {
+	var errs []error
+
+	errs = append(errs, validateBrokers("dead_letter_consumer.brokers", c.DeadLetterConsumer.Brokers)...)
+	errs = append(errs, validateTopics("dead_letter_consumer.topics", c.DeadLetterConsumer.Topics)...)
+
+	return errors.Join(errs...)
+}
@@ -1,23 +1,99 @@
 package domain
 
-import "time"
+import (
+	"net/netip"
+	"time"
+)
 
 type Event struct {
-	ServerTime time.Time `json:"server_time"`
-	IP         string    `json:"ip"`
-	ClientTime string    `json:"client_time"`
-	DeviceID   string    `json:"device_id"`
-	DeviceOS   string    `json:"device_os"`
-	Session    string    `json:"session"`
-	Event      string    `json:"event"`
-	ParamStr   string    `json:"param_str"`
-	Sequence   int       `json:"sequence"`
-	ParamInt   int       `json:"param_int"`
+	// ProjectID is the project of the API key the event was sent with, empty when
+	// ingestion is not authenticated.
+	ProjectID  string     `json:"project_id,omitempty"`
+	ServerTime time.Time  `json:"server_time"`
+	IP         netip.Addr `json:"ip"`
+	// ClientTime is when the client recorded the event, see ParseClientTime.
+	ClientTime RawTime `json:"client_time"`
+	DeviceID   string  `json:"device_id"`
+	DeviceOS   string  `json:"device_os"`
+	Session    string  `json:"session"`
+	Event      string  `json:"event"`
+	ParamStr   string  `json:"param_str"`
+	Sequence   int     `json:"sequence"`
+	ParamInt   int     `json:"param_int"`
+	// Properties are the raw properties sent by the client. They are moved into
+	// TypedProperties on ingestion and are not queued.
+	Properties      map[string]any  `json:"properties,omitempty"`
+	TypedProperties TypedProperties `json:"typed_properties"`
+	// UserProperties describe the user rather than the event. They are never
+	// checked against a schema and are moved into FlatUserProperties on ingestion.
+	UserProperties     map[string]any `json:"user_properties,omitempty"`
+	FlatUserProperties FlatProperties `json:"flat_user_properties"`
+	// Geo is looked up from IP on ingestion when GeoIP databases are configured.
+	Geo Geo `json:"geo"`
+	// Client is parsed from the User-Agent and Accept-Language headers of the
+	// request on ingestion.
+	Client Client `json:"client"`
+	// PrivacyVersion is the version of the privacy rules applied on ingestion,
+	// empty when none are configured.
+	PrivacyVersion string `json:"privacy_version,omitempty"`
+	// ClientTimestamp is ClientTime in UTC with millisecond precision, clamped to the
+	// bounds of ClientTimeRules if they say so, or ServerTime when ClientTime is
+	// missing or invalid.
+	ClientTimestamp time.Time `json:"client_timestamp"`
+	// ClockSkew is how far ahead of ServerTime ClientTime is, before clamping.
+	ClockSkew time.Duration `json:"clock_skew"`
+	// ClientTimeFlag is future or past for client times out of bounds, and missing
+	// or invalid when ServerTime stands in for ClientTime.
+	ClientTimeFlag string `json:"client_time_flag,omitempty"`
 }
 
-func (e *Event) EnrichWith(clientIP string, serverTime time.Time) {
+// Geo locates the client address of an event. Fields the databases do not know are
+// left empty.
+type Geo struct {
+	// Country is the ISO 3166-1 alpha-2 code.
+	Country string `json:"country,omitempty"`
+	// Region is the ISO 3166-2 subdivision code without the country prefix.
+	Region string `json:"region,omitempty"`
+	City   string `json:"city,omitempty"`
+	// ASN is the autonomous system number of the network.
+	ASN   uint32 `json:"asn,omitempty"`
+	ASOrg string `json:"as_org,omitempty"`
+}
+
+// Client describes the software that sent an event. Fields that could not be told
+// from the headers are left empty.
+type Client struct {
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os,omitempty"`
+	OSVersion      string `json:"os_version,omitempty"`
+	// Device is desktop, mobile, tablet or bot.
+	Device string `json:"device,omitempty"`
+	// Language is the preferred BCP 47 language tag.
+	Language string `json:"language,omitempty"`
+	// Bot is the name of the bot rule the User-Agent matched, empty for humans.
+	Bot string `json:"bot,omitempty"`
+}
+
+// FlattenUserProperties moves the raw user properties into FlatUserProperties.
+func (e *Event) FlattenUserProperties() {
+	e.FlatUserProperties = Flatten(e.UserProperties)
+	e.UserProperties = nil
+}
+
+// EnrichWith sets the fields known to the server. The address keeps its family, so
+// IPv4 clients stay IPv4 until storage maps them into IPv6. The other fields set on
+// ingestion are reset, so that clients cannot set them; they are filled in later.
+func (e *Event) EnrichWith(projectID string, clientIP netip.Addr, serverTime time.Time) {
+	e.ProjectID = projectID
 	e.IP = clientIP
 	e.ServerTime = serverTime
+	e.Geo = Geo{}
+	e.Client = Client{}
+	e.PrivacyVersion = ""
+	e.ClientTimestamp = time.Time{}
+	e.ClockSkew = 0
+	e.ClientTimeFlag = ""
 }
 
 type EventBatch struct {
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// TypedProperties holds event properties split by type, so that they survive the
+// JSON round trip through the queue and map onto typed ClickHouse columns.
+//
+// Which column a property lands in depends on whether a schema declares it.
+// Undeclared properties only use String and Float, the properties_string and
+// properties_float columns: numbers keep their value and everything else becomes a
+// string. Declared properties use the column of their type, so an int property is
+// in properties_int once a schema declares it and in properties_float before.
+// Queries over properties that got declared later must read both columns.
+type TypedProperties struct {
+	String    map[string]string    `json:"string,omitempty"`
+	Int       map[string]int64     `json:"int,omitempty"`
+	Float     map[string]float64   `json:"float,omitempty"`
+	Bool      map[string]bool      `json:"bool,omitempty"`
+	Timestamp map[string]time.Time `json:"timestamp,omitempty"`
+	Array     map[string][]string  `json:"array,omitempty"`
+}
+
+func (p *TypedProperties) SetString(key, value string) {
+	if p.String == nil {
+		p.String = make(map[string]string)
+	}
+	p.String[key] = value
+}
+
+func (p *TypedProperties) SetInt(key string, value int64) {
+	if p.Int == nil {
+		p.Int = make(map[string]int64)
+	}
+	p.Int[key] = value
+}
+
+func (p *TypedProperties) SetFloat(key string, value float64) {
+	if p.Float == nil {
+		p.Float = make(map[string]float64)
+	}
+	p.Float[key] = value
+}
+
+func (p *TypedProperties) SetBool(key string, value bool) {
+	if p.Bool == nil {
+		p.Bool = make(map[string]bool)
+	}
+	p.Bool[key] = value
+}
+
+func (p *TypedProperties) SetTimestamp(key string, value time.Time) {
+	if p.Timestamp == nil {
+		p.Timestamp = make(map[string]time.Time)
+	}
+	p.Timestamp[key] = value
+}
+
+func (p *TypedProperties) SetArray(key string, value []string) {
+	if p.Array == nil {
+		p.Array = make(map[string][]string)
+	}
+	p.Array[key] = value
+}
+
+// FlatProperties holds a free-form property object as strings and numbers.
+type FlatProperties struct {
+	String map[string]string  `json:"string,omitempty"`
+	Float  map[string]float64 `json:"float,omitempty"`
+}
+
+// Flatten splits a decoded JSON object: numbers keep their value, everything else is
+// stored as a string, booleans as "true" and "false", arrays and objects as JSON.
+func Flatten(props map[string]any) FlatProperties {
+	var flat FlatProperties
+	for key, value := range props {
+		switch v := value.(type) {
+		case nil:
+		case float64:
+			flat.setFloat(key, v)
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				flat.setString(key, v.String())
+				continue
+			}
+			flat.setFloat(key, f)
+		default:
+			flat.setString(key, flatString(v))
+		}
+	}
+	return flat
+}
+
+func (p *FlatProperties) setFloat(key string, value float64) {
+	if p.Float == nil {
+		p.Float = make(map[string]float64)
+	}
+	p.Float[key] = value
+}
+
+func (p *FlatProperties) setString(key, value string) {
+	if p.String == nil {
+		p.String = make(map[string]string)
+	}
+	p.String[key] = value
+}
+
+func flatString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_FlattenUserProperties(t *testing.T) {
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"device_id": "device",
+		"user_properties": {
+			"plan": "pro",
+			"age": 31,
+			"verified": true,
+			"tags": ["a", "b"],
+			"address": {"city": "Minsk"},
+			"deleted": null
+		}
+	}`), &e))
+
+	e.FlattenUserProperties()
+	require.Nil(t, e.UserProperties)
+	require.Equal(t, FlatProperties{
+		String: map[string]string{
+			"plan":     "pro",
+			"verified": "true",
+			"tags":     `["a","b"]`,
+			"address":  `{"city":"Minsk"}`,
+		},
+		Float: map[string]float64{"age": 31},
+	}, e.FlatUserProperties)
+
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	var queued Event
+	require.NoError(t, json.Unmarshal(b, &queued))
+	require.Equal(t, e.FlatUserProperties, queued.FlatUserProperties)
+}
+
+func TestEvent_FlattenUserProperties_Empty(t *testing.T) {
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(`{"device_id": "device"}`), &e))
+
+	e.FlattenUserProperties()
+	require.Equal(t, FlatProperties{}, e.FlatUserProperties)
+}
@@ -0,0 +1,397 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/leshachaplin/datalog/internal/domain/eventpb"
+)
+
+//go:generate protoc -I ../../assets/proto --go_out=. --go_opt=module=github.com/leshachaplin/datalog/internal/domain event.proto
+
+// The protobuf encoding follows assets/proto/event.proto. The generated types in
+// eventpb only carry the wire format: they are mapped onto the domain types here, so
+// the domain types stay the only event types of the service.
+
+// ContentTypeProtobuf marks protobuf encoded request bodies and records.
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// maxProtoDepth bounds the nesting of decoded messages, which property values may
+// otherwise make as deep as the body allows.
+const maxProtoDepth = 100
+
+var (
+	marshalOptions   = proto.MarshalOptions{Deterministic: true}
+	unmarshalOptions = proto.UnmarshalOptions{RecursionLimit: maxProtoDepth}
+)
+
+// MarshalProto encodes the batch as a datalog.v1.EventBatch message.
+func (b EventBatch) MarshalProto() ([]byte, error) {
+	msg := &eventpb.EventBatch{
+		Id:     b.ID,
+		Events: make([]*eventpb.Event, len(b.Events)),
+	}
+	for i := range b.Events {
+		msg.Events[i] = b.Events[i].toProto()
+	}
+	return marshalOptions.Marshal(msg)
+}
+
+// UnmarshalProto decodes a datalog.v1.EventBatch message.
+func (b *EventBatch) UnmarshalProto(data []byte) error {
+	*b = EventBatch{Events: make([]Event, 0)}
+	return ReadProto(bytes.NewReader(data), func(id string) {
+		b.ID = id
+	}, func(i int, event *Event, _ int, err error) error {
+		if err != nil {
+			return fmt.Errorf("events[%d]: %w", i, err)
+		}
+		b.Events = append(b.Events, *event)
+		return nil
+	})
+}
+
+// ReadProto decodes a datalog.v1.EventBatch message from r one event at a time, so
+// that the whole batch is never in memory and a malformed event does not fail the
+// others. fn gets the 0-based index of the event, its encoded size and its decoding
+// error; reading stops when fn returns an error. The returned error is about the
+// batch framing or reading r.
+func ReadProto(r io.Reader, idFn func(id string), fn func(i int, event *Event, size int, err error) error) error {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		reader := bufio.NewReader(r)
+		r, br = reader, reader
+	}
+
+	for i := 0; ; {
+		tag, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		num, typ := protowire.DecodeTag(tag)
+		if num < protowire.MinValidNumber {
+			return errors.New("invalid field number")
+		}
+
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			_, err = binary.ReadUvarint(br)
+		case protowire.Fixed32Type:
+			_, err = io.CopyN(io.Discard, r, 4)
+		case protowire.Fixed64Type:
+			_, err = io.CopyN(io.Discard, r, 8)
+		case protowire.BytesType:
+			data, err = readBytes(r, br)
+		default:
+			err = fmt.Errorf("field %d: unsupported wire type %d", num, typ)
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		switch {
+		case num == 1 && typ == protowire.BytesType && idFn != nil:
+			idFn(string(data))
+		case num == 2:
+			event := &Event{}
+			if typ == protowire.BytesType {
+				err = event.UnmarshalProto(data)
+			} else {
+				err = fmt.Errorf("unexpected wire type %d", typ)
+			}
+			if err = fn(i, event, len(data), err); err != nil {
+				return err
+			}
+			i++
+		}
+	}
+}
+
+// readBytes reads a length-prefixed field. The buffer grows with the data read, so
+// a forged length does not allocate more than the body holds.
+func readBytes(r io.Reader, br io.ByteReader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 {
+		return nil, errors.New("field is too long")
+	}
+
+	var buf bytes.Buffer
+	if _, err = io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// UnmarshalProto decodes a datalog.v1.Event message. Property values decode to the
+// types encoding/json produces, so both formats go through the same validation.
+func (e *Event) UnmarshalProto(data []byte) error {
+	var msg eventpb.Event
+	if err := unmarshalOptions.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	return e.fromProto(&msg)
+}
+
+func (e *Event) toProto() *eventpb.Event {
+	msg := &eventpb.Event{
+		ClientTime:         string(e.ClientTime),
+		DeviceId:           e.DeviceID,
+		DeviceOs:           e.DeviceOS,
+		Session:            e.Session,
+		Event:              e.Event,
+		ParamStr:           e.ParamStr,
+		Sequence:           int32(e.Sequence),
+		ParamInt:           int64(e.ParamInt),
+		Properties:         valuesToProto(e.Properties),
+		UserProperties:     valuesToProto(e.UserProperties),
+		ServerTime:         timeToProto(e.ServerTime),
+		TypedProperties:    e.TypedProperties.toProto(),
+		FlatUserProperties: e.FlatUserProperties.toProto(),
+		ProjectId:          e.ProjectID,
+		PrivacyVersion:     e.PrivacyVersion,
+		ClientTimestamp:    timeToProto(e.ClientTimestamp),
+		ClockSkewMs:        e.ClockSkew.Milliseconds(),
+		ClientTimeFlag:     e.ClientTimeFlag,
+	}
+	if e.IP.IsValid() {
+		msg.Ip = e.IP.String()
+	}
+	if e.Geo != (Geo{}) {
+		msg.Geo = &eventpb.Geo{
+			Country: e.Geo.Country,
+			Region:  e.Geo.Region,
+			City:    e.Geo.City,
+			Asn:     e.Geo.ASN,
+			AsOrg:   e.Geo.ASOrg,
+		}
+	}
+	if e.Client != (Client{}) {
+		msg.Client = &eventpb.Client{
+			Browser:        e.Client.Browser,
+			BrowserVersion: e.Client.BrowserVersion,
+			Os:             e.Client.OS,
+			OsVersion:      e.Client.OSVersion,
+			Device:         e.Client.Device,
+			Language:       e.Client.Language,
+			Bot:            e.Client.Bot,
+		}
+	}
+	return msg
+}
+
+func (e *Event) fromProto(msg *eventpb.Event) error {
+	*e = Event{
+		ProjectID:       msg.GetProjectId(),
+		ServerTime:      timeFromProto(msg.GetServerTime()),
+		ClientTime:      RawTime(msg.GetClientTime()),
+		DeviceID:        msg.GetDeviceId(),
+		DeviceOS:        msg.GetDeviceOs(),
+		Session:         msg.GetSession(),
+		Event:           msg.GetEvent(),
+		ParamStr:        msg.GetParamStr(),
+		Sequence:        int(msg.GetSequence()),
+		ParamInt:        int(msg.GetParamInt()),
+		Properties:      valuesFromProto(msg.GetProperties()),
+		UserProperties:  valuesFromProto(msg.GetUserProperties()),
+		PrivacyVersion:  msg.GetPrivacyVersion(),
+		ClientTimestamp: timeFromProto(msg.GetClientTimestamp()),
+		ClockSkew:       time.Duration(msg.GetClockSkewMs()) * time.Millisecond,
+		ClientTimeFlag:  msg.GetClientTimeFlag(),
+	}
+	e.TypedProperties.fromProto(msg.GetTypedProperties())
+	e.FlatUserProperties.fromProto(msg.GetFlatUserProperties())
+
+	if ip := msg.GetIp(); ip != "" {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return fmt.Errorf("ip: %w", err)
+		}
+		e.IP = addr
+	}
+	if geo := msg.GetGeo(); geo != nil {
+		e.Geo = Geo{
+			Country: geo.GetCountry(),
+			Region:  geo.GetRegion(),
+			City:    geo.GetCity(),
+			ASN:     geo.GetAsn(),
+			ASOrg:   geo.GetAsOrg(),
+		}
+	}
+	if client := msg.GetClient(); client != nil {
+		e.Client = Client{
+			Browser:        client.GetBrowser(),
+			BrowserVersion: client.GetBrowserVersion(),
+			OS:             client.GetOs(),
+			OSVersion:      client.GetOsVersion(),
+			Device:         client.GetDevice(),
+			Language:       client.GetLanguage(),
+			Bot:            client.GetBot(),
+		}
+	}
+	return nil
+}
+
+func (p *TypedProperties) toProto() *eventpb.TypedProperties {
+	if len(p.String)+len(p.Int)+len(p.Float)+len(p.Bool)+len(p.Timestamp)+len(p.Array) == 0 {
+		return nil
+	}
+
+	msg := &eventpb.TypedProperties{
+		String_: p.String,
+		Int:     p.Int,
+		Float:   p.Float,
+		Bool:    p.Bool,
+	}
+	if len(p.Timestamp) > 0 {
+		msg.Timestamp = make(map[string]*timestamppb.Timestamp, len(p.Timestamp))
+		for key, t := range p.Timestamp {
+			msg.Timestamp[key] = timestamppb.New(t)
+		}
+	}
+	if len(p.Array) > 0 {
+		msg.Array = make(map[string]*eventpb.StringList, len(p.Array))
+		for key, values := range p.Array {
+			msg.Array[key] = &eventpb.StringList{Values: values}
+		}
+	}
+	return msg
+}
+
+func (p *TypedProperties) fromProto(msg *eventpb.TypedProperties) {
+	for key, v := range msg.GetString_() {
+		p.SetString(key, v)
+	}
+	for key, v := range msg.GetInt() {
+		p.SetInt(key, v)
+	}
+	for key, v := range msg.GetFloat() {
+		p.SetFloat(key, v)
+	}
+	for key, v := range msg.GetBool() {
+		p.SetBool(key, v)
+	}
+	for key, v := range msg.GetTimestamp() {
+		p.SetTimestamp(key, v.AsTime())
+	}
+	for key, v := range msg.GetArray() {
+		p.SetArray(key, append([]string{}, v.GetValues()...))
+	}
+}
+
+func (p *FlatProperties) toProto() *eventpb.FlatProperties {
+	if len(p.String)+len(p.Float) == 0 {
+		return nil
+	}
+	return &eventpb.FlatProperties{
+		String_: p.String,
+		Float:   p.Float,
+	}
+}
+
+func (p *FlatProperties) fromProto(msg *eventpb.FlatProperties) {
+	for key, v := range msg.GetString_() {
+		p.setString(key, v)
+	}
+	for key, v := range msg.GetFloat() {
+		p.setFloat(key, v)
+	}
+}
+
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// valuesToProto encodes decoded JSON values as google.protobuf.Value. Values
+// structpb does not know are encoded as strings.
+func valuesToProto(m map[string]any) map[string]*structpb.Value {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]*structpb.Value, len(m))
+	for key, value := range m {
+		out[key] = valueToProto(value)
+	}
+	return out
+}
+
+func valueToProto(value any) *structpb.Value {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return structpb.NewStringValue(v.String())
+		}
+		return structpb.NewNumberValue(f)
+	case map[string]any:
+		fields := make(map[string]*structpb.Value, len(v))
+		for key, item := range v {
+			fields[key] = valueToProto(item)
+		}
+		return structpb.NewStructValue(&structpb.Struct{Fields: fields})
+	case []any:
+		values := make([]*structpb.Value, len(v))
+		for i, item := range v {
+			values[i] = valueToProto(item)
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values})
+	}
+
+	pv, err := structpb.NewValue(value)
+	if err != nil {
+		return structpb.NewStringValue(fmt.Sprint(value))
+	}
+	return pv
+}
+
+// valuesFromProto decodes google.protobuf.Value into nil, float64, string, bool,
+// map[string]any or []any, as encoding/json does.
+func valuesFromProto(m map[string]*structpb.Value) map[string]any {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]any, len(m))
+	for key, value := range m {
+		out[key] = value.AsInterface()
+	}
+	return out
+}
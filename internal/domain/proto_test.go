@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/leshachaplin/datalog/internal/domain/eventpb"
+)
+
+func TestEventBatch_ProtoRoundTrip(t *testing.T) {
+	serverTime := time.Date(2023, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	batch := EventBatch{
+		ID: "batch",
+		Events: []Event{
+			{
+				ProjectID:  "shop",
+				ServerTime: serverTime,
+				IP:         netip.MustParseAddr("2001:db8::1"),
+				ClientTime: "2023-05-01 12:29:59",
+				DeviceID:   "device",
+				DeviceOS:   "ios",
+				Session:    "session",
+				Event:      "purchase",
+				ParamStr:   "param",
+				Sequence:   3,
+				ParamInt:   -42,
+				TypedProperties: TypedProperties{
+					String:    map[string]string{"sku": "a-1", "empty": ""},
+					Int:       map[string]int64{"qty": 2, "zero": 0},
+					Float:     map[string]float64{"price": 9.99},
+					Bool:      map[string]bool{"gift": true, "trial": false},
+					Timestamp: map[string]time.Time{"paid_at": serverTime},
+					Array:     map[string][]string{"tags": {"x", "y"}, "none": {}},
+				},
+				FlatUserProperties: FlatProperties{
+					String: map[string]string{"plan": "pro"},
+					Float:  map[string]float64{"age": 31},
+				},
+				Geo: Geo{Country: "DE", Region: "BE", City: "Berlin", ASN: 3320, ASOrg: "Deutsche Telekom AG"},
+				Client: Client{
+					Browser: "Chrome", BrowserVersion: "120.0", OS: "Windows", OSVersion: "10",
+					Device: "desktop", Language: "de-DE",
+				},
+				PrivacyVersion:  "2024-01",
+				ClientTimestamp: serverTime.Add(-time.Hour),
+				ClockSkew:       -time.Hour,
+				ClientTimeFlag:  ClientTimePast,
+			},
+			{DeviceID: "other", Event: "app_open"},
+		},
+	}
+
+	var decoded EventBatch
+	require.NoError(t, decoded.UnmarshalProto(marshalProto(t, batch)))
+	require.Equal(t, batch, decoded)
+}
+
+func TestEventBatch_MarshalProto_Generated(t *testing.T) {
+	serverTime := time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC)
+	batch := EventBatch{ID: "batch", Events: []Event{{
+		ServerTime:         serverTime,
+		IP:                 netip.MustParseAddr("10.0.0.1"),
+		ClientTime:         "1682944199000",
+		DeviceID:           "device",
+		Event:              "purchase",
+		Sequence:           3,
+		TypedProperties:    TypedProperties{Array: map[string][]string{"tags": {"x"}}},
+		FlatUserProperties: FlatProperties{Float: map[string]float64{"age": 31}},
+		Geo:                Geo{Country: "DE", ASN: 3320},
+		ClockSkew:          -1500 * time.Millisecond,
+	}}}
+
+	var msg eventpb.EventBatch
+	require.NoError(t, proto.Unmarshal(marshalProto(t, batch), &msg))
+	require.True(t, proto.Equal(&eventpb.EventBatch{Id: "batch", Events: []*eventpb.Event{{
+		ServerTime:         timestamppb.New(serverTime),
+		Ip:                 "10.0.0.1",
+		ClientTime:         "1682944199000",
+		DeviceId:           "device",
+		Event:              "purchase",
+		Sequence:           3,
+		TypedProperties:    &eventpb.TypedProperties{Array: map[string]*eventpb.StringList{"tags": {Values: []string{"x"}}}},
+		FlatUserProperties: &eventpb.FlatProperties{Float: map[string]float64{"age": 31}},
+		Geo:                &eventpb.Geo{Country: "DE", Asn: 3320},
+		ClockSkewMs:        -1500,
+	}}}, &msg), "got %v", &msg)
+
+	data, err := proto.Marshal(&msg)
+	require.NoError(t, err)
+	var decoded EventBatch
+	require.NoError(t, decoded.UnmarshalProto(data))
+	require.Equal(t, batch, decoded)
+}
+
+func marshalProto(t *testing.T, batch EventBatch) []byte {
+	t.Helper()
+	data, err := batch.MarshalProto()
+	require.NoError(t, err)
+	return data
+}
+
+func TestEvent_UnmarshalProto_Properties(t *testing.T) {
+	props, err := structpb.NewStruct(map[string]any{
+		"name":    "shoes",
+		"price":   19.5,
+		"gift":    false,
+		"tags":    []any{"a", 1.0},
+		"address": map[string]any{"city": "Minsk"},
+		"deleted": nil,
+	})
+	require.NoError(t, err)
+
+	data, err := proto.Marshal(&eventpb.Event{
+		DeviceId:   "device",
+		Properties: props.Fields,
+		ServerTime: timestamppb.New(time.Date(2023, 5, 1, 0, 0, 0, 5, time.UTC)),
+	})
+	require.NoError(t, err)
+
+	var event Event
+	require.NoError(t, event.UnmarshalProto(data))
+	require.Equal(t, "device", event.DeviceID)
+	require.Equal(t, time.Date(2023, 5, 1, 0, 0, 0, 5, time.UTC), event.ServerTime)
+
+	// The properties must decode exactly as their JSON encoding would.
+	b, err := props.MarshalJSON()
+	require.NoError(t, err)
+	var expected map[string]any
+	require.NoError(t, json.Unmarshal(b, &expected))
+	require.Equal(t, expected, event.Properties)
+}
+
+func TestEvent_UnmarshalProto_Invalid(t *testing.T) {
+	var event Event
+	// A truncated device_id, and one that is not UTF-8.
+	require.Error(t, event.UnmarshalProto([]byte{0x12, 0x05, 'a'}))
+	require.Error(t, event.UnmarshalProto([]byte{0x12, 0x01, 0xff}))
+	require.ErrorContains(t, event.UnmarshalProto([]byte{0x62, 0x03, 'a', 'b', 'c'}), "ip: ")
+
+	value := structpb.NewStringValue("deep")
+	for i := 0; i < maxProtoDepth; i++ {
+		value = structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{value}})
+	}
+	data, err := proto.Marshal(&eventpb.Event{Properties: map[string]*structpb.Value{"deep": value}})
+	require.NoError(t, err)
+	require.Error(t, event.UnmarshalProto(data))
+}
+
+func TestReadProto(t *testing.T) {
+	// The second event claims a 5 bytes device_id but carries none.
+	data := marshalProto(t, EventBatch{Events: []Event{{DeviceID: "a"}}})
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{0x12, 0x05})
+	data = append(data, marshalProto(t, EventBatch{Events: []Event{{DeviceID: "c"}}})...)
+
+	var (
+		ids    []string
+		failed []int
+	)
+	err := ReadProto(bytes.NewReader(data), nil, func(i int, event *Event, _ int, err error) error {
+		if err != nil {
+			failed = append(failed, i)
+			return nil
+		}
+		ids = append(ids, event.DeviceID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, ids)
+	require.Equal(t, []int{1}, failed)
+}
+
+func TestReadProto_Truncated(t *testing.T) {
+	data := marshalProto(t, EventBatch{Events: []Event{{DeviceID: "a"}, {DeviceID: "b"}}})
+
+	var ids []string
+	err := ReadProto(bytes.NewReader(data[:len(data)-1]), nil, func(i int, event *Event, _ int, err error) error {
+		require.NoError(t, err)
+		ids = append(ids, event.DeviceID)
+		return nil
+	})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, []string{"a"}, ids)
+}
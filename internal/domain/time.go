@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxFuture = 5 * time.Minute
+	defaultMaxPast   = 30 * 24 * time.Hour
+
+	// epochMillisThreshold tells epoch milliseconds from seconds: as seconds it is
+	// in the year 5138, as milliseconds in 1973.
+	epochMillisThreshold = 1e11
+)
+
+// The range of DateTime64 in ClickHouse.
+var (
+	minClientTime = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxClientTime = time.Date(2299, 12, 31, 23, 59, 59, 999e6, time.UTC)
+)
+
+// TimePolicy tells what happens to client times out of bounds.
+type TimePolicy string
+
+const (
+	// TimeFlag keeps the time and sets ClientTimeFlag.
+	TimeFlag TimePolicy = "flag"
+	// TimeClamp moves the time to the bound it crossed and sets ClientTimeFlag.
+	TimeClamp TimePolicy = "clamp"
+)
+
+// Client time flags.
+const (
+	ClientTimeFuture = "future"
+	ClientTimePast   = "past"
+	// ClientTimeMissing and ClientTimeInvalid client times were not sent or could
+	// not be parsed, and ServerTime stands in for them.
+	ClientTimeMissing = "missing"
+	ClientTimeInvalid = "invalid"
+)
+
+// ClientTimeRules bound client times against the server time. Zero values fall
+// back to defaults.
+type ClientTimeRules struct {
+	// MaxFuture is how far ahead of the server a client time may be. 5m by default.
+	MaxFuture time.Duration `mapstructure:"max_future"`
+	// MaxPast is how far behind the server a client time may be, which covers the
+	// events that SDKs keep while offline. 30 days by default.
+	MaxPast time.Duration `mapstructure:"max_past"`
+	// OutOfBounds is flag or clamp; flag by default.
+	OutOfBounds TimePolicy `mapstructure:"out_of_bounds"`
+}
+
+func (r ClientTimeRules) withDefaults() ClientTimeRules {
+	if r.MaxFuture <= 0 {
+		r.MaxFuture = defaultMaxFuture
+	}
+	if r.MaxPast <= 0 {
+		r.MaxPast = defaultMaxPast
+	}
+	if r.OutOfBounds == "" {
+		r.OutOfBounds = TimeFlag
+	}
+	return r
+}
+
+// RawTime is a time as sent by a client. In JSON it is a string or a number, which
+// is kept as written.
+type RawTime string
+
+func (t *RawTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*t = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*t = RawTime(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("client_time must be a string or a number")
+	}
+	*t = RawTime(n)
+	return nil
+}
+
+// ParseClientTime reads a time in RFC 3339, as "2006-01-02 15:04:05" in UTC, or as
+// Unix epoch seconds or milliseconds, and returns it in UTC with millisecond
+// precision.
+func ParseClientTime(raw string) (time.Time, error) {
+	var (
+		t   time.Time
+		err error
+	)
+	switch {
+	case raw == "":
+		return time.Time{}, errors.New("is empty")
+	case isEpoch(raw):
+		t, err = parseEpoch(raw)
+	case strings.Contains(raw, "T"):
+		t, err = time.Parse(time.RFC3339Nano, raw)
+	default:
+		t, err = time.Parse("2006-01-02 15:04:05.999999999", raw)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time, a \"2006-01-02 15:04:05\" time or epoch seconds or milliseconds", raw)
+	}
+
+	t = t.UTC().Truncate(time.Millisecond)
+	if t.Before(minClientTime) || t.After(maxClientTime) {
+		return time.Time{}, fmt.Errorf("must be between %d and %d, got %s", minClientTime.Year(), maxClientTime.Year(), t.Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+func isEpoch(raw string) bool {
+	dot := false
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '.' && !dot && i > 0:
+			dot = true
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return true
+}
+
+func parseEpoch(raw string) (time.Time, error) {
+	whole, frac, _ := strings.Cut(raw, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n >= epochMillisThreshold {
+		return time.UnixMilli(n), nil
+	}
+	ms, _ := strconv.Atoi((frac + "000")[:3])
+	return time.Unix(n, int64(ms)*int64(time.Millisecond)), nil
+}
+
+// NormalizeClientTime sets ClientTimestamp, ClockSkew and ClientTimeFlag from
+// ClientTime and ServerTime. Events without a ClientTime get ServerTime and are
+// flagged. So are those with an invalid one, which ingestion rejects, but events
+// queued before it did may still carry.
+func (e *Event) NormalizeClientTime(rules ClientTimeRules) {
+	rules = rules.withDefaults()
+
+	serverTime := e.ServerTime.UTC().Truncate(time.Millisecond)
+	t, err := ParseClientTime(string(e.ClientTime))
+	if err != nil {
+		e.ClientTimestamp = serverTime
+		e.ClockSkew = 0
+		e.ClientTimeFlag = ClientTimeInvalid
+		if e.ClientTime == "" {
+			e.ClientTimeFlag = ClientTimeMissing
+		}
+		return
+	}
+	e.ClientTimestamp = t
+	e.ClockSkew = t.Sub(serverTime)
+	e.ClientTimeFlag = ""
+
+	var bound time.Time
+	switch {
+	case e.ClockSkew > rules.MaxFuture:
+		e.ClientTimeFlag = ClientTimeFuture
+		bound = serverTime.Add(rules.MaxFuture)
+	case e.ClockSkew < -rules.MaxPast:
+		e.ClientTimeFlag = ClientTimePast
+		bound = serverTime.Add(-rules.MaxPast)
+	default:
+		return
+	}
+	if rules.OutOfBounds == TimeClamp {
+		e.ClientTimestamp = bound
+	}
+}
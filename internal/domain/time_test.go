@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClientTime(t *testing.T) {
+	want := time.Date(2023, 5, 31, 10, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2023-05-31T10:00:00Z":           want,
+		"2023-05-31T13:00:00+03:00":      want,
+		"2023-05-31T10:00:00.123456Z":    want.Add(123 * time.Millisecond),
+		"2023-05-31 10:00:00":            want,
+		"2023-05-31 10:00:00.5":          want.Add(500 * time.Millisecond),
+		"1685527200":                     want,
+		"1685527200.25":                  want.Add(250 * time.Millisecond),
+		"1685527200123":                  want.Add(123 * time.Millisecond),
+		"0":                              time.Unix(0, 0).UTC(),
+		"2299-12-31T23:59:59.999999999Z": time.Date(2299, 12, 31, 23, 59, 59, 999e6, time.UTC),
+	}
+	for raw, want := range tests {
+		t.Run(raw, func(t *testing.T) {
+			got, err := ParseClientTime(raw)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		})
+	}
+
+	for raw, want := range map[string]string{
+		"":                     "is empty",
+		"yesterday":            "is not an RFC 3339 time",
+		"-1685527200":          "is not an RFC 3339 time",
+		"1685527200.1.2":       "is not an RFC 3339 time",
+		"2023-05-31T10:00:00":  "is not an RFC 3339 time",
+		"1800-01-01T00:00:00Z": "must be between 1900 and 2299, got 1800-01-01T00:00:00Z",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			_, err := ParseClientTime(raw)
+			require.ErrorContains(t, err, want)
+		})
+	}
+}
+
+func TestRawTime_UnmarshalJSON(t *testing.T) {
+	var e Event
+	for body, want := range map[string]RawTime{
+		`{"client_time":"2023-05-31T10:00:00Z"}`: "2023-05-31T10:00:00Z",
+		`{"client_time":1685527200123}`:          "1685527200123",
+		`{"client_time":1685527200.25}`:          "1685527200.25",
+		`{"client_time":null}`:                   "",
+	} {
+		require.NoError(t, json.Unmarshal([]byte(body), &e))
+		require.Equal(t, want, e.ClientTime)
+	}
+	require.ErrorContains(t, json.Unmarshal([]byte(`{"client_time":true}`), &e), "client_time must be a string or a number")
+}
+
+func TestEvent_NormalizeClientTime(t *testing.T) {
+	serverTime := time.Date(2023, 5, 31, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	rules := ClientTimeRules{MaxFuture: time.Minute, MaxPast: 24 * time.Hour}
+
+	tests := []struct {
+		name       string
+		clientTime RawTime
+		policy     TimePolicy
+		timestamp  time.Time
+		skew       time.Duration
+		flag       string
+	}{
+		{
+			name:       "behind",
+			clientTime: "2023-05-31T06:59:58.5Z",
+			timestamp:  time.Date(2023, 5, 31, 6, 59, 58, 500e6, time.UTC),
+			skew:       -1500 * time.Millisecond,
+		},
+		{
+			name:       "future flagged",
+			clientTime: "2023-05-31T07:10:00Z",
+			timestamp:  time.Date(2023, 5, 31, 7, 10, 0, 0, time.UTC),
+			skew:       10 * time.Minute,
+			flag:       ClientTimeFuture,
+		},
+		{
+			name:       "future clamped",
+			clientTime: "2023-05-31T07:10:00Z",
+			policy:     TimeClamp,
+			timestamp:  time.Date(2023, 5, 31, 7, 1, 0, 0, time.UTC),
+			skew:       10 * time.Minute,
+			flag:       ClientTimeFuture,
+		},
+		{
+			name:       "past clamped",
+			clientTime: "1970-01-01T00:00:00Z",
+			policy:     TimeClamp,
+			timestamp:  time.Date(2023, 5, 30, 7, 0, 0, 0, time.UTC),
+			skew:       time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).Sub(serverTime),
+			flag:       ClientTimePast,
+		},
+		{
+			name:       "invalid",
+			clientTime: "yesterday",
+			timestamp:  time.Date(2023, 5, 31, 7, 0, 0, 0, time.UTC),
+			flag:       ClientTimeInvalid,
+		},
+		{
+			name:      "missing",
+			timestamp: time.Date(2023, 5, 31, 7, 0, 0, 0, time.UTC),
+			flag:      ClientTimeMissing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := rules
+			rules.OutOfBounds = tt.policy
+			e := Event{ClientTime: tt.clientTime, ServerTime: serverTime}
+			e.NormalizeClientTime(rules)
+			require.Equal(t, tt.timestamp, e.ClientTimestamp)
+			require.Equal(t, tt.skew, e.ClockSkew)
+			require.Equal(t, tt.flag, e.ClientTimeFlag)
+		})
+	}
+}
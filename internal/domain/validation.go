@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxLength         = 256
+	defaultMaxParamStrLength = 1024
+	defaultMaxProperties     = 100
+)
+
+type ValidationCode string
+
+const (
+	CodeInvalidJSON  ValidationCode = "invalid_json"
+	CodeInvalidProto ValidationCode = "invalid_protobuf"
+	CodeRequired     ValidationCode = "required"
+	CodeTooLong      ValidationCode = "too_long"
+	CodeOutOfRange   ValidationCode = "out_of_range"
+	CodeUnknownEvent ValidationCode = "unknown_event"
+	CodeInvalidType  ValidationCode = "invalid_type"
+	CodeUnknownField ValidationCode = "unknown_field"
+	CodeInvalidTime  ValidationCode = "invalid_time"
+)
+
+// FieldError describes why a field of an event was rejected.
+type FieldError struct {
+	Field   string         `json:"field"`
+	Code    ValidationCode `json:"code"`
+	Message string         `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationRules configures Event.Validate. Zero values fall back to defaults that
+// fit the ClickHouse columns.
+type ValidationRules struct {
+	// MaxLength limits device_id, device_os, session and event.
+	MaxLength int `mapstructure:"max_length"`
+	// MaxParamStrLength limits param_str.
+	MaxParamStrLength int `mapstructure:"max_param_str_length"`
+	// EventNames lists the accepted event names. Empty accepts any name.
+	EventNames []string `mapstructure:"event_names"`
+	// MaxProperties limits the number of keys in properties and user_properties.
+	MaxProperties int `mapstructure:"max_properties"`
+	// ParamIntMin and ParamIntMax bound param_int. Both zero means the Int32 range.
+	ParamIntMin int `mapstructure:"param_int_min"`
+	ParamIntMax int `mapstructure:"param_int_max"`
+}
+
+// Validate returns every problem of the event, or nil when it can be stored.
+func (e *Event) Validate(rules ValidationRules) []FieldError {
+	rules = rules.withDefaults()
+
+	var errs []FieldError
+	required := func(field, value string) {
+		if value == "" {
+			errs = append(errs, FieldError{Field: field, Code: CodeRequired, Message: "is required"})
+		}
+	}
+	maxLength := func(field, value string, max int) {
+		if n := utf8.RuneCountInString(value); n > max {
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeTooLong,
+				Message: fmt.Sprintf("is %d characters long, at most %d allowed", n, max),
+			})
+		}
+	}
+	inRange := func(field string, value, min, max int) {
+		if value < min || value > max {
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeOutOfRange,
+				Message: fmt.Sprintf("must be between %d and %d, got %d", min, max, value),
+			})
+		}
+	}
+
+	required("device_id", e.DeviceID)
+	required("event", e.Event)
+	required("client_time", string(e.ClientTime))
+	if _, err := ParseClientTime(string(e.ClientTime)); e.ClientTime != "" && err != nil {
+		errs = append(errs, FieldError{Field: "client_time", Code: CodeInvalidTime, Message: err.Error()})
+	}
+
+	maxLength("device_id", e.DeviceID, rules.MaxLength)
+	maxLength("device_os", e.DeviceOS, rules.MaxLength)
+	maxLength("session", e.Session, rules.MaxLength)
+	maxLength("event", e.Event, rules.MaxLength)
+	maxLength("param_str", e.ParamStr, rules.MaxParamStrLength)
+
+	maxProperties := func(field string, n int) {
+		if n > rules.MaxProperties {
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeTooLong,
+				Message: fmt.Sprintf("has %d keys, at most %d allowed", n, rules.MaxProperties),
+			})
+		}
+	}
+
+	maxProperties("properties", len(e.Properties))
+	maxProperties("user_properties", len(e.UserProperties))
+
+	inRange("sequence", e.Sequence, 0, math.MaxInt16)
+	inRange("param_int", e.ParamInt, rules.ParamIntMin, rules.ParamIntMax)
+
+	if e.Event != "" && len(rules.EventNames) > 0 && !contains(rules.EventNames, e.Event) {
+		errs = append(errs, FieldError{
+			Field:   "event",
+			Code:    CodeUnknownEvent,
+			Message: fmt.Sprintf("%q is not an allowed event name", e.Event),
+		})
+	}
+
+	return errs
+}
+
+func (r ValidationRules) withDefaults() ValidationRules {
+	if r.MaxLength <= 0 {
+		r.MaxLength = defaultMaxLength
+	}
+	if r.MaxParamStrLength <= 0 {
+		r.MaxParamStrLength = defaultMaxParamStrLength
+	}
+	if r.MaxProperties <= 0 {
+		r.MaxProperties = defaultMaxProperties
+	}
+	if r.ParamIntMin == 0 && r.ParamIntMax == 0 {
+		r.ParamIntMin, r.ParamIntMax = math.MinInt32, math.MaxInt32
+	}
+	return r
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_Validate(t *testing.T) {
+	valid := Event{
+		ClientTime: "2023-05-31 10:00:00",
+		DeviceID:   "device",
+		Event:      "app_open",
+	}
+
+	cases := map[string]struct {
+		rules    ValidationRules
+		modify   func(e *Event)
+		expected []FieldError
+	}{
+		"valid": {
+			modify: func(e *Event) {},
+		},
+		"missing required fields": {
+			modify: func(e *Event) { *e = Event{} },
+			expected: []FieldError{
+				{Field: "device_id", Code: CodeRequired, Message: "is required"},
+				{Field: "event", Code: CodeRequired, Message: "is required"},
+				{Field: "client_time", Code: CodeRequired, Message: "is required"},
+			},
+		},
+		"too long": {
+			rules:  ValidationRules{MaxLength: 4},
+			modify: func(e *Event) { e.DeviceID = "device" },
+			expected: []FieldError{
+				{Field: "device_id", Code: CodeTooLong, Message: "is 6 characters long, at most 4 allowed"},
+				{Field: "event", Code: CodeTooLong, Message: "is 8 characters long, at most 4 allowed"},
+			},
+		},
+		"param_str limit counts characters": {
+			rules:  ValidationRules{MaxParamStrLength: 3},
+			modify: func(e *Event) { e.ParamStr = strings.Repeat("ы", 3) },
+		},
+		"param_int out of range": {
+			rules:  ValidationRules{ParamIntMin: 0, ParamIntMax: 100},
+			modify: func(e *Event) { e.ParamInt = 101 },
+			expected: []FieldError{
+				{Field: "param_int", Code: CodeOutOfRange, Message: "must be between 0 and 100, got 101"},
+			},
+		},
+		"invalid client_time": {
+			modify: func(e *Event) { e.ClientTime = "31/05/2023" },
+			expected: []FieldError{{
+				Field:   "client_time",
+				Code:    CodeInvalidTime,
+				Message: `"31/05/2023" is not an RFC 3339 time, a "2006-01-02 15:04:05" time or epoch seconds or milliseconds`,
+			}},
+		},
+		"unknown event": {
+			rules:  ValidationRules{EventNames: []string{"app_close"}},
+			modify: func(e *Event) {},
+			expected: []FieldError{
+				{Field: "event", Code: CodeUnknownEvent, Message: `"app_open" is not an allowed event name`},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := valid
+			tc.modify(&e)
+			require.Equal(t, tc.expected, e.Validate(tc.rules))
+		})
+	}
+}
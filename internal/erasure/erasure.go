@@ -0,0 +1,391 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const (
+	defaultTable        = "events"
+	defaultPollInterval = 10 * time.Second
+	defaultSuppressFor  = 7 * 24 * time.Hour
+	// reissueMargin is added to PollInterval before the deletes are issued again. It
+	// covers the batches that passed the guard of another instance just before that
+	// instance picked the erasure up.
+	reissueMargin = 30 * time.Second
+)
+
+var (
+	// ErrInvalid is returned for requests that cannot be carried out.
+	ErrInvalid  = errors.New("invalid erasure request")
+	ErrNotFound = errors.New("erasure not found")
+)
+
+// Status tells how far an erasure got.
+type Status string
+
+const (
+	// StatusRunning erasures have their deletes issued, and not yet completed on
+	// every table.
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	// StatusFailed erasures have a delete that could not be issued or that failed;
+	// they are requested again once the cause is fixed.
+	StatusFailed Status = "failed"
+)
+
+type Config struct {
+	// Tables have a device_id column, and the rows of erased devices are deleted
+	// from them: events and the tables derived from it. events by default.
+	Tables []string `mapstructure:"tables"`
+	// Lightweight deletes with DELETE FROM instead of ALTER TABLE ... DELETE. Rows
+	// are hidden at once and removed from disk by later merges.
+	Lightweight bool `mapstructure:"lightweight"`
+	// PollInterval is how often running erasures are checked and the erasures of
+	// other instances are picked up. 10s by default. It should be the same on every
+	// instance, since the deletes are issued again once it has passed.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// SuppressFor is how long after an erasure the events of the device still in
+	// the queue are dropped. It should cover the retention of the event topic; 7
+	// days by default.
+	SuppressFor time.Duration `mapstructure:"suppress_for"`
+}
+
+// Request asks to erase the events of a device.
+type Request struct {
+	DeviceID string `json:"device_id"`
+	// Hashed tells that DeviceID is already in its stored form, so that the privacy
+	// rules are not applied to it.
+	Hashed      bool   `json:"hashed"`
+	RequestedBy string `json:"requested_by"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Erasure is the audit record of a request.
+type Erasure struct {
+	ID string `json:"id"`
+	// DeviceID is the device ID in its stored form.
+	DeviceID    string   `json:"device_id"`
+	RequestedBy string   `json:"requested_by"`
+	Reason      string   `json:"reason,omitempty"`
+	Tables      []string `json:"tables"`
+	Status      Status   `json:"status"`
+	// Reissued tells that the deletes were issued again once every instance had
+	// picked the erasure up, see Check.
+	Reissued bool `json:"reissued"`
+	// Error tells why the erasure failed.
+	Error       string    `json:"error,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Store deletes rows and keeps the audit records.
+type Store interface {
+	// DeleteDevice starts deleting the rows of the device from the table.
+	DeleteDevice(ctx context.Context, table, deviceID string, lightweight bool) error
+	// DeleteProgress reports whether deletes of the device from the table are still
+	// running, and why the latest of them failed.
+	DeleteProgress(ctx context.Context, table, deviceID string) (running bool, failure string, err error)
+	// SaveErasure records the erasure, replacing an earlier record of its ID.
+	SaveErasure(ctx context.Context, erasure Erasure) error
+	// Erasure returns ErrNotFound for unknown IDs.
+	Erasure(ctx context.Context, id string) (Erasure, error)
+	// Erasures returns the erasures requested since the time.
+	Erasures(ctx context.Context, since time.Time) ([]Erasure, error)
+	// ClaimReissue records the claim to issue the deletes of the erasure again, and
+	// reports whether it was the first claim of the erasure.
+	ClaimReissue(ctx context.Context, id, claim string) (bool, error)
+}
+
+// EventStorage stores batches of events.
+type EventStorage interface {
+	StoreEvents(ctx context.Context, batch domain.EventBatch) error
+}
+
+// Eraser erases the events of devices and suppresses those of their events that
+// are still queued. It is safe for concurrent use.
+type Eraser struct {
+	store        Store
+	deviceID     func(id string) string
+	tables       []string
+	lightweight  bool
+	pollInterval time.Duration
+	suppressFor  time.Duration
+
+	mu sync.RWMutex
+	// erased maps stored device IDs to the time of their latest erasure.
+	erased map[string]time.Time
+}
+
+// New returns an eraser. deviceID turns the device IDs sent by clients into their
+// stored form; nil keeps them as they are.
+func New(cfg Config, store Store, deviceID func(id string) string) *Eraser {
+	tables := cfg.Tables
+	if len(tables) == 0 {
+		tables = []string{defaultTable}
+	}
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	suppressFor := cfg.SuppressFor
+	if suppressFor <= 0 {
+		suppressFor = defaultSuppressFor
+	}
+
+	return &Eraser{
+		store:        store,
+		deviceID:     deviceID,
+		tables:       tables,
+		lightweight:  cfg.Lightweight,
+		pollInterval: pollInterval,
+		suppressFor:  suppressFor,
+		erased:       make(map[string]time.Time),
+	}
+}
+
+// Erase records the erasure and issues the deletes. Events of the device queued
+// before the request are suppressed from then on. The returned erasure is running
+// unless a delete could not be issued, in which case it is failed and the error is
+// returned with it.
+func (e *Eraser) Erase(ctx context.Context, req Request) (Erasure, error) {
+	if req.DeviceID == "" {
+		return Erasure{}, fmt.Errorf("%w: device_id is required", ErrInvalid)
+	}
+	if req.RequestedBy == "" {
+		return Erasure{}, fmt.Errorf("%w: requested_by is required", ErrInvalid)
+	}
+	deviceID := req.DeviceID
+	if !req.Hashed && e.deviceID != nil {
+		if deviceID = e.deviceID(deviceID); deviceID == "" {
+			return Erasure{}, fmt.Errorf("%w: device IDs are not stored", ErrInvalid)
+		}
+	}
+
+	now := time.Now().UTC()
+	erasure := Erasure{
+		ID:          uuid.NewString(),
+		DeviceID:    deviceID,
+		RequestedBy: req.RequestedBy,
+		Reason:      req.Reason,
+		Tables:      e.tables,
+		Status:      StatusRunning,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	e.suppress(deviceID, now)
+	if err := e.store.SaveErasure(ctx, erasure); err != nil {
+		return Erasure{}, fmt.Errorf("save erasure: %w", err)
+	}
+
+	for _, table := range e.tables {
+		if err := e.store.DeleteDevice(ctx, table, deviceID, e.lightweight); err != nil {
+			err = fmt.Errorf("delete from %s: %w", table, err)
+			return e.update(ctx, erasure, StatusFailed, err.Error()), err
+		}
+	}
+	return erasure, nil
+}
+
+// Erasure returns the erasure with the ID as it is recorded. Running erasures are
+// checked by Watch and Wait, not when they are looked up.
+func (e *Eraser) Erasure(ctx context.Context, id string) (Erasure, error) {
+	return e.store.Erasure(ctx, id)
+}
+
+// Check records a running erasure as done once its deletes completed on every
+// table, or as failed once one of them failed.
+//
+// Other instances suppress the device only from their next reload on, and until
+// then they may still store its queued events. So the deletes are issued again
+// PollInterval after the request, plus a margin, and the erasure is done once those
+// completed too. Every instance checks the erasure, so the reissue is claimed in
+// the store first, and only the first claim issues the deletes; the other checks
+// return the erasure as it is recorded.
+func (e *Eraser) Check(ctx context.Context, erasure Erasure) (Erasure, error) {
+	if erasure.Status != StatusRunning {
+		return erasure, nil
+	}
+
+	done := true
+	for _, table := range erasure.Tables {
+		running, failure, err := e.store.DeleteProgress(ctx, table, erasure.DeviceID)
+		if err != nil {
+			return erasure, fmt.Errorf("check deletes from %s: %w", table, err)
+		}
+		if failure != "" {
+			return e.update(ctx, erasure, StatusFailed, fmt.Sprintf("delete from %s: %s", table, failure)), nil
+		}
+		done = done && !running
+	}
+
+	if !erasure.Reissued {
+		if time.Since(erasure.RequestedAt) < e.pollInterval+reissueMargin {
+			return erasure, nil
+		}
+		claimed, err := e.store.ClaimReissue(ctx, erasure.ID, uuid.NewString())
+		if err != nil {
+			return erasure, fmt.Errorf("claim reissue: %w", err)
+		}
+		if !claimed {
+			// The record of the erasure is reissued once the first claim is done;
+			// Wait only sees that in the store.
+			return e.store.Erasure(ctx, erasure.ID)
+		}
+		for _, table := range erasure.Tables {
+			if err := e.store.DeleteDevice(ctx, table, erasure.DeviceID, e.lightweight); err != nil {
+				err = fmt.Errorf("delete from %s again: %w", table, err)
+				return e.update(ctx, erasure, StatusFailed, err.Error()), err
+			}
+		}
+		erasure.Reissued = true
+		return e.update(ctx, erasure, StatusRunning, ""), nil
+	}
+	if done {
+		return e.update(ctx, erasure, StatusDone, ""), nil
+	}
+	return erasure, nil
+}
+
+// Wait checks the erasure every PollInterval until it is no longer running.
+func (e *Eraser) Wait(ctx context.Context, erasure Erasure) (Erasure, error) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		if erasure, err = e.Check(ctx, erasure); err != nil || erasure.Status != StatusRunning {
+			return erasure, err
+		}
+		select {
+		case <-ctx.Done():
+			return erasure, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// update records the new status of the erasure. A failure to record it is logged,
+// since the erasure is checked again.
+func (e *Eraser) update(ctx context.Context, erasure Erasure, status Status, failure string) Erasure {
+	erasure.Status = status
+	erasure.Error = failure
+	erasure.UpdatedAt = time.Now().UTC()
+	if err := e.store.SaveErasure(ctx, erasure); err != nil {
+		log.Error().Err(err).Str("erasure", erasure.ID).Str("status", string(status)).Msg("Failed to record the status of an erasure.")
+	}
+	return erasure
+}
+
+// Reload reads the erasures of the last SuppressFor, including those of other
+// instances and of the CLI, and returns the running ones.
+func (e *Eraser) Reload(ctx context.Context) ([]Erasure, error) {
+	since := time.Now().Add(-e.suppressFor)
+	erasures, err := e.store.Erasures(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("load erasures: %w", err)
+	}
+
+	var running []Erasure
+	e.mu.Lock()
+	for deviceID, at := range e.erased {
+		if at.Before(since) {
+			delete(e.erased, deviceID)
+		}
+	}
+	for _, erasure := range erasures {
+		if at, ok := e.erased[erasure.DeviceID]; !ok || erasure.RequestedAt.After(at) {
+			e.erased[erasure.DeviceID] = erasure.RequestedAt
+		}
+		if erasure.Status == StatusRunning {
+			running = append(running, erasure)
+		}
+	}
+	e.mu.Unlock()
+	return running, nil
+}
+
+// Watch reloads the erasures and checks the running ones every PollInterval until
+// ctx is done.
+func (e *Eraser) Watch(ctx context.Context) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			running, err := e.Reload(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to reload erasures, keeping the previous ones.")
+				continue
+			}
+			for _, erasure := range running {
+				if _, err = e.Check(ctx, erasure); err != nil {
+					log.Error().Err(err).Str("erasure", erasure.ID).Msg("Failed to check an erasure.")
+				}
+			}
+		}
+	}
+}
+
+func (e *Eraser) suppress(deviceID string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if prev, ok := e.erased[deviceID]; !ok || at.After(prev) {
+		e.erased[deviceID] = at
+	}
+}
+
+// Suppressed reports whether the event was received before its device was erased.
+func (e *Eraser) Suppressed(event *domain.Event) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	at, ok := e.erased[event.DeviceID]
+	return ok && !event.ServerTime.After(at)
+}
+
+// Guard returns a storage that drops the suppressed events of batches before
+// passing them to next.
+func (e *Eraser) Guard(next EventStorage) EventStorage {
+	return &guard{eraser: e, next: next}
+}
+
+type guard struct {
+	eraser *Eraser
+	next   EventStorage
+}
+
+func (g *guard) StoreEvents(ctx context.Context, batch domain.EventBatch) error {
+	// The events are copied only once one of them is suppressed, since the batch
+	// belongs to the caller.
+	var events []domain.Event
+	for i := range batch.Events {
+		if g.eraser.Suppressed(&batch.Events[i]) {
+			if events == nil {
+				events = append(make([]domain.Event, 0, len(batch.Events)), batch.Events[:i]...)
+			}
+			continue
+		}
+		if events != nil {
+			events = append(events, batch.Events[i])
+		}
+	}
+	if events != nil {
+		log.Info().Str("batch", batch.ID).Int("events", len(batch.Events)-len(events)).Msg("Dropped the events of erased devices.")
+		if len(events) == 0 {
+			return nil
+		}
+		batch.Events = events
+	}
+	return g.next.StoreEvents(ctx, batch)
+}
@@ -0,0 +1,246 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+type memStore struct {
+	erasures  map[string]Erasure
+	deleted   []string
+	deleteErr error
+	// running and failures are the deletes in progress and their failures, by table.
+	running  map[string]bool
+	failures map[string]string
+	// claims maps erasure IDs to the first claim of their reissue.
+	claims map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		erasures: make(map[string]Erasure),
+		running:  make(map[string]bool),
+		failures: make(map[string]string),
+		claims:   make(map[string]string),
+	}
+}
+
+func (m *memStore) DeleteDevice(_ context.Context, table, deviceID string, _ bool) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	m.deleted = append(m.deleted, table+":"+deviceID)
+	m.running[table] = true
+	return nil
+}
+
+func (m *memStore) DeleteProgress(_ context.Context, table, _ string) (bool, string, error) {
+	return m.running[table], m.failures[table], nil
+}
+
+func (m *memStore) SaveErasure(_ context.Context, erasure Erasure) error {
+	m.erasures[erasure.ID] = erasure
+	return nil
+}
+
+func (m *memStore) Erasure(_ context.Context, id string) (Erasure, error) {
+	erasure, ok := m.erasures[id]
+	if !ok {
+		return Erasure{}, ErrNotFound
+	}
+	return erasure, nil
+}
+
+func (m *memStore) Erasures(_ context.Context, since time.Time) ([]Erasure, error) {
+	var erasures []Erasure
+	for _, erasure := range m.erasures {
+		if !erasure.RequestedAt.Before(since) {
+			erasures = append(erasures, erasure)
+		}
+	}
+	return erasures, nil
+}
+
+func (m *memStore) ClaimReissue(_ context.Context, id, claim string) (bool, error) {
+	if _, ok := m.claims[id]; !ok {
+		m.claims[id] = claim
+	}
+	return m.claims[id] == claim, nil
+}
+
+type storeFunc func(ctx context.Context, batch domain.EventBatch) error
+
+func (f storeFunc) StoreEvents(ctx context.Context, batch domain.EventBatch) error {
+	return f(ctx, batch)
+}
+
+func TestEraser_Erase(t *testing.T) {
+	store := newMemStore()
+	eraser := New(Config{Tables: []string{"events", "sessions"}}, store, strings.ToUpper)
+
+	erasure, err := eraser.Erase(context.Background(), Request{DeviceID: "d1", RequestedBy: "dpo", Reason: "ticket 42"})
+	require.NoError(t, err)
+	require.Equal(t, "D1", erasure.DeviceID)
+	require.Equal(t, StatusRunning, erasure.Status)
+	require.Equal(t, []string{"events:D1", "sessions:D1"}, store.deleted)
+	require.Equal(t, erasure, store.erasures[erasure.ID])
+
+	hashed, err := eraser.Erase(context.Background(), Request{DeviceID: "ab12", Hashed: true, RequestedBy: "dpo"})
+	require.NoError(t, err)
+	require.Equal(t, "ab12", hashed.DeviceID)
+
+	store.running["events"] = false
+	got, err := eraser.Check(context.Background(), erasure)
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, got.Status)
+
+	// The deletes completed, but other instances may not have picked the erasure up
+	// yet.
+	store.running["sessions"] = false
+	got, err = eraser.Check(context.Background(), erasure)
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, got.Status)
+	require.Len(t, store.deleted, 4)
+
+	// They have once PollInterval and the margin passed, and the deletes are issued
+	// again.
+	aged := store.erasures[erasure.ID]
+	aged.RequestedAt = aged.RequestedAt.Add(-defaultPollInterval - reissueMargin)
+	store.erasures[erasure.ID] = aged
+	got, err = eraser.Check(context.Background(), aged)
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, got.Status)
+	require.True(t, got.Reissued)
+	require.True(t, store.erasures[erasure.ID].Reissued)
+	require.Equal(t, []string{"events:D1", "sessions:D1", "events:ab12", "sessions:ab12", "events:D1", "sessions:D1"}, store.deleted)
+
+	store.running["events"] = false
+	store.running["sessions"] = false
+	got, err = eraser.Check(context.Background(), got)
+	require.NoError(t, err)
+	require.Equal(t, StatusDone, got.Status)
+	require.Len(t, store.deleted, 6)
+
+	got, err = eraser.Erasure(context.Background(), erasure.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusDone, got.Status)
+
+	_, err = eraser.Erasure(context.Background(), "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestEraser_Check_Reissue(t *testing.T) {
+	store := newMemStore()
+	eraser := New(Config{}, store, nil)
+	erasure, err := eraser.Erase(context.Background(), Request{DeviceID: "d1", RequestedBy: "dpo"})
+	require.NoError(t, err)
+	store.running["events"] = false
+
+	erasure.RequestedAt = erasure.RequestedAt.Add(-defaultPollInterval - reissueMargin)
+	store.erasures[erasure.ID] = erasure
+
+	// Looking the erasure up leaves it alone, even once its deletes are due again.
+	got, err := eraser.Erasure(context.Background(), erasure.ID)
+	require.NoError(t, err)
+	require.Equal(t, erasure, got)
+	require.Len(t, store.deleted, 1)
+
+	// Every instance checks its own copy of the running erasure, and only the first
+	// check claims the reissue and issues the deletes again, even on the same
+	// instance.
+	others := []*Eraser{New(Config{}, store, nil), New(Config{}, store, nil)}
+	got, err = others[0].Check(context.Background(), erasure)
+	require.NoError(t, err)
+	require.True(t, got.Reissued)
+	for _, e := range []*Eraser{eraser, others[1], others[0]} {
+		got, err = e.Check(context.Background(), erasure)
+		require.NoError(t, err)
+		require.True(t, got.Reissued, "the record of the first claim is returned")
+		require.Equal(t, StatusRunning, got.Status)
+	}
+	require.Equal(t, []string{"events:d1", "events:d1"}, store.deleted)
+	require.True(t, store.erasures[erasure.ID].Reissued)
+
+	// Once the record is reloaded, any instance completes the erasure.
+	store.running["events"] = false
+	got, err = eraser.Check(context.Background(), store.erasures[erasure.ID])
+	require.NoError(t, err)
+	require.Equal(t, StatusDone, got.Status)
+	require.Len(t, store.deleted, 2)
+}
+
+func TestEraser_Erase_Failures(t *testing.T) {
+	store := newMemStore()
+	eraser := New(Config{}, store, func(string) string { return "" })
+
+	_, err := eraser.Erase(context.Background(), Request{RequestedBy: "dpo"})
+	require.ErrorIs(t, err, ErrInvalid)
+	_, err = eraser.Erase(context.Background(), Request{DeviceID: "d1"})
+	require.ErrorIs(t, err, ErrInvalid)
+	_, err = eraser.Erase(context.Background(), Request{DeviceID: "d1", RequestedBy: "dpo"})
+	require.ErrorContains(t, err, "device IDs are not stored")
+
+	store.deleteErr = errors.New("table is read-only")
+	erasure, err := eraser.Erase(context.Background(), Request{DeviceID: "d1", Hashed: true, RequestedBy: "dpo"})
+	require.ErrorContains(t, err, "delete from events: table is read-only")
+	require.Equal(t, StatusFailed, erasure.Status)
+	require.Equal(t, erasure, store.erasures[erasure.ID])
+
+	store.deleteErr = nil
+	erasure, err = eraser.Erase(context.Background(), Request{DeviceID: "d2", Hashed: true, RequestedBy: "dpo"})
+	require.NoError(t, err)
+	erasure.RequestedAt = erasure.RequestedAt.Add(-defaultPollInterval - reissueMargin)
+	store.deleteErr = errors.New("too many mutations")
+	failed, err := eraser.Check(context.Background(), erasure)
+	require.ErrorContains(t, err, "delete from events again: too many mutations")
+	require.Equal(t, StatusFailed, failed.Status)
+
+	store.deleteErr = nil
+	store.failures["events"] = "Memory limit exceeded"
+	erasure, err = eraser.Check(context.Background(), erasure)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, erasure.Status)
+	require.Equal(t, "delete from events: Memory limit exceeded", erasure.Error)
+}
+
+func TestEraser_Guard(t *testing.T) {
+	store := newMemStore()
+	eraser := New(Config{}, store, nil)
+	erasure, err := eraser.Erase(context.Background(), Request{DeviceID: "d1", RequestedBy: "dpo"})
+	require.NoError(t, err)
+
+	var stored []domain.EventBatch
+	guarded := eraser.Guard(storeFunc(func(_ context.Context, batch domain.EventBatch) error {
+		stored = append(stored, batch)
+		return nil
+	}))
+
+	before := erasure.RequestedAt.Add(-time.Minute)
+	after := erasure.RequestedAt.Add(time.Minute)
+	batch := domain.EventBatch{ID: "b1", Events: []domain.Event{
+		{DeviceID: "d2", ServerTime: before},
+		{DeviceID: "d1", ServerTime: before},
+		{DeviceID: "d1", ServerTime: after},
+	}}
+	require.NoError(t, guarded.StoreEvents(context.Background(), batch))
+	require.NoError(t, guarded.StoreEvents(context.Background(), domain.EventBatch{ID: "b2", Events: batch.Events[1:2]}))
+
+	require.Len(t, stored, 1)
+	require.Equal(t, []domain.Event{batch.Events[0], batch.Events[2]}, stored[0].Events)
+	require.Equal(t, "d1", batch.Events[1].DeviceID, "the batch of the caller is left alone")
+
+	// Erasures made elsewhere are picked up on reload.
+	other := New(Config{}, store, nil)
+	running, err := other.Reload(context.Background())
+	require.NoError(t, err)
+	require.Len(t, running, 1)
+	require.True(t, other.Suppressed(&batch.Events[1]))
+	require.False(t, other.Suppressed(&batch.Events[2]))
+}
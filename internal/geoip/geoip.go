@@ -0,0 +1,242 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/rs/zerolog/log"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
+)
+
+const defaultReloadInterval = time.Minute
+
+// IPPolicy tells what happens to the client address once it is located.
+type IPPolicy string
+
+const (
+	IPKeep IPPolicy = "keep"
+	// IPTruncate zeroes the host part of the address, keeping a /24 of IPv4 and a
+	// /48 of IPv6.
+	IPTruncate IPPolicy = "truncate"
+	IPDrop     IPPolicy = "drop"
+)
+
+type Config struct {
+	// CityDB is a MaxMind DB file with country, subdivisions and city, such as
+	// GeoLite2-City.mmdb. A country database works too.
+	CityDB string `mapstructure:"city_db"`
+	// ASNDB is a MaxMind DB file with autonomous systems, such as GeoLite2-ASN.mmdb.
+	ASNDB string `mapstructure:"asn_db"`
+	// IP is keep, truncate or drop; keep by default.
+	IP IPPolicy `mapstructure:"ip"`
+	// ReloadInterval is how often the files are checked for changes, so that
+	// updated databases are picked up without a restart.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// Enabled reports whether events are located or their addresses rewritten.
+func (c Config) Enabled() bool {
+	return c.CityDB != "" || c.ASNDB != "" || (c.IP != "" && c.IP != IPKeep)
+}
+
+// Locator enriches events with the location of their client address. It is safe
+// for concurrent use and can be reloaded while events are enriched.
+type Locator struct {
+	city           *database
+	asn            *database
+	ip             IPPolicy
+	reloadInterval time.Duration
+}
+
+func New(cfg Config) (*Locator, error) {
+	ip := cfg.IP
+	switch ip {
+	case "":
+		ip = IPKeep
+	case IPKeep, IPTruncate, IPDrop:
+	default:
+		return nil, fmt.Errorf("ip: must be %s, %s or %s, got %q", IPKeep, IPTruncate, IPDrop, ip)
+	}
+
+	reloadInterval := cfg.ReloadInterval
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	l := &Locator{
+		ip:             ip,
+		reloadInterval: reloadInterval,
+	}
+	var err error
+	if l.city, err = openDatabase(cfg.CityDB); err != nil {
+		return nil, err
+	}
+	if l.asn, err = openDatabase(cfg.ASNDB); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Locate looks the address up in the databases.
+func (l *Locator) Locate(addr netip.Addr) domain.Geo {
+	var geo domain.Geo
+	if !addr.IsValid() {
+		return geo
+	}
+	for _, db := range []*database{l.city, l.asn} {
+		var r record
+		if err := db.lookup(addr, &r); err != nil {
+			log.Debug().Err(err).Str("path", db.path).Str("ip", addr.String()).Msg("Failed to look the IP up.")
+			continue
+		}
+		r.fill(&geo)
+	}
+	return geo
+}
+
+// Enrich locates the event and then applies the IP policy to its address.
+func (l *Locator) Enrich(event *domain.Event) {
+	event.Geo = l.Locate(event.IP)
+	switch l.ip {
+	case IPTruncate:
+		event.IP = pipeline.TruncateAddr(event.IP, 24, 48)
+	case IPDrop:
+		event.IP = netip.Addr{}
+	}
+}
+
+// Reload reads the databases again if their files changed. The current databases
+// are kept when a file is invalid.
+func (l *Locator) Reload() error {
+	var errs []error
+	for _, db := range []*database{l.city, l.asn} {
+		reloaded, err := db.reload()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if reloaded {
+			log.Info().Str("path", db.path).Msg("GeoIP database reloaded.")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch reloads the databases whenever their files change, until ctx is done.
+func (l *Locator) Watch(ctx context.Context) {
+	ticker := time.NewTicker(l.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				log.Error().Err(err).Msg("Failed to reload GeoIP databases, keeping the previous ones.")
+			}
+		}
+	}
+}
+
+// database is one MaxMind DB file. A database without a path finds nothing.
+type database struct {
+	path string
+	// modTime is only touched by Reload, which does not run concurrently.
+	modTime time.Time
+	reader  atomic.Pointer[maxminddb.Reader]
+}
+
+func openDatabase(path string) (*database, error) {
+	db := &database{path: path}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *database) reload() (bool, error) {
+	if db.path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return false, fmt.Errorf("stat GeoIP database %s: %w", db.path, err)
+	}
+	if info.ModTime().Equal(db.modTime) {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(db.path)
+	if err != nil {
+		return false, fmt.Errorf("read GeoIP database %s: %w", db.path, err)
+	}
+	r, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return false, fmt.Errorf("GeoIP database %s: %w", db.path, err)
+	}
+
+	db.reader.Store(r)
+	db.modTime = info.ModTime()
+	return true, nil
+}
+
+// lookup decodes the record of the network containing addr into r, which is left
+// empty when there is none.
+func (db *database) lookup(addr netip.Addr, r *record) error {
+	reader := db.reader.Load()
+	if reader == nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	if reader.Metadata.IPVersion == 4 && !addr.Is4() {
+		return nil
+	}
+	return reader.Lookup(addr.AsSlice(), r)
+}
+
+// record holds the fields of a GeoIP2 or GeoLite2 City, Country or ASN record that
+// events are enriched with.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names struct {
+			EN string `maxminddb:"en"`
+		} `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN   uint32 `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// fill sets the empty fields of geo from the record.
+func (r *record) fill(geo *domain.Geo) {
+	if geo.Country == "" {
+		geo.Country = r.Country.ISOCode
+	}
+	if geo.Region == "" && len(r.Subdivisions) > 0 {
+		geo.Region = r.Subdivisions[0].ISOCode
+	}
+	if geo.City == "" {
+		geo.City = r.City.Names.EN
+	}
+	if geo.ASN == 0 {
+		geo.ASN = r.ASN
+	}
+	if geo.ASOrg == "" {
+		geo.ASOrg = r.ASOrg
+	}
+}
@@ -0,0 +1,286 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+var berlin = map[string]any{
+	"country":      map[string]any{"iso_code": "DE"},
+	"subdivisions": []any{map[string]any{"iso_code": "BE", "names": map[string]any{"en": "Land Berlin"}}},
+	"city":         map[string]any{"names": map[string]any{"en": "Berlin", "de": "Berlin"}},
+}
+
+func TestLocator_Enrich(t *testing.T) {
+	dir := t.TempDir()
+	cityDB := writeDatabase(t, dir, "city.mmdb", map[string]any{
+		"81.200.16.0/20": berlin,
+		"2001:db8::/32":  map[string]any{"country": map[string]any{"iso_code": "NL"}},
+	})
+	asnDB := writeDatabase(t, dir, "asn.mmdb", map[string]any{
+		"81.200.0.0/16": map[string]any{
+			"autonomous_system_number":       uint32(3320),
+			"autonomous_system_organization": "Deutsche Telekom AG",
+		},
+	})
+
+	tests := []struct {
+		name   string
+		policy IPPolicy
+		ip     string
+		geo    domain.Geo
+		wantIP string
+	}{
+		{
+			name:   "ipv4",
+			ip:     "81.200.17.5",
+			geo:    domain.Geo{Country: "DE", Region: "BE", City: "Berlin", ASN: 3320, ASOrg: "Deutsche Telekom AG"},
+			wantIP: "81.200.17.5",
+		},
+		{
+			name:   "only asn",
+			ip:     "81.200.200.1",
+			geo:    domain.Geo{ASN: 3320, ASOrg: "Deutsche Telekom AG"},
+			wantIP: "81.200.200.1",
+		},
+		{
+			name:   "ipv6",
+			ip:     "2001:db8::1",
+			geo:    domain.Geo{Country: "NL"},
+			wantIP: "2001:db8::1",
+		},
+		{
+			name:   "unknown",
+			ip:     "192.0.2.1",
+			wantIP: "192.0.2.1",
+		},
+		{
+			name:   "truncate ipv4",
+			policy: IPTruncate,
+			ip:     "81.200.17.5",
+			geo:    domain.Geo{Country: "DE", Region: "BE", City: "Berlin", ASN: 3320, ASOrg: "Deutsche Telekom AG"},
+			wantIP: "81.200.17.0",
+		},
+		{
+			name:   "truncate ipv6",
+			policy: IPTruncate,
+			ip:     "2001:db8:aa:bb::1",
+			geo:    domain.Geo{Country: "NL"},
+			wantIP: "2001:db8:aa::",
+		},
+		{
+			name:   "drop",
+			policy: IPDrop,
+			ip:     "2001:db8::1",
+			geo:    domain.Geo{Country: "NL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(Config{CityDB: cityDB, ASNDB: asnDB, IP: tt.policy})
+			require.NoError(t, err)
+
+			event := &domain.Event{IP: netip.MustParseAddr(tt.ip)}
+			l.Enrich(event)
+			require.Equal(t, tt.geo, event.Geo)
+			if tt.wantIP == "" {
+				require.False(t, event.IP.IsValid())
+			} else {
+				require.Equal(t, tt.wantIP, event.IP.String())
+			}
+		})
+	}
+}
+
+func TestLocator_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeDatabase(t, dir, "city.mmdb", map[string]any{"81.200.16.0/20": berlin})
+
+	l, err := New(Config{CityDB: path})
+	require.NoError(t, err)
+	addr := netip.MustParseAddr("81.200.17.5")
+	require.Equal(t, "DE", l.Locate(addr).Country)
+
+	writeDatabase(t, dir, "city.mmdb", map[string]any{
+		"81.200.16.0/20": map[string]any{"country": map[string]any{"iso_code": "AT"}},
+	})
+	bumpModTime(t, path, time.Minute)
+	require.NoError(t, l.Reload())
+	require.Equal(t, "AT", l.Locate(addr).Country)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o600))
+	bumpModTime(t, path, 2*time.Minute)
+	require.Error(t, l.Reload())
+	require.Equal(t, "AT", l.Locate(addr).Country)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{IP: "hash"})
+	require.ErrorContains(t, err, "ip: must be keep, truncate or drop")
+
+	_, err = New(Config{CityDB: filepath.Join(t.TempDir(), "missing.mmdb")})
+	require.ErrorContains(t, err, "stat GeoIP database")
+
+	l, err := New(Config{})
+	require.NoError(t, err)
+	require.Equal(t, domain.Geo{}, l.Locate(netip.MustParseAddr("81.200.17.5")))
+}
+
+func bumpModTime(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	modTime := time.Now().Add(d)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// The MaxMind DB format, https://maxmind.github.io/MaxMind-DB/, as far as
+// writeDatabase needs it.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree and the
+// data section.
+const dataSectionSeparator = 16
+
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeArray  = 11
+)
+
+// writeDatabase writes an IPv6 MaxMind DB with 24-bit records. IPv4 networks are
+// placed under ::/96, as MaxMind does.
+func writeDatabase(t *testing.T, dir, name string, networks map[string]any) string {
+	t.Helper()
+
+	type node struct{ records [2]int }
+	const (
+		empty = -1
+		data  = -2
+	)
+	nodes := []node{{records: [2]int{empty, empty}}}
+	var (
+		section []byte
+		offsets = map[[2]int]int{}
+	)
+
+	prefixes := make([]string, 0, len(networks))
+	for p := range networks {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		prefix := netip.MustParsePrefix(p)
+		ip, bits := prefix.Addr().As16(), prefix.Bits()
+		if prefix.Addr().Is4() {
+			ip, bits = netip.AddrFrom16([16]byte{12: ip[12], 13: ip[13], 14: ip[14], 15: ip[15]}).As16(), bits+96
+		}
+
+		offset := len(section)
+		section = append(section, encodeValue(t, networks[p])...)
+
+		n := 0
+		for bit := 0; bit < bits; bit++ {
+			side := int(ip[bit/8]>>(7-bit%8)) & 1
+			if bit == bits-1 {
+				nodes[n].records[side] = data
+				offsets[[2]int{n, side}] = offset
+				break
+			}
+			if nodes[n].records[side] < 0 {
+				nodes = append(nodes, node{records: [2]int{empty, empty}})
+				nodes[n].records[side] = len(nodes) - 1
+			}
+			n = nodes[n].records[side]
+		}
+	}
+
+	var b []byte
+	for n, nd := range nodes {
+		for side, record := range nd.records {
+			switch record {
+			case empty:
+				record = len(nodes)
+			case data:
+				record = len(nodes) + dataSectionSeparator + offsets[[2]int{n, side}]
+			}
+			b = append(b, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	b = append(b, make([]byte, dataSectionSeparator)...)
+	b = append(b, section...)
+	b = append(b, metadataMarker...)
+	b = append(b, encodeValue(t, map[string]any{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(24),
+		"ip_version":    uint16(6),
+		"database_type": "Test",
+	})...)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+func encodeValue(t *testing.T, value any) []byte {
+	t.Helper()
+
+	control := func(typ, size int) []byte {
+		require.Less(t, size, 285)
+		var extra []byte
+		if size >= 29 {
+			size, extra = 29, []byte{byte(size - 29)}
+		}
+		b := []byte{byte(typ<<5 | size)}
+		if typ > 7 {
+			b = []byte{byte(size), byte(typ - 7)}
+		}
+		return append(b, extra...)
+	}
+	unsigned := func(typ int, v uint64) []byte {
+		var b []byte
+		for ; v > 0; v >>= 8 {
+			b = append([]byte{byte(v)}, b...)
+		}
+		return append(control(typ, len(b)), b...)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return append(control(typeString, len(v)), v...)
+	case uint16:
+		return unsigned(typeUint16, uint64(v))
+	case uint32:
+		return unsigned(typeUint32, uint64(v))
+	case []any:
+		b := control(typeArray, len(v))
+		for _, item := range v {
+			b = append(b, encodeValue(t, item)...)
+		}
+		return b
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b := control(typeMap, len(v))
+		for _, key := range keys {
+			b = append(b, encodeValue(t, key)...)
+			b = append(b, encodeValue(t, v[key])...)
+		}
+		return b
+	default:
+		t.Fatalf("unsupported value %T", value)
+		return nil
+	}
+}
@@ -0,0 +1,175 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
+)
+
+const (
+	defaultIPv4Bits    = 24
+	defaultIPv6Bits    = 48
+	defaultReplacement = "[redacted]"
+)
+
+// Patterns are the built-in patterns of personal data that scrub rules refer to by
+// name. Phone numbers need a leading + or a 3-3-4 grouping, so that dates, versions
+// and ids are left alone.
+var Patterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"phone": regexp.MustCompile(`\+\d[\d\s().-]{7,}\d|\(\d{3}\)\s?\d{3}[\s.-]\d{4}|\b\d{3}[\s.-]\d{3}[\s.-]\d{4}\b`),
+}
+
+type Config struct {
+	// Rules is a YAML file of privacy rules, see Load. Events are queued as sent
+	// when it is empty.
+	Rules string `mapstructure:"rules"`
+}
+
+// file declares the version of the rules and the rules, each with exactly one of
+// the built-ins, applied in order:
+//
+//	version: 2024-01
+//	rules:
+//	  - hash: {fields: [device_id], key_env: DATALOG_PRIVACY_KEY}
+//	  - truncate_ip: {ipv4_bits: 24, ipv6_bits: 48}
+//	  - scrub: {fields: [param_str], patterns: [email, phone], replacement: "[redacted]"}
+//	  - drop: {fields: [properties.name]}
+type file struct {
+	Version string     `yaml:"version"`
+	Rules   []ruleSpec `yaml:"rules"`
+}
+
+type ruleSpec struct {
+	Hash       *pipeline.HashSpec `yaml:"hash"`
+	TruncateIP *struct {
+		IPv4Bits int `yaml:"ipv4_bits"`
+		IPv6Bits int `yaml:"ipv6_bits"`
+	} `yaml:"truncate_ip"`
+	Scrub *struct {
+		Fields []string `yaml:"fields"`
+		// Patterns are names of Patterns.
+		Patterns []string `yaml:"patterns"`
+		// Regex are additional regular expressions.
+		Regex       []string `yaml:"regex"`
+		Replacement *string  `yaml:"replacement"`
+	} `yaml:"scrub"`
+	Drop *struct {
+		Fields []string `yaml:"fields"`
+	} `yaml:"drop"`
+}
+
+// RuleSet removes personal data from events before they leave the node, and tags
+// them with its version. It is a pipeline.Stage, safe for concurrent use.
+type RuleSet struct {
+	Version string
+	rules   pipeline.Chain
+}
+
+// Load reads the rules declared in a file.
+func Load(path string) (*RuleSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read privacy rules %s: %w", path, err)
+	}
+
+	var f file
+	if err = yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode privacy rules %s: %w", path, err)
+	}
+	if f.Version == "" {
+		return nil, fmt.Errorf("privacy rules %s: missing version", path)
+	}
+
+	r := &RuleSet{Version: f.Version, rules: make(pipeline.Chain, 0, len(f.Rules))}
+	for i, spec := range f.Rules {
+		rule, err := spec.build()
+		if err != nil {
+			return nil, fmt.Errorf("privacy rules %s: rule %d: %w", path, i, err)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+// Process applies the rules to the event and tags it with the version.
+func (r *RuleSet) Process(event *domain.Event, emit func(*domain.Event)) {
+	r.rules.Run(event, func(e *domain.Event) {
+		e.PrivacyVersion = r.Version
+		emit(e)
+	})
+}
+
+// DeviceID returns the device ID as the rules store it, so that the events of a
+// device can be found from the ID its client sends. It is empty when the rules drop
+// device IDs.
+func (r *RuleSet) DeviceID(id string) string {
+	stored := ""
+	r.rules.Run(&domain.Event{DeviceID: id}, func(e *domain.Event) {
+		stored = e.DeviceID
+	})
+	return stored
+}
+
+func (s ruleSpec) build() (pipeline.Stage, error) {
+	n := 0
+	for _, set := range []bool{s.Hash != nil, s.TruncateIP != nil, s.Scrub != nil, s.Drop != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, errors.New("must declare exactly one of hash, truncate_ip, scrub and drop")
+	}
+
+	switch {
+	case s.Hash != nil:
+		return s.Hash.Build()
+	case s.TruncateIP != nil:
+		v4, v6 := s.TruncateIP.IPv4Bits, s.TruncateIP.IPv6Bits
+		if v4 == 0 {
+			v4 = defaultIPv4Bits
+		}
+		if v6 == 0 {
+			v6 = defaultIPv6Bits
+		}
+		return pipeline.TruncateIP(v4, v6)
+	case s.Scrub != nil:
+		fields, err := pipeline.ParseFields(s.Scrub.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("scrub: %w", err)
+		}
+		patterns := make([]*regexp.Regexp, 0, len(s.Scrub.Patterns)+len(s.Scrub.Regex))
+		for _, name := range s.Scrub.Patterns {
+			p, ok := Patterns[name]
+			if !ok {
+				return nil, fmt.Errorf("scrub: unknown pattern %q", name)
+			}
+			patterns = append(patterns, p)
+		}
+		for _, expr := range s.Scrub.Regex {
+			p, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("scrub: %w", err)
+			}
+			patterns = append(patterns, p)
+		}
+		replacement := defaultReplacement
+		if s.Scrub.Replacement != nil {
+			replacement = *s.Scrub.Replacement
+		}
+		return pipeline.Scrub(fields, patterns, replacement)
+	default:
+		fields, err := pipeline.ParseFields(s.Drop.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("drop: %w", err)
+		}
+		return pipeline.Remove(fields)
+	}
+}
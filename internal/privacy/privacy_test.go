@@ -0,0 +1,111 @@
+package privacy
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func writeRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "privacy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func process(r *RuleSet, event domain.Event) []domain.Event {
+	var out []domain.Event
+	r.Process(&event, func(e *domain.Event) {
+		out = append(out, *e)
+	})
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_PRIVACY_KEY", "secret")
+	rules, err := Load(writeRules(t, `
+version: "2024-01"
+rules:
+  - hash: {fields: [device_id], key_env: TEST_PRIVACY_KEY}
+  - truncate_ip: {}
+  - scrub: {fields: [param_str, properties.note], patterns: [email, phone], regex: ['card \d{4}']}
+  - drop: {fields: [session, user_properties.name]}
+`))
+	require.NoError(t, err)
+	require.Equal(t, "2024-01", rules.Version)
+
+	out := process(rules, domain.Event{
+		IP:       netip.MustParseAddr("203.0.113.77"),
+		DeviceID: "d1",
+		Session:  "s1",
+		ParamStr: "mail jane.doe@example.com or call +1 (555) 010-9999, card 1234",
+		TypedProperties: domain.TypedProperties{
+			String: map[string]string{"note": "call 555-010-9999 on 2024-01-15"},
+		},
+		FlatUserProperties: domain.FlatProperties{String: map[string]string{"name": "Jane", "plan": "pro"}},
+	})
+	require.Len(t, out, 1)
+	require.Len(t, out[0].DeviceID, 64)
+	require.NotEqual(t, "d1", out[0].DeviceID)
+	require.Equal(t, netip.MustParseAddr("203.0.113.0"), out[0].IP)
+	require.Empty(t, out[0].Session)
+	require.Equal(t, "mail [redacted] or call [redacted], [redacted]", out[0].ParamStr)
+	require.Equal(t, map[string]string{"note": "call [redacted] on 2024-01-15"}, out[0].TypedProperties.String)
+	require.Equal(t, map[string]string{"plan": "pro"}, out[0].FlatUserProperties.String)
+	require.Equal(t, "2024-01", out[0].PrivacyVersion)
+
+	require.Equal(t, out[0].DeviceID, rules.DeviceID("d1"))
+
+	out = process(rules, domain.Event{IP: netip.MustParseAddr("2001:db8:1234:5678::1")})
+	require.Equal(t, netip.MustParseAddr("2001:db8:1234::"), out[0].IP)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]string{
+		"rules: []\n":                  "missing version",
+		"version: 1\nrules:\n  - {}\n": "rule 0: must declare exactly one of hash, truncate_ip, scrub and drop",
+		"version: 1\nrules:\n  - hash: {fields: [device_id]}\n":                          "hash: missing key",
+		"version: 1\nrules:\n  - hash: {fields: [device_id], key_env: TEST_UNSET_KEY}\n": "hash: environment variable TEST_UNSET_KEY is not set",
+		"version: 1\nrules:\n  - truncate_ip: {ipv4_bits: 33}\n":                         "cannot keep 33 bits of IPv4",
+		"version: 1\nrules:\n  - scrub: {fields: [param_str], patterns: [ssn]}\n":        `scrub: unknown pattern "ssn"`,
+		"version: 1\nrules:\n  - scrub: {fields: [sequence], patterns: [email]}\n":       "cannot scrub sequence",
+		"version: 1\nrules:\n  - drop: {fields: [project_id]}\n":                         "cannot remove project_id: read-only field",
+		"version: 1\nrules:\n  - drop: {fields: [country]}\n":                            `drop: unknown field "country"`,
+		"version: [": "decode privacy rules",
+	}
+	for content, want := range tests {
+		t.Run(want, func(t *testing.T) {
+			_, err := Load(writeRules(t, content))
+			require.ErrorContains(t, err, want)
+		})
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "read privacy rules")
+}
+
+func TestPatterns(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		match   bool
+	}{
+		"a.b+c@mail.example.org": {"email", true},
+		"user@localhost":         {"email", false},
+		"+44 20 7946 0958":       {"phone", true},
+		"(212) 555-0134":         {"phone", true},
+		"212.555.0134":           {"phone", true},
+		"2024-01-15":             {"phone", false},
+		"order 1234567890":       {"phone", false},
+		"v1.2.3":                 {"phone", false},
+	}
+	for s, tt := range tests {
+		t.Run(s, func(t *testing.T) {
+			require.Equal(t, tt.match, Patterns[tt.pattern].MatchString(s))
+		})
+	}
+}
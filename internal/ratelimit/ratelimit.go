@@ -0,0 +1,302 @@
+package ratelimit
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that refilled completely are forgotten, so
+// that a bucket per client IP does not grow the memory without bound.
+const sweepInterval = time.Minute
+
+const (
+	ScopeGlobal = "global"
+	ScopeKey    = "key"
+	ScopeIP     = "ip"
+
+	Requests = "requests"
+	Events   = "events"
+)
+
+// Throttled counts the requests turned away, by scope and what ran out, such as
+// "ip.requests".
+var Throttled = expvar.NewMap("ratelimit_throttled")
+
+// Rate is a token bucket: PerSecond tokens are added every second, up to Burst. A
+// zero PerSecond disables the bucket.
+type Rate struct {
+	PerSecond float64 `mapstructure:"per_second"`
+	// Burst is the size of the bucket, PerSecond rounded up by default.
+	Burst int `mapstructure:"burst"`
+}
+
+func (r Rate) Enabled() bool {
+	return r.PerSecond > 0
+}
+
+func (r Rate) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return math.Max(math.Ceil(r.PerSecond), 1)
+}
+
+// Limit bounds the requests and the events per second of a scope.
+type Limit struct {
+	Requests Rate `mapstructure:"requests"`
+	Events   Rate `mapstructure:"events"`
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests.Enabled() || l.Events.Enabled()
+}
+
+type Config struct {
+	// Global is shared by all clients.
+	Global Limit `mapstructure:"global"`
+	// PerKey applies to every API key, when requests are authenticated.
+	PerKey Limit `mapstructure:"per_key"`
+	// PerIP applies to every client IP.
+	PerIP Limit `mapstructure:"per_ip"`
+}
+
+func (c Config) Enabled() bool {
+	return c.Global.Enabled() || c.PerKey.Enabled() || c.PerIP.Enabled()
+}
+
+// State describes a bucket after a request, for the RateLimit response headers.
+type State struct {
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets holds a token bucket per key, all with the same rate. It is safe for
+// concurrent use.
+type Buckets struct {
+	rate  Rate
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewBuckets(rate Rate) *Buckets {
+	return &Buckets{
+		rate:    rate,
+		burst:   rate.burst(),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take removes n tokens from the bucket of key if it holds them. Otherwise it
+// returns false and how long until it will.
+func (b *Buckets) Take(key string, n float64) (State, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bk := b.refill(key)
+	if bk.tokens < n {
+		return b.state(bk), b.wait(bk, n), false
+	}
+	bk.tokens -= n
+	return b.state(bk), 0, true
+}
+
+// Check reports whether the bucket of key is not in debt, without taking tokens.
+// Otherwise it returns how long until it is paid off.
+func (b *Buckets) Check(key string) (State, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bk := b.refill(key)
+	if bk.tokens <= 0 {
+		return b.state(bk), b.wait(bk, math.SmallestNonzeroFloat64), false
+	}
+	return b.state(bk), 0, true
+}
+
+// Charge removes n tokens from the bucket of key, which may go into debt. It is
+// used for costs only known once a request has been served.
+func (b *Buckets) Charge(key string, n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(key).tokens -= n
+}
+
+func (b *Buckets) refill(key string) *bucket {
+	now := b.now()
+	if now.Sub(b.lastSweep) >= sweepInterval {
+		b.sweep(now)
+	}
+
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: b.burst, last: now}
+		b.buckets[key] = bk
+		return bk
+	}
+
+	bk.tokens = math.Min(b.burst, bk.tokens+now.Sub(bk.last).Seconds()*b.rate.PerSecond)
+	bk.last = now
+	return bk
+}
+
+// sweep forgets the buckets that are full by now; they are created full again.
+func (b *Buckets) sweep(now time.Time) {
+	b.lastSweep = now
+	for key, bk := range b.buckets {
+		if bk.tokens+now.Sub(bk.last).Seconds()*b.rate.PerSecond >= b.burst {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+func (b *Buckets) wait(bk *bucket, n float64) time.Duration {
+	return time.Duration((n - bk.tokens) / b.rate.PerSecond * float64(time.Second))
+}
+
+func (b *Buckets) state(bk *bucket) State {
+	return State{
+		Limit:     int(b.burst),
+		Remaining: int(math.Max(0, math.Floor(bk.tokens))),
+		Reset:     time.Duration((b.burst - bk.tokens) / b.rate.PerSecond * float64(time.Second)),
+	}
+}
+
+// Decision is the outcome of Limiter.Allow.
+type Decision struct {
+	Allowed bool
+	// Scope and Resource name the bucket that turned the request away.
+	Scope    string
+	Resource string
+	// RetryAfter is how long until the request would be allowed.
+	RetryAfter time.Duration
+	// State is the request bucket with the fewest remaining tokens, nil without
+	// request limits.
+	State *State
+}
+
+type scope struct {
+	name     string
+	requests *Buckets
+	events   *Buckets
+}
+
+func newScope(name string, limit Limit) scope {
+	s := scope{name: name}
+	if limit.Requests.Enabled() {
+		s.requests = NewBuckets(limit.Requests)
+	}
+	if limit.Events.Enabled() {
+		s.events = NewBuckets(limit.Events)
+	}
+	return s
+}
+
+// Limiter applies the per-IP, per-key and global limits. A request takes a request
+// token from every scope; its events are charged once they are decoded, and further
+// requests are turned away while an events bucket is in debt.
+type Limiter struct {
+	// scopes are ordered from the narrowest, see Allow.
+	scopes []scope
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		scopes: []scope{
+			newScope(ScopeIP, cfg.PerIP),
+			newScope(ScopeKey, cfg.PerKey),
+			newScope(ScopeGlobal, cfg.Global),
+		},
+	}
+}
+
+// Allow checks a request with the API key, empty without authentication, from the
+// client IP. The scopes are checked from the narrowest, ip, key and then global,
+// and each takes its request token before the next one is checked. Tokens taken
+// before a later scope refuses are not returned, but a client over its own limits
+// is turned away before it drains the global buckets, which are shared by all.
+func (l *Limiter) Allow(key, ip string) Decision {
+	return l.allow(key, ip, false)
+}
+
+// AllowKey checks the per-key limits only, for requests whose IP was checked with
+// Allow before their key was known.
+func (l *Limiter) AllowKey(key string) Decision {
+	if key == "" {
+		return Decision{Allowed: true}
+	}
+	return l.allow(key, "", true)
+}
+
+func (l *Limiter) allow(key, ip string, keyOnly bool) Decision {
+	var d Decision
+	for _, s := range l.scopes {
+		if keyOnly && s.name != ScopeKey {
+			continue
+		}
+		id, ok := s.id(key, ip)
+		if !ok {
+			continue
+		}
+
+		if s.events != nil {
+			if _, wait, ok := s.events.Check(id); !ok {
+				return l.throttle(d, s.name, Events, wait)
+			}
+		}
+		if s.requests != nil {
+			state, wait, ok := s.requests.Take(id, 1)
+			if d.State == nil || state.Remaining < d.State.Remaining {
+				d.State = &state
+			}
+			if !ok {
+				return l.throttle(d, s.name, Requests, wait)
+			}
+		}
+	}
+	d.Allowed = true
+	return d
+}
+
+// Charge takes the events of an allowed request from every scope.
+func (l *Limiter) Charge(key, ip string, events int) {
+	if events <= 0 {
+		return
+	}
+	for _, s := range l.scopes {
+		if id, ok := s.id(key, ip); ok && s.events != nil {
+			s.events.Charge(id, float64(events))
+		}
+	}
+}
+
+func (l *Limiter) throttle(d Decision, scope, resource string, wait time.Duration) Decision {
+	Throttled.Add(scope+"."+resource, 1)
+	d.Scope, d.Resource, d.RetryAfter = scope, resource, wait
+	return d
+}
+
+func (s scope) id(key, ip string) (string, bool) {
+	switch s.name {
+	case ScopeKey:
+		return key, key != ""
+	case ScopeIP:
+		return ip, true
+	default:
+		return "", true
+	}
+}
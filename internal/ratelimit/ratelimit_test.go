@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestBuckets_Take(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	b := NewBuckets(Rate{PerSecond: 2, Burst: 3})
+	b.now = c.Now
+
+	for i := 0; i < 3; i++ {
+		_, _, ok := b.Take("a", 1)
+		require.True(t, ok)
+	}
+	state, wait, ok := b.Take("a", 1)
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+	require.Equal(t, State{Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}, state)
+
+	// Other keys have their own bucket.
+	_, _, ok = b.Take("b", 1)
+	require.True(t, ok)
+
+	c.now = c.now.Add(500 * time.Millisecond)
+	state, _, ok = b.Take("a", 1)
+	require.True(t, ok)
+	require.Equal(t, 0, state.Remaining)
+}
+
+func TestBuckets_Charge(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	b := NewBuckets(Rate{PerSecond: 10})
+	b.now = c.Now
+
+	_, _, ok := b.Check("a")
+	require.True(t, ok)
+
+	b.Charge("a", 30)
+	_, wait, ok := b.Check("a")
+	require.False(t, ok)
+	require.InDelta(t, 2*time.Second, wait, float64(time.Millisecond))
+
+	c.now = c.now.Add(2*time.Second + time.Millisecond)
+	_, _, ok = b.Check("a")
+	require.True(t, ok)
+}
+
+func TestBuckets_Sweep(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	b := NewBuckets(Rate{PerSecond: 1, Burst: 1})
+	b.now = c.Now
+
+	b.Take("a", 1)
+	c.now = c.now.Add(sweepInterval)
+	b.Take("b", 1)
+	require.Len(t, b.buckets, 1, "the refilled bucket must be forgotten")
+}
+
+func throttled(key string) int64 {
+	if v, ok := Throttled.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := New(Config{
+		PerKey: Limit{Requests: Rate{PerSecond: 100}},
+		PerIP:  Limit{Requests: Rate{PerSecond: 1, Burst: 2}, Events: Rate{PerSecond: 10}},
+	})
+
+	d := l.Allow("key", "10.0.0.1")
+	require.True(t, d.Allowed)
+	require.Equal(t, 1, d.State.Remaining, "the tightest request limit is reported")
+
+	l.Charge("key", "10.0.0.1", 50)
+	before := throttled("ip.events")
+	d = l.Allow("key", "10.0.0.1")
+	require.False(t, d.Allowed)
+	require.Equal(t, ScopeIP, d.Scope)
+	require.Equal(t, Events, d.Resource)
+	require.Positive(t, d.RetryAfter)
+	require.Equal(t, before+1, throttled("ip.events"))
+
+	d = l.Allow("key", "10.0.0.2")
+	require.True(t, d.Allowed)
+	d = l.Allow("key", "10.0.0.2")
+	require.True(t, d.Allowed)
+	d = l.Allow("key", "10.0.0.2")
+	require.False(t, d.Allowed)
+	require.Equal(t, Requests, d.Resource)
+}
+
+func TestLimiter_Allow_Flood(t *testing.T) {
+	l := New(Config{
+		Global: Limit{Requests: Rate{PerSecond: 10}},
+		PerIP:  Limit{Requests: Rate{PerSecond: 1, Burst: 2}},
+	})
+
+	// The flooding IP is turned away by its own limit without draining the global
+	// bucket.
+	for i := 0; i < 100; i++ {
+		d := l.Allow("", "10.0.0.1")
+		if i < 2 {
+			require.True(t, d.Allowed)
+			continue
+		}
+		require.False(t, d.Allowed)
+		require.Equal(t, ScopeIP, d.Scope)
+	}
+	d := l.Allow("", "10.0.0.2")
+	require.True(t, d.Allowed)
+	require.Equal(t, 1, d.State.Remaining)
+}
+
+func TestLimiter_AllowKey(t *testing.T) {
+	l := New(Config{
+		PerKey: Limit{Requests: Rate{PerSecond: 1}},
+		PerIP:  Limit{Requests: Rate{PerSecond: 1}},
+	})
+
+	// Allow without a key leaves the per-key buckets to AllowKey.
+	require.True(t, l.Allow("", "10.0.0.1").Allowed)
+	require.True(t, l.AllowKey("key").Allowed)
+	d := l.AllowKey("key")
+	require.False(t, d.Allowed)
+	require.Equal(t, ScopeKey, d.Scope)
+	require.True(t, l.Allow("", "10.0.0.2").Allowed)
+	require.True(t, l.AllowKey("").Allowed)
+}
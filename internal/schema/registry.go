@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// UnknownPolicy tells what happens to events without a declared schema.
+type UnknownPolicy string
+
+const (
+	UnknownAllow      UnknownPolicy = "allow"
+	UnknownReject     UnknownPolicy = "reject"
+	UnknownQuarantine UnknownPolicy = "quarantine"
+)
+
+type Config struct {
+	// Path is a schema file or a directory of .yaml, .yml and .json files. The
+	// registry is disabled when it is empty.
+	Path string `mapstructure:"path"`
+	// UnknownEvents is allow, reject or quarantine; reject by default.
+	UnknownEvents UnknownPolicy `mapstructure:"unknown_events"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// Verdict is the outcome of checking an event against the registry.
+type Verdict int
+
+const (
+	Accept Verdict = iota
+	Reject
+	Quarantine
+)
+
+type file struct {
+	Events []Event `yaml:"events"`
+}
+
+// Registry holds the declared event types. It is safe for concurrent use and can
+// be reloaded while events are checked.
+type Registry struct {
+	path           string
+	unknown        UnknownPolicy
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	events  map[string]Event
+	modTime time.Time
+}
+
+func NewRegistry(cfg Config) (*Registry, error) {
+	unknown := cfg.UnknownEvents
+	switch unknown {
+	case "":
+		unknown = UnknownReject
+	case UnknownAllow, UnknownReject, UnknownQuarantine:
+	default:
+		return nil, fmt.Errorf("unknown_events: must be %s, %s or %s, got %q", UnknownAllow, UnknownReject, UnknownQuarantine, unknown)
+	}
+
+	reloadInterval := cfg.ReloadInterval
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	r := &Registry{
+		path:           cfg.Path,
+		unknown:        unknown,
+		reloadInterval: reloadInterval,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the schema files again. The current schemas are kept when the files
+// are invalid.
+func (r *Registry) Reload() error {
+	files, modTime, err := schemaFiles(r.path)
+	if err != nil {
+		return err
+	}
+
+	events := make(map[string]Event)
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read schema %s: %w", path, err)
+		}
+
+		var f file
+		if err = yaml.Unmarshal(b, &f); err != nil {
+			return fmt.Errorf("decode schema %s: %w", path, err)
+		}
+
+		for _, e := range f.Events {
+			if err = e.validate(); err != nil {
+				return fmt.Errorf("schema %s: %w", path, err)
+			}
+			if _, ok := events[e.Name]; ok {
+				return fmt.Errorf("schema %s: event %s is declared twice", path, e.Name)
+			}
+			events[e.Name] = e
+		}
+	}
+
+	r.mu.Lock()
+	r.events = events
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the registry whenever a schema file changes, until ctx is done.
+func (r *Registry) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, modTime, err := schemaFiles(r.path)
+			if err != nil {
+				log.Error().Err(err).Str("path", r.path).Msg("Failed to check schemas.")
+				continue
+			}
+
+			r.mu.RLock()
+			changed := !modTime.Equal(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err = r.Reload(); err != nil {
+				log.Error().Err(err).Str("path", r.path).Msg("Failed to reload schemas, keeping the previous ones.")
+				continue
+			}
+			log.Info().Str("path", r.path).Msg("Schemas reloaded.")
+		}
+	}
+}
+
+// Apply checks the event against its declaration and moves its raw properties into
+// TypedProperties. Field errors are returned for rejected events only.
+func (r *Registry) Apply(event *domain.Event) (Verdict, []domain.FieldError) {
+	r.mu.RLock()
+	declared, ok := r.events[event.Event]
+	r.mu.RUnlock()
+
+	event.TypedProperties = domain.TypedProperties{}
+	if !ok {
+		switch r.unknown {
+		case UnknownAllow:
+			Infer(event)
+			return Accept, nil
+		case UnknownQuarantine:
+			Infer(event)
+			return Quarantine, nil
+		default:
+			return Reject, []domain.FieldError{{
+				Field:   "event",
+				Code:    domain.CodeUnknownEvent,
+				Message: fmt.Sprintf("%q has no schema", event.Event),
+			}}
+		}
+	}
+
+	errs := declared.apply(event)
+	event.Properties = nil
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Field < errs[j].Field
+		})
+		return Reject, errs
+	}
+	return Accept, nil
+}
+
+// schemaFiles lists the schema files under path and returns the latest modification
+// time among them and the directory, so that added and removed files are noticed.
+func schemaFiles(path string) ([]string, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("stat schemas: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, info.ModTime(), nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read schemas: %w", err)
+	}
+
+	modTime := info.ModTime()
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("stat schema %s: %w", entry.Name(), err)
+		}
+		if entryInfo.ModTime().After(modTime) {
+			modTime = entryInfo.ModTime()
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	return files, modTime, nil
+}
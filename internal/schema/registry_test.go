@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const purchaseSchema = `
+events:
+  - name: purchase
+    properties:
+      amount: {type: float, required: true}
+      quantity: {type: int}
+      gift: {type: bool}
+      paid_at: {type: timestamp}
+      items: {type: array, items: int}
+      currency: {type: string}
+`
+
+func writeSchema(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+}
+
+func TestRegistry_Apply(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "purchase.yaml", purchaseSchema)
+
+	registry, err := NewRegistry(Config{Path: dir})
+	require.NoError(t, err)
+
+	event := &domain.Event{
+		Event: "purchase",
+		Properties: map[string]any{
+			"amount":   9.99,
+			"quantity": float64(2),
+			"gift":     true,
+			"paid_at":  "2023-05-31T10:00:00+03:00",
+			"items":    []any{float64(1), float64(2)},
+			"currency": "EUR",
+		},
+	}
+	verdict, errs := registry.Apply(event)
+	require.Equal(t, Accept, verdict)
+	require.Empty(t, errs)
+	require.Nil(t, event.Properties)
+	require.Equal(t, domain.TypedProperties{
+		String:    map[string]string{"currency": "EUR"},
+		Int:       map[string]int64{"quantity": 2},
+		Float:     map[string]float64{"amount": 9.99},
+		Bool:      map[string]bool{"gift": true},
+		Timestamp: map[string]time.Time{"paid_at": time.Date(2023, 5, 31, 7, 0, 0, 0, time.UTC)},
+		Array:     map[string][]string{"items": {"1", "2"}},
+	}, event.TypedProperties)
+
+	event = &domain.Event{
+		Event: "purchase",
+		Properties: map[string]any{
+			"quantity": 1.5,
+			"coupon":   "SUMMER",
+		},
+	}
+	verdict, errs = registry.Apply(event)
+	require.Equal(t, Reject, verdict)
+	require.Equal(t, []domain.FieldError{
+		{Field: "properties.amount", Code: domain.CodeRequired, Message: "is required"},
+		{Field: "properties.coupon", Code: domain.CodeUnknownField, Message: `is not declared for event "purchase"`},
+		{Field: "properties.quantity", Code: domain.CodeInvalidType, Message: "expected int, got 1.5"},
+	}, errs)
+}
+
+func TestRegistry_UnknownEvents(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "purchase.yaml", purchaseSchema)
+
+	cases := map[UnknownPolicy]Verdict{
+		UnknownAllow:      Accept,
+		UnknownReject:     Reject,
+		UnknownQuarantine: Quarantine,
+	}
+	for policy, expected := range cases {
+		t.Run(string(policy), func(t *testing.T) {
+			registry, err := NewRegistry(Config{Path: dir, UnknownEvents: policy})
+			require.NoError(t, err)
+
+			event := &domain.Event{Event: "app_open", Properties: map[string]any{"screen": "main"}}
+			verdict, _ := registry.Apply(event)
+			require.Equal(t, expected, verdict)
+			if expected != Reject {
+				require.Equal(t, map[string]string{"screen": "main"}, event.TypedProperties.String)
+			}
+		})
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "purchase.yaml", purchaseSchema)
+
+	registry, err := NewRegistry(Config{Path: dir})
+	require.NoError(t, err)
+
+	verdict, _ := registry.Apply(&domain.Event{Event: "app_open"})
+	require.Equal(t, Reject, verdict)
+
+	writeSchema(t, dir, "app.json", `{"events": [{"name": "app_open"}]}`)
+	require.NoError(t, registry.Reload())
+	verdict, _ = registry.Apply(&domain.Event{Event: "app_open"})
+	require.Equal(t, Accept, verdict)
+
+	writeSchema(t, dir, "broken.yaml", `events: [{name: broken, properties: {x: {type: decimal}}}]`)
+	require.Error(t, registry.Reload())
+	verdict, _ = registry.Apply(&domain.Event{Event: "app_open"})
+	require.Equal(t, Accept, verdict, "previous schemas are kept")
+}
@@ -0,0 +1,283 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+type Type string
+
+const (
+	TypeString    Type = "string"
+	TypeInt       Type = "int"
+	TypeFloat     Type = "float"
+	TypeBool      Type = "bool"
+	TypeTimestamp Type = "timestamp"
+	TypeArray     Type = "array"
+)
+
+func (t Type) valid() bool {
+	switch t {
+	case TypeString, TypeInt, TypeFloat, TypeBool, TypeTimestamp, TypeArray:
+		return true
+	default:
+		return false
+	}
+}
+
+// Property declares a typed event property.
+type Property struct {
+	Type     Type `yaml:"type"`
+	Required bool `yaml:"required"`
+	// Items is the element type of an array, string by default. Elements are stored
+	// as strings.
+	Items Type `yaml:"items"`
+}
+
+// Event declares the properties of one event type.
+type Event struct {
+	Name       string              `yaml:"name"`
+	Properties map[string]Property `yaml:"properties"`
+	// AdditionalProperties accepts undeclared properties, stored as by Infer.
+	AdditionalProperties bool `yaml:"additional_properties"`
+}
+
+func (e Event) validate() error {
+	if e.Name == "" {
+		return errors.New("event name is empty")
+	}
+	for name, p := range e.Properties {
+		if !p.Type.valid() {
+			return fmt.Errorf("event %s: property %s: unknown type %q", e.Name, name, p.Type)
+		}
+		if p.Items != "" && (p.Type != TypeArray || !p.Items.valid() || p.Items == TypeArray) {
+			return fmt.Errorf("event %s: property %s: invalid items type %q", e.Name, name, p.Items)
+		}
+	}
+	return nil
+}
+
+// apply types the raw properties of event according to the declaration.
+func (e Event) apply(event *domain.Event) []domain.FieldError {
+	var errs []domain.FieldError
+	for name, p := range e.Properties {
+		value, ok := event.Properties[name]
+		if !ok || value == nil {
+			if p.Required {
+				errs = append(errs, domain.FieldError{
+					Field:   propertyField(name),
+					Code:    domain.CodeRequired,
+					Message: "is required",
+				})
+			}
+			continue
+		}
+
+		if err := set(&event.TypedProperties, name, p, value); err != nil {
+			errs = append(errs, domain.FieldError{
+				Field:   propertyField(name),
+				Code:    domain.CodeInvalidType,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	additional := make(map[string]any)
+	for name, value := range event.Properties {
+		if _, ok := e.Properties[name]; ok {
+			continue
+		}
+		if !e.AdditionalProperties {
+			errs = append(errs, domain.FieldError{
+				Field:   propertyField(name),
+				Code:    domain.CodeUnknownField,
+				Message: fmt.Sprintf("is not declared for event %q", e.Name),
+			})
+			continue
+		}
+		additional[name] = value
+	}
+	infer(&event.TypedProperties, additional)
+	return errs
+}
+
+// Infer stores the raw properties of an event without a declaration in the string
+// and float maps, the way user properties are stored: numbers keep their value and
+// everything else becomes a string, see domain.Flatten.
+func Infer(event *domain.Event) {
+	infer(&event.TypedProperties, event.Properties)
+	event.Properties = nil
+}
+
+func infer(props *domain.TypedProperties, raw map[string]any) {
+	flat := domain.Flatten(raw)
+	for name, value := range flat.String {
+		props.SetString(name, value)
+	}
+	for name, value := range flat.Float {
+		props.SetFloat(name, value)
+	}
+}
+
+func set(props *domain.TypedProperties, name string, p Property, value any) error {
+	switch p.Type {
+	case TypeString:
+		v, ok := value.(string)
+		if !ok {
+			return typeError(p.Type, value)
+		}
+		props.SetString(name, v)
+	case TypeInt:
+		v, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		props.SetInt(name, v)
+	case TypeFloat:
+		v, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		props.SetFloat(name, v)
+	case TypeBool:
+		v, ok := value.(bool)
+		if !ok {
+			return typeError(p.Type, value)
+		}
+		props.SetBool(name, v)
+	case TypeTimestamp:
+		v, err := toTimestamp(value)
+		if err != nil {
+			return err
+		}
+		props.SetTimestamp(name, v)
+	case TypeArray:
+		v, err := toArray(value, p.Items)
+		if err != nil {
+			return err
+		}
+		props.SetArray(name, v)
+	}
+	return nil
+}
+
+// toInt reads numbers decoded as json.Number exactly. float64(math.MaxInt64) is
+// 2^63, which is out of range, hence the >=.
+func toInt(value any) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("expected int, got %s", v)
+		}
+		return n, nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("expected int, got %v", v)
+		}
+		return int64(v), nil
+	default:
+		return 0, typeError(TypeInt, value)
+	}
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("expected float, got %s", v)
+		}
+		return f, nil
+	case float64:
+		return v, nil
+	default:
+		return 0, typeError(TypeFloat, value)
+	}
+}
+
+func toTimestamp(value any) (time.Time, error) {
+	v, ok := value.(string)
+	if !ok {
+		return time.Time{}, typeError(TypeTimestamp, value)
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp, got %q", v)
+	}
+	return t.UTC(), nil
+}
+
+func toArray(value any, items Type) ([]string, error) {
+	v, ok := value.([]any)
+	if !ok {
+		return nil, typeError(TypeArray, value)
+	}
+	if items == "" {
+		items = TypeString
+	}
+
+	out := make([]string, len(v))
+	for i, item := range v {
+		var tmp domain.TypedProperties
+		if err := set(&tmp, "", Property{Type: items}, item); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		switch items {
+		case TypeTimestamp:
+			out[i] = tmp.Timestamp[""].Format(time.RFC3339Nano)
+		case TypeInt:
+			out[i] = strconv.FormatInt(tmp.Int[""], 10)
+		default:
+			out[i] = stringify(item)
+		}
+	}
+	return out, nil
+}
+
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func typeError(expected Type, value any) error {
+	return fmt.Errorf("expected %s, got %s", expected, jsonType(value))
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func propertyField(name string) string {
+	return "properties." + name
+}
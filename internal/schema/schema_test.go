@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func TestToInt(t *testing.T) {
+	cases := map[string]struct {
+		value    any
+		expected int64
+		err      string
+	}{
+		"float":               {value: float64(42), expected: 42},
+		"negative float":      {value: float64(math.MinInt64), expected: math.MinInt64},
+		"float 2^63":          {value: math.Pow(2, 63), err: "expected int, got 9.223372036854776e+18"},
+		"fraction":            {value: 1.5, err: "expected int, got 1.5"},
+		"number above 2^53":   {value: json.Number("9007199254740993"), expected: 9007199254740993},
+		"number max":          {value: json.Number("9223372036854775807"), expected: math.MaxInt64},
+		"number out of range": {value: json.Number("9223372036854775808"), err: "expected int, got 9223372036854775808"},
+		"number fraction":     {value: json.Number("1.5"), err: "expected int, got 1.5"},
+		"string":              {value: "1", err: "expected int, got string"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := toInt(tc.value)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestInfer(t *testing.T) {
+	event := &domain.Event{Properties: map[string]any{
+		"screen":  "main",
+		"count":   json.Number("3"),
+		"ratio":   0.5,
+		"premium": true,
+		"tags":    []any{"a", "b"},
+		"empty":   nil,
+	}}
+	Infer(event)
+
+	require.Nil(t, event.Properties)
+	require.Equal(t, domain.TypedProperties{
+		String: map[string]string{"screen": "main", "premium": "true", "tags": `["a","b"]`},
+		Float:  map[string]float64{"count": 3, "ratio": 0.5},
+	}, event.TypedProperties)
+}
+
+func TestEvent_Apply_Columns(t *testing.T) {
+	properties := func() map[string]any {
+		return map[string]any{"count": json.Number("3"), "premium": true, "screen": "main"}
+	}
+
+	// Declared properties use the column of their type.
+	declared := Event{Name: "purchase", Properties: map[string]Property{
+		"count":   {Type: TypeInt},
+		"premium": {Type: TypeBool},
+	}, AdditionalProperties: true}
+	event := &domain.Event{Properties: properties()}
+	require.Empty(t, declared.apply(event))
+	require.Equal(t, domain.TypedProperties{
+		String: map[string]string{"screen": "main"},
+		Int:    map[string]int64{"count": 3},
+		Bool:   map[string]bool{"premium": true},
+	}, event.TypedProperties)
+
+	// The same properties without a declaration only use String and Float.
+	event = &domain.Event{Properties: properties()}
+	Infer(event)
+	require.Equal(t, domain.TypedProperties{
+		String: map[string]string{"screen": "main", "premium": "true"},
+		Float:  map[string]float64{"count": 3},
+	}, event.TypedProperties)
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+	"github.com/leshachaplin/datalog/internal/erasure"
+)
+
+const (
+	bearerPrefix = "Bearer "
+	// maxAdminBodySize limits the bodies of admin requests, which are small JSON
+	// objects.
+	maxAdminBodySize = 64 << 10
+)
+
+// Eraser erases the events of devices.
+type Eraser interface {
+	Erase(ctx context.Context, req erasure.Request) (erasure.Erasure, error)
+	Erasure(ctx context.Context, id string) (erasure.Erasure, error)
+}
+
+// SetEraser serves erasures on the admin API.
+func (h *Handler) SetEraser(eraser Eraser) {
+	h.eraser = eraser
+}
+
+// AuthenticateAdmin rejects requests without one of the tokens as a bearer token
+// in the Authorization header.
+func (h *Handler) AuthenticateAdmin(tokens []string) func(http.Handler) http.Handler {
+	digests := make([][sha256.Size]byte, 0, len(tokens))
+	for _, token := range tokens {
+		digests = append(digests, sha256.Sum256([]byte(token)))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+			if !ok || token == "" {
+				h.error(apierror.NewAPIError("admin token is required", http.StatusUnauthorized), w)
+				return
+			}
+
+			// Every token is compared, so that timing does not tell which one is close.
+			digest := sha256.Sum256([]byte(token))
+			valid := 0
+			for i := range digests {
+				valid |= subtle.ConstantTimeCompare(digest[:], digests[i][:])
+			}
+			if valid == 0 {
+				h.error(apierror.NewAPIError("admin token is not valid", http.StatusUnauthorized), w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Erase starts erasing the events of a device. The erasure is returned running,
+// and GET /admin/v1/erasures/{id} tells when it is done.
+func (h *Handler) Erase(w http.ResponseWriter, r *http.Request) {
+	var req erasure.Request
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		h.error(apierror.NewAPIError("invalid erasure request: "+err.Error(), http.StatusBadRequest), w)
+		return
+	}
+
+	result, err := h.eraser.Erase(r.Context(), req)
+	switch {
+	case errors.Is(err, erasure.ErrInvalid):
+		h.error(apierror.NewAPIError(err.Error(), http.StatusBadRequest), w)
+		return
+	case err != nil && result.ID == "":
+		h.error(err, w)
+		return
+	case err != nil:
+		// The failure is recorded, so that the erasure can be looked up.
+		h.logger.Error().Err(err).Str("erasure", result.ID).Msg("Erasure failed.")
+	}
+
+	if err = encodeJSONResponse(w, http.StatusAccepted, result); err != nil {
+		h.logger.Error().Err(err).Send()
+	}
+}
+
+// Erasure reports an erasure.
+func (h *Handler) Erasure(w http.ResponseWriter, r *http.Request) {
+	result, err := h.eraser.Erasure(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, erasure.ErrNotFound) {
+		h.error(apierror.NewAPIError(err.Error(), http.StatusNotFound), w)
+		return
+	}
+	if err != nil {
+		h.error(err, w)
+		return
+	}
+
+	if err = encodeJSONResponse(w, http.StatusOK, result); err != nil {
+		h.logger.Error().Err(err).Send()
+	}
+}
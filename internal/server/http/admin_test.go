@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/erasure"
+)
+
+type stubEraser struct {
+	requests []erasure.Request
+	err      error
+}
+
+func (s *stubEraser) Erase(_ context.Context, req erasure.Request) (erasure.Erasure, error) {
+	s.requests = append(s.requests, req)
+	if s.err != nil {
+		return erasure.Erasure{}, s.err
+	}
+	return erasure.Erasure{ID: "e1", DeviceID: req.DeviceID, Status: erasure.StatusRunning}, nil
+}
+
+func (s *stubEraser) Erasure(_ context.Context, id string) (erasure.Erasure, error) {
+	if id != "e1" {
+		return erasure.Erasure{}, erasure.ErrNotFound
+	}
+	return erasure.Erasure{ID: "e1", DeviceID: "d1", Status: erasure.StatusDone}, nil
+}
+
+func TestHandler_AuthenticateAdmin(t *testing.T) {
+	cases := map[string]struct {
+		header         string
+		expectedStatus int
+	}{
+		"valid":       {header: "Bearer second", expectedStatus: http.StatusNoContent},
+		"missing":     {expectedStatus: http.StatusUnauthorized},
+		"not bearer":  {header: "Basic second", expectedStatus: http.StatusUnauthorized},
+		"unknown":     {header: "Bearer third", expectedStatus: http.StatusUnauthorized},
+		"empty token": {header: "Bearer ", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := NewHandler(Config{}, &stubProcessor{}, zerolog.Nop())
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/erasures/e1", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.AuthenticateAdmin([]string{"first", "second"})(next).ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestServer_Erasures(t *testing.T) {
+	eraser := &stubEraser{}
+	h := NewHandler(Config{}, &stubProcessor{}, zerolog.Nop())
+	h.SetEraser(eraser)
+	s := New(h)
+	s.EnableAdmin(h.AuthenticateAdmin([]string{"token"}))
+	s.registerPublicRoutes()
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		s.publicRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/admin/v1/erasures", `{"device_id":"d1","hashed":true,"requested_by":"dpo"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.JSONEq(t, `{"id":"e1","device_id":"d1","requested_by":"","tables":null,"status":"running","reissued":false,
+		"requested_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`, rec.Body.String())
+	require.Equal(t, []erasure.Request{{DeviceID: "d1", Hashed: true, RequestedBy: "dpo"}}, eraser.requests)
+
+	rec = do(http.MethodPost, "/admin/v1/erasures", `{"device":"d1"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	eraser.err = errors.Join(erasure.ErrInvalid, errors.New("requested_by is required"))
+	rec = do(http.MethodPost, "/admin/v1/erasures", `{"device_id":"d1"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(http.MethodGet, "/admin/v1/erasures/e1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"status":"done"`)
+
+	rec = do(http.MethodGet, "/admin/v1/erasures/e2", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
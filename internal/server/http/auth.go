@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+	"github.com/leshachaplin/datalog/internal/auth"
+)
+
+const (
+	keyHeader = "X-Datalog-Key"
+	// keyParam carries the key of clients that cannot set headers, such as
+	// navigator.sendBeacon.
+	keyParam = "api_key"
+)
+
+// KeyStore maps API keys to project IDs.
+type KeyStore interface {
+	Project(key string) (string, bool)
+}
+
+// Authenticate rejects requests without a known API key and puts the key and its
+// project ID into the request context.
+func (h *Handler) Authenticate(keys KeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(keyHeader)
+			if key == "" {
+				key = r.URL.Query().Get(keyParam)
+			}
+			if key == "" {
+				h.error(apierror.NewAPIError("API key is required", http.StatusUnauthorized), w)
+				return
+			}
+
+			projectID, ok := keys.Project(key)
+			if !ok {
+				h.error(apierror.NewAPIError("API key is not valid", http.StatusUnauthorized), w)
+				return
+			}
+			ctx := auth.WithProject(auth.WithKey(r.Context(), key), projectID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/auth"
+)
+
+type keyMap map[string]string
+
+func (k keyMap) Project(key string) (string, bool) {
+	projectID, ok := k[key]
+	return projectID, ok
+}
+
+func TestHandler_Authenticate(t *testing.T) {
+	cases := map[string]struct {
+		target          string
+		header          string
+		expectedStatus  int
+		expectedProject string
+	}{
+		"header": {
+			target:          "/v1/event",
+			header:          "shop-key",
+			expectedStatus:  http.StatusNoContent,
+			expectedProject: "shop",
+		},
+		"query parameter": {
+			target:          "/v1/event?api_key=shop-key",
+			expectedStatus:  http.StatusNoContent,
+			expectedProject: "shop",
+		},
+		"missing": {
+			target:         "/v1/event",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unknown": {
+			target:         "/v1/event",
+			header:         "other-key",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := NewHandler(Config{}, &stubProcessor{}, zerolog.Nop())
+
+			var project string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				project = auth.Project(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+			if tc.header != "" {
+				req.Header.Set(keyHeader, tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.Authenticate(keyMap{"shop-key": "shop"})(next).ServeHTTP(rec, req)
+
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			require.Equal(t, tc.expectedProject, project)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses addresses and CIDR prefixes of proxies whose forwarding
+// headers are believed.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// clientIP returns the address of the client. Forwarding headers are only believed
+// when the peer is a trusted proxy; then the hops are walked from the nearest one,
+// right to left, past trusted proxies, so that a client cannot spoof its address by
+// sending the headers itself. Forwarded (RFC 7239) takes precedence over
+// X-Forwarded-For. IPv4-mapped IPv6 addresses are returned as IPv4. The address is
+// invalid when the peer address cannot be parsed.
+func (h *Handler) clientIP(r *http.Request) netip.Addr {
+	addr, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return netip.Addr{}
+	}
+
+	var hops []string
+	if h.trusted(addr) {
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+		if hops == nil {
+			hops = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0 && h.trusted(addr); i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// The hop is obfuscated or malformed, so the nearest known address is
+			// the last trusted proxy.
+			break
+		}
+		addr = hop
+	}
+	return addr
+}
+
+func (h *Handler) trusted(addr netip.Addr) bool {
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for= parameters of Forwarded header values, or nil when
+// there are none.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(v, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+func xForwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address with an optional port, and IPv6 addresses in
+// brackets, as in RemoteAddr and forwarding headers.
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return normalize(addrPort.Addr()), true
+	}
+
+	hop = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+	addr, err := netip.ParseAddr(hop)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return normalize(addr), true
+}
+
+func normalize(addr netip.Addr) netip.Addr {
+	return addr.WithZone("").Unmap()
+}
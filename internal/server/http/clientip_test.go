@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_ClientIP(t *testing.T) {
+	cases := map[string]struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		"peer address": {
+			remoteAddr: "203.0.113.7:51234",
+			expected:   "203.0.113.7",
+		},
+		"untrusted peer cannot spoof": {
+			remoteAddr: "203.0.113.7:51234",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			expected:   "203.0.113.7",
+		},
+		"behind the load balancer": {
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.4"},
+			expected:   "198.51.100.4",
+		},
+		"spoofed hop left of the client": {
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.4, 10.0.0.3"},
+			expected:   "198.51.100.4",
+		},
+		"only trusted hops": {
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.4, 10.0.0.3"},
+			expected:   "10.0.0.4",
+		},
+		"malformed hop": {
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.4, garbage"},
+			expected:   "10.0.0.2",
+		},
+		"forwarded takes precedence": {
+			remoteAddr: "10.0.0.2:443",
+			headers: map[string]string{
+				"Forwarded":       `for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711";by=10.0.0.2`,
+				"X-Forwarded-For": "198.51.100.4",
+			},
+			expected: "2001:db8:cafe::17",
+		},
+		"forwarded unknown": {
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"Forwarded": "for=unknown"},
+			expected:   "10.0.0.2",
+		},
+		"ipv6 peer": {
+			remoteAddr: "[2001:db8::1%eth0]:443",
+			expected:   "2001:db8::1",
+		},
+		"ipv4-mapped": {
+			remoteAddr: "[::ffff:203.0.113.7]:443",
+			expected:   "203.0.113.7",
+		},
+		"ipv6 proxy and client": {
+			remoteAddr: "[fd00::2]:443",
+			headers:    map[string]string{"X-Forwarded-For": "2001:db8::5, fd00::9"},
+			expected:   "2001:db8::5",
+		},
+		"invalid peer": {
+			remoteAddr: "pipe",
+			expected:   "invalid IP",
+		},
+	}
+
+	h := NewHandler(Config{TrustedProxies: []string{"10.0.0.0/8", "fd00::/8"}}, &stubProcessor{}, zerolog.Nop())
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/event", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			require.Equal(t, tc.expected, h.clientIP(req).String())
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies([]string{"10.1.2.3/8", "192.0.2.1", "::ffff:192.0.2.2", "fd00::/8"})
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.0/8", prefixes[0].String())
+	require.Equal(t, "192.0.2.1/32", prefixes[1].String())
+	require.Equal(t, "192.0.2.2/32", prefixes[2].String())
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
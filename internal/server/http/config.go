@@ -0,0 +1,28 @@
+package http
+
+import "time"
+
+const (
+	defaultSyncTimeout = 4 * time.Second
+	defaultRetryAfter  = time.Second
+)
+
+type Config struct {
+	// SyncAck makes POST /v1/event wait until the events are queued before replying.
+	// Clients can choose per request with the X-Datalog-Ack header.
+	SyncAck bool `mapstructure:"sync_ack"`
+	// SyncTimeout bounds the wait in the synchronous mode.
+	SyncTimeout time.Duration `mapstructure:"sync_timeout"`
+	// MaxBodySize limits POST /v1/event bodies in bytes, both as received and after
+	// Content-Encoding is decoded. 10 MiB by default.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+	// RetryAfter is sent with 429 and 503 responses. 1s by default.
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+	// TrustedProxies are the addresses and CIDR prefixes of the load balancers and
+	// proxies in front of the server. Their Forwarded and X-Forwarded-For headers
+	// are used to find the client address; without any, the peer address is used.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// AdminTokens are the bearer tokens of the admin API. Without any, the admin
+	// API is not served.
+	AdminTokens []string `mapstructure:"admin_tokens"`
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+)
+
+const defaultMaxBodySize = 10 << 20
+
+var errBodyTooLarge = errors.New("request body is too large")
+
+// openBody returns the request body, decoded according to Content-Encoding as it is
+// read. Codings are undone in the reverse order of the header. maxSize bounds both
+// the body on the wire and the decoded body, so a small compressed payload cannot
+// expand without limit. Read errors are apierror values.
+func openBody(r *http.Request, maxSize int64) (io.ReadCloser, error) {
+	b := &body{maxSize: maxSize}
+	var decoded io.Reader = &limitReader{r: r.Body, n: maxSize}
+
+	codings := contentCodings(r.Header.Get("Content-Encoding"))
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoder, err := newDecoder(codings[i], decoded, maxSize)
+		if errors.Is(err, errBodyTooLarge) {
+			_ = b.Close()
+			return nil, bodyTooLargeError(maxSize)
+		}
+		if err != nil {
+			_ = b.Close()
+			return nil, err
+		}
+		if c, ok := decoder.(io.Closer); ok {
+			b.closers = append(b.closers, c)
+		}
+		decoded = decoder
+	}
+
+	b.r = &limitReader{r: decoded, n: maxSize}
+	b.codings = strings.Join(codings, ", ")
+	return b, nil
+}
+
+type body struct {
+	r       io.Reader
+	codings string
+	maxSize int64
+	closers []io.Closer
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == nil || errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	if b.codings != "" {
+		err = decodeError(b.codings, err)
+	}
+	if errors.Is(err, errBodyTooLarge) {
+		err = bodyTooLargeError(b.maxSize)
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	for _, c := range b.closers {
+		_ = c.Close()
+	}
+	return nil
+}
+
+// limitReader fails with errBodyTooLarge once more than n bytes are read, unlike
+// io.LimitReader which silently truncates.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+func newDecoder(coding string, r io.Reader, maxSize int64) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		decoder, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, decodeError(coding, err)
+		}
+		return decoder, nil
+	case "deflate":
+		return newDeflateReader(r)
+	case "zstd":
+		// The window is what a frame makes the decoder allocate up front, so it is
+		// bounded by the body limit too.
+		maxWindow := uint64(maxSize)
+		if maxWindow < zstd.MinWindowSize {
+			maxWindow = zstd.MinWindowSize
+		}
+		decoder, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(maxWindow),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	default:
+		apiErr := apierror.NewAPIError(fmt.Sprintf("unsupported content encoding %q", coding), http.StatusUnsupportedMediaType)
+		apiErr.Details = map[string]interface{}{
+			"supported": []string{"gzip", "deflate", "zstd", "br"},
+		}
+		return nil, apiErr
+	}
+}
+
+// newDeflateReader accepts both the zlib stream required by RFC 9110 and the raw
+// deflate stream that some clients send instead.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, decodeError("deflate", err)
+	}
+
+	// A zlib header uses the deflate method (CM = 8) and is a multiple of 31.
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		decoder, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, decodeError("deflate", err)
+		}
+		return decoder, nil
+	}
+	return flate.NewReader(br), nil
+}
+
+// contentCodings splits Content-Encoding and drops identity.
+func contentCodings(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+func decodeError(coding string, err error) error {
+	if errors.Is(err, errBodyTooLarge) {
+		return err
+	}
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return errBodyTooLarge
+	}
+	return apierror.NewAPIError(fmt.Sprintf("could not decode %s body: %v", coding, err), http.StatusBadRequest)
+}
+
+func bodyTooLargeError(maxSize int64) apierror.Error {
+	apiErr := apierror.NewAPIError(errBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+	apiErr.Details = map[string]interface{}{
+		"max_size": maxSize,
+	}
+	return apiErr
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestOpenBody(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"event":"app_open","device_id":"device"}`+"\n", 100))
+
+	cases := map[string]struct {
+		encoding       string
+		body           []byte
+		maxSize        int64
+		expectedStatus int
+	}{
+		"identity":             {body: payload},
+		"explicit identity":    {encoding: "identity", body: payload},
+		"gzip":                 {encoding: "gzip", body: compress(t, "gzip", payload)},
+		"deflate":              {encoding: "deflate", body: compress(t, "deflate", payload)},
+		"raw deflate":          {encoding: "deflate", body: compress(t, "raw-deflate", payload)},
+		"zstd":                 {encoding: "zstd", body: compress(t, "zstd", payload)},
+		"brotli":               {encoding: "br", body: compress(t, "br", payload)},
+		"case insensitive":     {encoding: "GZIP", body: compress(t, "gzip", payload)},
+		"stacked codings":      {encoding: "gzip, zstd", body: compress(t, "zstd", compress(t, "gzip", payload))},
+		"unsupported encoding": {encoding: "compress", body: payload, expectedStatus: http.StatusUnsupportedMediaType},
+		"corrupt gzip":         {encoding: "gzip", body: []byte("not gzip"), expectedStatus: http.StatusBadRequest},
+		"raw body too large": {
+			body:           payload,
+			maxSize:        int64(len(payload) - 1),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"decompressed body too large": {
+			encoding:       "gzip",
+			body:           compress(t, "gzip", payload),
+			maxSize:        int64(len(payload) - 1),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"zip bomb": {
+			encoding:       "zstd",
+			body:           compress(t, "zstd", make([]byte, 64<<20)),
+			maxSize:        1 << 20,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/event", bytes.NewReader(tc.body))
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			maxSize := tc.maxSize
+			if maxSize == 0 {
+				maxSize = defaultMaxBodySize
+			}
+
+			body, err := openBody(req, maxSize)
+			var data []byte
+			if err == nil {
+				data, err = io.ReadAll(body)
+				require.NoError(t, body.Close())
+			}
+			if tc.expectedStatus == 0 {
+				require.NoError(t, err)
+				require.Equal(t, payload, data)
+				return
+			}
+
+			var apiErr apierror.Error
+			require.ErrorAs(t, err, &apiErr)
+			require.Equal(t, tc.expectedStatus, apiErr.StatusCode())
+		})
+	}
+}
@@ -1,19 +1,164 @@
 package http
 
 import (
-	"bytes"
-	"io"
+	"context"
+	"errors"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+	"github.com/leshachaplin/datalog/internal/auth"
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/service"
+)
+
+const (
+	ackHeader = "X-Datalog-Ack"
+	ackSync   = "sync"
+	ackAsync  = "async"
 )
 
 func (h *Handler) Event(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
+	sync := h.isSync(r)
+	if !sync && h.eventProcessor.QueueStats().Saturated() {
+		h.error(apierror.NewAPIError(service.ErrSaturated.Error(), http.StatusTooManyRequests), w)
+		return
+	}
+
+	body, err := openBody(r, h.maxBodySize)
 	if err != nil {
 		h.error(err, w)
 		return
 	}
-	buf := bytes.NewBuffer(data)
-	go h.eventProcessor.ProcessEvent(buf, getClientIP(r), time.Now())
-	w.WriteHeader(http.StatusAccepted)
+	defer body.Close()
+	format := requestFormat(r)
+	origin := service.Origin{
+		ProjectID:      auth.Project(r.Context()),
+		IP:             h.clientIP(r),
+		ServerTime:     time.Now(),
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+	}
+
+	if !sync {
+		result, err := h.eventProcessor.ProcessEvent(r.Context(), body, format, origin)
+		chargeEvents(r.Context(), result)
+		if err != nil {
+			h.error(processError(err, result), w)
+			return
+		}
+		switch {
+		case allRejected(result):
+			h.error(rejectedError(result), w)
+		case len(result.Rejected) > 0:
+			// Accepted lines are queued, so a partial success is not an error:
+			// clients resending the whole body on a 4xx would duplicate them.
+			if err = encodeJSONResponse(w, http.StatusAccepted, result); err != nil {
+				h.logger.Error().Err(err).Send()
+			}
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.syncTimeout)
+	defer cancel()
+
+	result, err := h.eventProcessor.ProcessEventSync(ctx, body, format, origin)
+	chargeEvents(r.Context(), result)
+	switch {
+	case err != nil:
+		h.error(processError(err, result), w)
+		return
+	case allRejected(result):
+		h.error(rejectedError(result), w)
+		return
+	}
+
+	if err = encodeJSONResponse(w, http.StatusOK, result); err != nil {
+		h.logger.Error().Err(err).Send()
+	}
+}
+
+// isSync reports whether the client waits for its events to be queued. The
+// X-Datalog-Ack header overrides the configured mode.
+func (h *Handler) isSync(r *http.Request) bool {
+	switch strings.ToLower(r.Header.Get(ackHeader)) {
+	case ackSync:
+		return true
+	case ackAsync:
+		return false
+	default:
+		return h.syncAck
+	}
+}
+
+// requestFormat picks the decoder by Content-Type. Other types, text/plain sent by
+// navigator.sendBeacon among them, are detected from the body: a JSON array or
+// NDJSON, which is what clients sent before the header was looked at.
+func requestFormat(r *http.Request) service.Format {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return service.FormatAuto
+	}
+
+	switch mediaType {
+	case domain.ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return service.FormatProtobuf
+	case "application/json":
+		return service.FormatJSON
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return service.FormatNDJSON
+	default:
+		return service.FormatAuto
+	}
+}
+
+// processError turns a failure to read or queue the body into an API error. Chunks
+// queued before the failure are listed in Details, so that clients do not resend
+// them.
+func processError(err error, result service.Result) error {
+	var apiErr apierror.Error
+	switch {
+	case errors.Is(err, service.ErrSaturated):
+		apiErr = apierror.NewAPIError(err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, service.ErrQueueUnavailable), errors.Is(err, service.ErrStopped):
+		apiErr = apierror.NewAPIError(err.Error(), http.StatusServiceUnavailable)
+	case errors.As(err, &apiErr):
+	default:
+		return err
+	}
+
+	if len(result.Accepted)+len(result.Quarantined) == 0 {
+		return apiErr
+	}
+	details := map[string]interface{}{
+		"accepted": result.Accepted,
+	}
+	if len(result.Quarantined) > 0 {
+		details["quarantined"] = result.Quarantined
+	}
+	for k, v := range apiErr.Details {
+		details[k] = v
+	}
+	apiErr.Details = details
+	return apiErr
+}
+
+func allRejected(result service.Result) bool {
+	return len(result.Accepted)+len(result.Quarantined) == 0 && len(result.Rejected) > 0
+}
+
+// rejectedError carries the validation report of a body whose lines were all
+// rejected in Details.
+func rejectedError(result service.Result) apierror.Error {
+	apiErr := apierror.NewAPIError("all events were rejected", http.StatusBadRequest)
+	apiErr.Details = map[string]interface{}{
+		"accepted": result.Accepted,
+		"rejected": result.Rejected,
+	}
+	return apiErr
 }
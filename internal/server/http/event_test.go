@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/service"
+)
+
+type stubProcessor struct {
+	result service.Result
+	err    error
+	stats  service.QueueStats
+	async  chan struct{}
+}
+
+func (s *stubProcessor) ProcessEvent(context.Context, io.Reader, service.Format, service.Origin) (service.Result, error) {
+	close(s.async)
+	return s.result, s.err
+}
+
+func (s *stubProcessor) ProcessEventSync(context.Context, io.Reader, service.Format, service.Origin) (service.Result, error) {
+	return s.result, s.err
+}
+
+func (s *stubProcessor) QueueStats() service.QueueStats {
+	return s.stats
+}
+
+func TestHandler_Event(t *testing.T) {
+	cases := map[string]struct {
+		cfg            Config
+		ackHeader      string
+		processor      *stubProcessor
+		expectedStatus int
+		expectedBody   string
+	}{
+		"async by default": {
+			processor:      &stubProcessor{},
+			expectedStatus: http.StatusAccepted,
+		},
+		"async with rejected lines": {
+			processor: &stubProcessor{result: service.Result{
+				Accepted: []int{1},
+				Rejected: []service.RejectedLine{{Line: 2, Field: "device_id", Code: domain.CodeRequired, Reason: "is required"}},
+			}},
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   `{"accepted":[1],"rejected":[{"line":2,"field":"device_id","code":"required","reason":"is required"}]}`,
+		},
+		"async all rejected": {
+			processor: &stubProcessor{result: service.Result{
+				Accepted: []int{},
+				Rejected: []service.RejectedLine{{Line: 1, Field: "device_id", Code: domain.CodeRequired, Reason: "is required"}},
+			}},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{
+				"message": "all events were rejected",
+				"details": {
+					"accepted": [],
+					"rejected": [{"line": 1, "field": "device_id", "code": "required", "reason": "is required"}]
+				},
+				"http": {"code": 400, "message": "Bad Request"}
+			}`,
+		},
+		"async requested over sync config": {
+			cfg:            Config{SyncAck: true},
+			ackHeader:      "async",
+			processor:      &stubProcessor{},
+			expectedStatus: http.StatusAccepted,
+		},
+		"sync requested by header": {
+			ackHeader: "sync",
+			processor: &stubProcessor{result: service.Result{
+				BatchID:  "device",
+				Accepted: []int{1, 3},
+				Rejected: []service.RejectedLine{{Line: 2, Code: domain.CodeInvalidJSON, Reason: "invalid character"}},
+			}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"batch_id":"device","accepted":[1,3],"rejected":[{"line":2,"code":"invalid_json","reason":"invalid character"}]}`,
+		},
+		"sync queue unavailable": {
+			cfg:            Config{SyncAck: true},
+			processor:      &stubProcessor{err: service.ErrQueueUnavailable},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		"async queue full": {
+			processor:      &stubProcessor{stats: service.QueueStats{Depth: 4, Capacity: 4}},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"async turned away while decoding": {
+			processor:      &stubProcessor{err: service.ErrSaturated, result: service.Result{Accepted: []int{1}}},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"sync nothing decoded": {
+			cfg: Config{SyncAck: true},
+			processor: &stubProcessor{result: service.Result{
+				Accepted: []int{},
+				Rejected: []service.RejectedLine{{Line: 1, Reason: "unexpected end of JSON input"}},
+			}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tc.processor.async = make(chan struct{})
+			h := NewHandler(tc.cfg, tc.processor, zerolog.Nop())
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/event", strings.NewReader(`{"event":"app_open"}`))
+			if tc.ackHeader != "" {
+				req.Header.Set(ackHeader, tc.ackHeader)
+			}
+			rec := httptest.NewRecorder()
+			h.Event(rec, req)
+
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			switch tc.expectedStatus {
+			case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+				require.Equal(t, "1", rec.Header().Get("Retry-After"))
+			}
+			if tc.expectedStatus == http.StatusAccepted {
+				select {
+				case <-tc.processor.async:
+				case <-time.After(time.Second):
+					t.Fatal("events were not processed")
+				}
+				if tc.expectedBody == "" {
+					require.Empty(t, rec.Body.String())
+					return
+				}
+			}
+			if tc.expectedBody != "" {
+				require.JSONEq(t, tc.expectedBody, rec.Body.String())
+				return
+			}
+
+			var apiErr map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+			require.Contains(t, apiErr, "http")
+		})
+	}
+}
+
+func TestRequestFormat(t *testing.T) {
+	cases := map[string]service.Format{
+		"":                                  service.FormatAuto,
+		"application/x-ndjson":              service.FormatNDJSON,
+		"application/json; charset=utf-8":   service.FormatJSON,
+		"text/plain;charset=UTF-8":          service.FormatAuto,
+		"application/x-protobuf":            service.FormatProtobuf,
+		"application/protobuf; proto=x":     service.FormatProtobuf,
+		"application/x-www-form-urlencoded": service.FormatAuto,
+	}
+
+	for contentType, expected := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/event", nil)
+		req.Header.Set("Content-Type", contentType)
+		require.Equal(t, expected, requestFormat(req), contentType)
+	}
+}
+
+func TestHandler_Ready(t *testing.T) {
+	processor := &stubProcessor{stats: service.QueueStats{Depth: 1, Capacity: 2}}
+	h := NewHandler(Config{}, processor, zerolog.Nop())
+
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/_/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	processor.stats.Depth = 2
+	rec = httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/_/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/netip"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -12,12 +14,41 @@ import (
 )
 
 type Handler struct {
+	syncAck        bool
+	syncTimeout    time.Duration
+	maxBodySize    int64
+	retryAfter     time.Duration
+	trustedProxies []netip.Prefix
 	eventProcessor service.Event
+	eraser         Eraser
 	logger         zerolog.Logger
 }
 
-func NewHandler(eventProcessor service.Event, logger zerolog.Logger) *Handler {
+func NewHandler(cfg Config, eventProcessor service.Event, logger zerolog.Logger) *Handler {
+	syncTimeout := cfg.SyncTimeout
+	if syncTimeout <= 0 {
+		syncTimeout = defaultSyncTimeout
+	}
+	maxBodySize := cfg.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	retryAfter := cfg.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	// The list is checked by config validation.
+	trustedProxies, err := ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Error().Err(err).Msg("Ignoring trusted proxies.")
+	}
+
 	return &Handler{
+		syncAck:        cfg.SyncAck,
+		syncTimeout:    syncTimeout,
+		maxBodySize:    maxBodySize,
+		retryAfter:     retryAfter,
+		trustedProxies: trustedProxies,
 		eventProcessor: eventProcessor,
 		logger:         logger,
 	}
@@ -31,8 +62,31 @@ func (h *Handler) error(err error, w http.ResponseWriter) {
 		apiErr = apierror.NewAPIError(err.Error(), http.StatusInternalServerError)
 	}
 
+	switch apiErr.StatusCode() {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if w.Header().Get("Retry-After") == "" {
+			setSeconds(w.Header(), "Retry-After", h.retryAfter)
+		}
+	}
 	w.WriteHeader(apiErr.StatusCode())
 	if err = json.NewEncoder(w).Encode(apiErr); err != nil {
 		h.logger.Error().Err(err).Send() // TODO improve logging
 	}
 }
+
+// Ready fails while the ingestion queue is full, so that load balancers send traffic
+// to other instances.
+func (h *Handler) Ready(w http.ResponseWriter, _ *http.Request) {
+	if stats := h.eventProcessor.QueueStats(); stats.Saturated() {
+		h.error(apierror.NewAPIError(service.ErrSaturated.Error(), http.StatusServiceUnavailable), w)
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+// Queue reports the depth of the ingestion queue.
+func (h *Handler) Queue(w http.ResponseWriter, _ *http.Request) {
+	if err := encodeJSONResponse(w, http.StatusOK, h.eventProcessor.QueueStats()); err != nil {
+		h.logger.Error().Err(err).Send()
+	}
+}
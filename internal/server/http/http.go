@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"expvar"
 	"net/http"
 	"time"
 
@@ -13,6 +14,8 @@ type Server struct {
 	publicRouter *chi.Mux
 
 	handler *Handler
+	// admin guards the admin API, which is served once it is set.
+	admin []func(http.Handler) http.Handler
 }
 
 func New(handler *Handler) *Server {
@@ -23,6 +26,12 @@ func New(handler *Handler) *Server {
 	}
 }
 
+// EnableAdmin serves the admin API behind the middlewares, which must include
+// authentication.
+func (s *Server) EnableAdmin(middlewares ...func(http.Handler) http.Handler) {
+	s.admin = middlewares
+}
+
 func (s *Server) ServePublic(addr string, mws ...func(http.Handler) http.Handler) error {
 	s.registerPublicRoutes(mws...)
 
@@ -43,13 +52,23 @@ func (s *Server) ShutdownPublic(ctx context.Context) error {
 	return nil
 }
 
+// registerPublicRoutes applies the middlewares to the API only, so that load
+// balancers can check the instance without an API key.
 func (s *Server) registerPublicRoutes(middlewares ...func(http.Handler) http.Handler) {
-	s.publicRouter.Use(middlewares...)
-	s.publicRouter.Get("/_/ready", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("OK"))
-	})
+	s.publicRouter.Get("/_/ready", s.handler.Ready)
+	s.publicRouter.Get("/_/queue", s.handler.Queue)
+	s.publicRouter.Handle("/_/vars", expvar.Handler())
 
 	s.publicRouter.Route("/v1", func(r chi.Router) {
+		r.Use(middlewares...)
 		r.Post("/event", s.handler.Event)
 	})
+
+	if s.admin != nil {
+		s.publicRouter.Route("/admin/v1", func(r chi.Router) {
+			r.Use(s.admin...)
+			r.Post("/erasures", s.handler.Erase)
+			r.Get("/erasures/{id}", s.handler.Erasure)
+		})
+	}
 }
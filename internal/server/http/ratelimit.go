@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/leshachaplin/datalog/internal/apierror"
+	"github.com/leshachaplin/datalog/internal/auth"
+	"github.com/leshachaplin/datalog/internal/ratelimit"
+	"github.com/leshachaplin/datalog/internal/service"
+)
+
+// RateLimiter decides whether a request is served and is told how many events it
+// carried.
+type RateLimiter interface {
+	Allow(key, ip string) ratelimit.Decision
+	AllowKey(key string) ratelimit.Decision
+	Charge(key, ip string, events int)
+}
+
+type chargeKey struct{}
+
+// RateLimit turns requests away with 429 once a per-IP or global limit is reached,
+// and sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of
+// the tightest request limit. It comes before Authenticate, so that requests with
+// missing or guessed keys are limited too; RateLimitKey applies the per-key limits
+// after it.
+func (h *Handler) RateLimit(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := h.clientIP(r).String()
+			if !h.applyLimit(w, limiter.Allow("", ip)) {
+				return
+			}
+
+			charge := func(key string, events int) {
+				limiter.Charge(key, ip, events)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargeKey{}, charge)))
+		})
+	}
+}
+
+// RateLimitKey turns requests away with 429 once the limit of their API key is
+// reached. It comes after Authenticate and RateLimit.
+func (h *Handler) RateLimitKey(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.applyLimit(w, limiter.AllowKey(auth.Key(r.Context()))) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// applyLimit sets the headers of the decision, unless a tighter request limit set
+// them already, and answers 429 when the request is not allowed.
+func (h *Handler) applyLimit(w http.ResponseWriter, d ratelimit.Decision) bool {
+	if d.State != nil {
+		remaining, err := strconv.Atoi(w.Header().Get("RateLimit-Remaining"))
+		if err != nil || d.State.Remaining < remaining {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.State.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.State.Remaining))
+			setSeconds(w.Header(), "RateLimit-Reset", d.State.Reset)
+		}
+	}
+	if d.Allowed {
+		return true
+	}
+
+	setSeconds(w.Header(), "Retry-After", d.RetryAfter)
+	apiErr := apierror.NewAPIError(fmt.Sprintf("%s rate limit exceeded", d.Resource), http.StatusTooManyRequests)
+	apiErr.Details = map[string]interface{}{
+		"scope": d.Scope,
+		"limit": d.Resource,
+	}
+	h.error(apiErr, w)
+	return false
+}
+
+// chargeEvents counts the decoded events of the request against the event limits.
+// Rejected events count too, since decoding them costs as much.
+func chargeEvents(ctx context.Context, result service.Result) {
+	charge, ok := ctx.Value(chargeKey{}).(func(string, int))
+	if !ok {
+		return
+	}
+
+	lines := make(map[int]struct{}, len(result.Rejected))
+	for _, rejected := range result.Rejected {
+		lines[rejected.Line] = struct{}{}
+	}
+	charge(auth.Key(ctx), len(result.Accepted)+len(result.Quarantined)+len(result.Dropped)+len(lines))
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/ratelimit"
+)
+
+func TestHandler_RateLimit(t *testing.T) {
+	h := NewHandler(Config{}, &stubProcessor{}, zerolog.Nop())
+	limiter := ratelimit.New(ratelimit.Config{PerIP: ratelimit.Limit{Requests: ratelimit.Rate{PerSecond: 1}}})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	h.RateLimit(limiter)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/event", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = httptest.NewRecorder()
+	h.RateLimit(limiter)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/event", nil))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.JSONEq(t, `{
+		"message": "requests rate limit exceeded",
+		"details": {"scope": "ip", "limit": "requests"},
+		"http": {"code": 429, "message": "Too Many Requests"}
+	}`, rec.Body.String())
+}
+
+func TestHandler_RateLimit_Authenticate(t *testing.T) {
+	h := NewHandler(Config{}, &stubProcessor{}, zerolog.Nop())
+	limiter := ratelimit.New(ratelimit.Config{
+		PerKey: ratelimit.Limit{Requests: ratelimit.Rate{PerSecond: 1}},
+		PerIP:  ratelimit.Limit{Requests: ratelimit.Rate{PerSecond: 2}},
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := h.RateLimit(limiter)(h.Authenticate(keyMap{"k1": "shop"})(h.RateLimitKey(limiter)(next)))
+
+	do := func(key, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/event", nil)
+		req.Header.Set(keyHeader, key)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("k1", "10.0.0.1")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"), "the key limit is tighter")
+
+	// The key is over its limit.
+	rec = do("k1", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Contains(t, rec.Body.String(), `"scope":"key"`)
+
+	// Guessed keys are limited by IP before they are checked.
+	require.Equal(t, http.StatusUnauthorized, do("guess", "10.0.0.2").Code)
+	require.Equal(t, http.StatusUnauthorized, do("guess", "10.0.0.2").Code)
+	rec = do("guess", "10.0.0.2")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Contains(t, rec.Body.String(), `"scope":"ip"`)
+}
@@ -26,11 +26,12 @@ import (
 
 const (
 	eventTopic        = "event"
+	deadLetterTopic   = "event-dlq"
 	defaultAddrPublic = ":8080"
 )
 
 var (
-	defaultTopics = []string{eventTopic}
+	defaultTopics = []string{eventTopic, deadLetterTopic}
 )
 
 type IntegrationTestSuite struct {
@@ -130,6 +131,12 @@ func (i *IntegrationTestSuite) SetupSuite() {
 				Brokers:       []string{i.broker},
 				Topic:         eventTopic,
 			},
+			DeadLetterProducer: producer.Config{
+				RetryAttempts: 5,
+				RetryDelay:    time.Second,
+				Brokers:       []string{i.broker},
+				Topic:         deadLetterTopic,
+			},
 		}, nil
 	})
 
@@ -2,9 +2,10 @@ package http
 
 import (
 	"encoding/json"
-	"net"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 )
 
 func encodeJSONResponse[T any](w http.ResponseWriter, code int, data T) error {
@@ -17,30 +18,7 @@ func encodeJSONResponse[T any](w http.ResponseWriter, code int, data T) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
-func getClientIP(req *http.Request) string {
-	out, _, err := net.SplitHostPort(req.RemoteAddr)
-	if err != nil {
-		if xoff := req.Header.Get("X-Original-Forwarded-For"); xoff != "" {
-			out = xoff
-		} else {
-			xff := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
-			if len(xff) == 0 {
-				return ""
-			}
-
-			if xff[0] != req.Header.Get("X-Envoy-External-Address") {
-				out = xff[0]
-			}
-		}
-	}
-
-	if ip := net.ParseIP(out); out != "" && ip != nil {
-		if ip.IsLoopback() {
-			return "127.0.0.1"
-		}
-
-		return out
-	}
-
-	return "0.0.0.0"
+// setSeconds sets a header to a duration in whole seconds, rounded up.
+func setSeconds(header http.Header, key string, d time.Duration) {
+	header.Set(key, strconv.Itoa(int(math.Ceil(d.Seconds()))))
 }
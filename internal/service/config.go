@@ -0,0 +1,51 @@
+package service
+
+import "github.com/leshachaplin/datalog/internal/domain"
+
+const (
+	defaultMaxLineSize      = 1 << 20
+	defaultChunkSize        = 500
+	defaultMaxInFlightBytes = 64 << 20
+	defaultIngestWorkers    = 32
+	defaultIngestQueueDepth = 1024
+)
+
+type Config struct {
+	Validation domain.ValidationRules `mapstructure:"validation"`
+	// ClientTime bounds client times against the server time.
+	ClientTime domain.ClientTimeRules `mapstructure:"client_time"`
+	// MaxLineSize limits an NDJSON line, or one event of other formats, in bytes.
+	// 1 MiB by default.
+	MaxLineSize int `mapstructure:"max_line_size"`
+	// ChunkSize is the number of events queued together while a body is read. 500 by
+	// default.
+	ChunkSize int `mapstructure:"chunk_size"`
+	// MaxInFlightBytes bounds the encoded size of the events that all requests hold
+	// until they are queued. 64 MiB by default.
+	MaxInFlightBytes int64 `mapstructure:"max_in_flight_bytes"`
+	// IngestWorkers is the number of goroutines queueing chunks in the asynchronous
+	// mode. 32 by default.
+	IngestWorkers int `mapstructure:"ingest_workers"`
+	// IngestQueueDepth is the number of chunks that can wait for an ingest worker.
+	// Requests are turned away once it is reached. 1024 by default.
+	IngestQueueDepth int `mapstructure:"ingest_queue_depth"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxLineSize <= 0 {
+		c.MaxLineSize = defaultMaxLineSize
+	}
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = defaultChunkSize
+	}
+	if c.MaxInFlightBytes <= 0 {
+		c.MaxInFlightBytes = defaultMaxInFlightBytes
+	}
+	if c.IngestWorkers <= 0 {
+		c.IngestWorkers = defaultIngestWorkers
+	}
+	if c.IngestQueueDepth <= 0 {
+		c.IngestQueueDepth = defaultIngestQueueDepth
+	}
+	return c
+}
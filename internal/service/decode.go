@@ -0,0 +1,495 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/schema"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
+)
+
+// Format is the encoding of a request body.
+type Format int
+
+const (
+	// FormatAuto is a JSON array of events, or NDJSON otherwise. It serves bodies
+	// without a meaningful Content-Type, such as text/plain from sendBeacon.
+	FormatAuto Format = iota
+	// FormatNDJSON is one JSON event per line. A malformed line does not affect the
+	// others.
+	FormatNDJSON
+	// FormatJSON is a JSON array of events, or one or more JSON objects.
+	FormatJSON
+	// FormatProtobuf is a datalog.v1.EventBatch message, see assets/proto/event.proto.
+	FormatProtobuf
+)
+
+var (
+	errTrailingData = errors.New("unexpected data after the array of events")
+	// errElementTooLong stops the JSON decoder in an element longer than MaxLineSize.
+	errElementTooLong = errors.New("element is too long")
+	// errStopped stops the decoders once a chunk could not be queued.
+	errStopped = errors.New("decoding stopped")
+)
+
+// collector sorts decoded events into chunks to queue and to quarantine, and builds
+// the report for the client. A chunk is queued once it holds ChunkSize events, and
+// its lines are reported as accepted once it is handed over to the queue.
+type collector struct {
+	s      *Service
+	ctx    context.Context
+	sync   bool
+	origin Origin
+	// client is parsed once per request, since all of its events share the headers.
+	client domain.Client
+	// stages are the stages of the service followed by its privacy rules.
+	stages pipeline.Chain
+
+	events      chunk
+	quarantined chunk
+	result      Result
+	// err is the error that stopped decoding.
+	err    error
+	logger zerolog.Logger
+}
+
+type chunk struct {
+	batch domain.EventBatch
+	lines []int
+	size  int64
+}
+
+func (s *Service) newCollector(ctx context.Context, sync bool, origin Origin) *collector {
+	var client domain.Client
+	if s.userAgents != nil {
+		client = s.userAgents.Parse(origin.UserAgent, origin.AcceptLanguage)
+	}
+	stages := s.stages
+	if s.privacy != nil {
+		stages = append(stages[:len(stages):len(stages)], s.privacy)
+	}
+	return &collector{
+		s:      s,
+		ctx:    ctx,
+		sync:   sync,
+		origin: origin,
+		client: client,
+		stages: stages,
+		result: Result{
+			Accepted: make([]int, 0),
+			Rejected: make([]RejectedLine, 0),
+		},
+		logger: log.Logger.With().Str("Service", "ProcessEvent").Logger(),
+	}
+}
+
+// decode reads the whole body and queues the remaining chunks. The error is about
+// reading the body or queueing, not about its content.
+func (c *collector) decode(body io.Reader, format Format) error {
+	r := &bodyReader{r: body}
+	br := bufio.NewReader(r)
+	switch format {
+	case FormatProtobuf:
+		c.decodeProtobuf(br)
+	case FormatNDJSON:
+		c.decodeNDJSON(br)
+	default:
+		switch {
+		case firstByte(br) == '[':
+			c.decodeArray(br)
+		case format == FormatJSON:
+			c.decodeValues(br)
+		default:
+			c.decodeNDJSON(br)
+		}
+	}
+
+	// The events of a body that could not be read to the end are not queued, except
+	// for the chunks that already were.
+	if r.err != nil {
+		return r.err
+	}
+	if c.err == nil {
+		c.flush(&c.events, false)
+		c.flush(&c.quarantined, true)
+	}
+	return c.err
+}
+
+// bodyReader keeps the first read error, which the decoders would otherwise report
+// as malformed content.
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// firstByte peeks at the first non-whitespace byte without consuming anything, so
+// that NDJSON line numbers are kept.
+func firstByte(br *bufio.Reader) byte {
+	for n := 1; n <= br.Size(); n++ {
+		b, err := br.Peek(n)
+		if len(b) < n {
+			return 0
+		}
+		switch c := b[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c
+		}
+		if err != nil {
+			return 0
+		}
+	}
+	return 0
+}
+
+// decodeNDJSON reads line by line. Lines longer than MaxLineSize are rejected and
+// skipped without being buffered.
+func (c *collector) decodeNDJSON(br *bufio.Reader) {
+	maxLineSize := c.s.maxLineSize()
+
+	var line []byte
+	for n := 1; c.err == nil; n++ {
+		line = line[:0]
+		tooLong := false
+		var err error
+		for {
+			var part []byte
+			part, err = br.ReadSlice('\n')
+			if !tooLong {
+				if len(line)+len(part) > maxLineSize+1 {
+					tooLong = true
+				} else {
+					line = append(line, part...)
+				}
+			}
+			if !errors.Is(err, bufio.ErrBufferFull) {
+				break
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			c.logger.Err(err).Msg("Failed to read events")
+			c.reject(n, domain.CodeInvalidJSON, err)
+			return
+		}
+
+		switch {
+		case tooLong:
+			c.rejectTooLong(n, maxLineSize)
+		case len(bytes.TrimSpace(line)) > 0:
+			c.addJSON(n, bytes.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// decodeArray decodes the elements of a JSON array one by one. A syntax error or an
+// element longer than MaxLineSize ends the array, since the decoder cannot find the
+// next element after it.
+func (c *collector) decodeArray(r io.Reader) {
+	er := &elementReader{r: r}
+	dec := json.NewDecoder(er)
+	c.limitElement(dec, er)
+	if _, err := dec.Token(); err != nil {
+		c.reject(1, domain.CodeInvalidJSON, err)
+		return
+	}
+
+	i := 0
+	for dec.More() && c.err == nil {
+		i++
+		data, err := c.decodeElement(dec, er)
+		if err != nil {
+			c.rejectElement(i, err)
+			return
+		}
+		c.addJSON(i, data)
+	}
+	if c.err != nil {
+		return
+	}
+
+	c.limitElement(dec, er)
+	if _, err := dec.Token(); err != nil {
+		c.reject(i+1, domain.CodeInvalidJSON, err)
+		return
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		c.reject(i+1, domain.CodeInvalidJSON, errTrailingData)
+	}
+}
+
+// decodeValues decodes a stream of JSON objects, usually a single one.
+func (c *collector) decodeValues(r io.Reader) {
+	er := &elementReader{r: r}
+	dec := json.NewDecoder(er)
+	for i := 1; c.err == nil; i++ {
+		data, err := c.decodeElement(dec, er)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			c.rejectElement(i, err)
+			return
+		}
+		c.addJSON(i, data)
+	}
+}
+
+// decodeElement decodes the next value of dec, reading at most MaxLineSize bytes past
+// the end of the previous one. The decoder buffers a whole value before returning
+// it, so the limit is enforced on what it reads rather than on the result.
+func (c *collector) decodeElement(dec *json.Decoder, er *elementReader) (json.RawMessage, error) {
+	c.limitElement(dec, er)
+	var data json.RawMessage
+	err := dec.Decode(&data)
+	return data, err
+}
+
+// limitElement lets the decoder read MaxLineSize bytes past what it consumed so far.
+// One more byte lets it see the end of a number at the limit.
+func (c *collector) limitElement(dec *json.Decoder, er *elementReader) {
+	er.limit = dec.InputOffset() + int64(c.s.maxLineSize()) + 1
+}
+
+func (c *collector) rejectElement(line int, err error) {
+	if errors.Is(err, errElementTooLong) {
+		c.rejectTooLong(line, c.s.maxLineSize())
+		return
+	}
+	c.logger.Err(err).Int("line", line).Msg("Failed to decode event")
+	c.reject(line, domain.CodeInvalidJSON, err)
+}
+
+// elementReader fails once the bytes read reach limit, which stops the decoder in
+// the middle of an element that is too long instead of buffering all of it.
+type elementReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (e *elementReader) Read(p []byte) (int, error) {
+	if e.read >= e.limit {
+		return 0, errElementTooLong
+	}
+	if int64(len(p)) > e.limit-e.read {
+		p = p[:e.limit-e.read]
+	}
+	n, err := e.r.Read(p)
+	e.read += int64(n)
+	return n, err
+}
+
+func (c *collector) decodeProtobuf(r io.Reader) {
+	var n int
+	err := domain.ReadProto(r, nil, func(i int, event *domain.Event, size int, err error) error {
+		n = i + 1
+		switch {
+		case err != nil:
+			c.logger.Err(err).Int("line", n).Msg("Failed to decode event")
+			c.reject(n, domain.CodeInvalidProto, err)
+		case size > c.s.maxLineSize():
+			c.rejectTooLong(n, c.s.maxLineSize())
+		default:
+			c.add(n, event, size)
+		}
+		if c.err != nil {
+			return errStopped
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopped) {
+		c.logger.Err(err).Msg("Failed to read events")
+		c.reject(n+1, domain.CodeInvalidProto, err)
+	}
+}
+
+func (c *collector) addJSON(line int, data []byte) {
+	if len(data) > c.s.maxLineSize() {
+		c.rejectTooLong(line, c.s.maxLineSize())
+		return
+	}
+
+	event := &domain.Event{}
+	if err := unmarshalEvent(data, event); err != nil {
+		// The event is not logged, since the privacy rules did not run on it.
+		c.logger.Err(err).Int("line", line).Msg("Failed to decode event")
+		c.reject(line, domain.CodeInvalidJSON, err)
+		return
+	}
+	c.add(line, event, len(data))
+}
+
+// unmarshalEvent decodes numbers in properties as json.Number, so that integers
+// above 2^53 keep their value. Invalid JSON is left to json.Unmarshal, which reports
+// it the way clients are used to.
+func unmarshalEvent(data []byte, event *domain.Event) error {
+	if !json.Valid(data) {
+		return json.Unmarshal(data, event)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(event)
+}
+
+// add validates the event, runs the stages and privacy rules on it and puts the events that come out
+// into their chunk. size is the encoded size of the event, the estimate of the
+// memory it holds until it is queued.
+func (c *collector) add(line int, event *domain.Event, size int) {
+	errs := event.Validate(c.s.validation)
+	verdict, schemaErrs := c.s.applySchema(event)
+	errs = append(errs, schemaErrs...)
+	event.FlattenUserProperties()
+	if len(errs) > 0 {
+		// Messages may quote the values of the event, which the privacy rules did not
+		// run on, so only the fields and codes are logged.
+		fields := make([]string, len(errs))
+		for i, fieldErr := range errs {
+			fields[i] = fieldErr.Field + ": " + string(fieldErr.Code)
+		}
+		c.logger.Debug().Strs("errors", fields).Int("line", line).Msg("Invalid event")
+		for _, fieldErr := range errs {
+			c.result.Rejected = append(c.result.Rejected, RejectedLine{
+				Line:   line,
+				Field:  fieldErr.Field,
+				Code:   fieldErr.Code,
+				Reason: fieldErr.Message,
+			})
+		}
+		return
+	}
+
+	if c.client.Bot != "" && c.s.userAgents.DropBots() {
+		c.result.Dropped = append(c.result.Dropped, line)
+		return
+	}
+	event.EnrichWith(c.origin.ProjectID, c.origin.IP, c.origin.ServerTime)
+	event.NormalizeClientTime(c.s.clientTime)
+	event.Client = c.client
+
+	quarantine := verdict == schema.Quarantine
+	emitted := 0
+	c.stages.Run(event, func(e *domain.Event) {
+		emitted++
+		c.queue(line, e, size, quarantine, emitted == 1)
+	})
+	if emitted == 0 {
+		c.result.Dropped = append(c.result.Dropped, line)
+	}
+}
+
+// queue puts an event into its chunk. The events split from one line are reported
+// once, with the first of them.
+func (c *collector) queue(line int, event *domain.Event, size int, quarantine, report bool) {
+	target := &c.events
+	if quarantine {
+		target = &c.quarantined
+	}
+	target.batch.Events = append(target.batch.Events, *event)
+	if report {
+		target.lines = append(target.lines, line)
+	}
+	target.size += int64(size)
+	if len(target.batch.Events) >= c.s.chunkSize() {
+		c.flush(target, quarantine)
+	}
+}
+
+// flush queues a chunk and starts a new one. It waits for in-flight memory, which
+// slows the reading of the body down while the queue is behind. In the asynchronous
+// mode the chunk is handed to the ingestion executor, and decoding stops with
+// ErrSaturated when the executor is full, or ErrStopped once it is drained.
+func (c *collector) flush(ch *chunk, quarantine bool) {
+	if len(ch.batch.Events) == 0 {
+		return
+	}
+	batch, lines := ch.batch, ch.lines
+	batch.ID = batch.Events[0].DeviceID
+
+	release, err := c.s.acquire(c.ctx, ch.size)
+	*ch = chunk{}
+	if err != nil {
+		c.logger.Err(err).Str("BATCH_ID", batch.ID).Msg("Gave up waiting for in-flight memory")
+		c.err = fmt.Errorf("%w: %v", ErrQueueUnavailable, err)
+		return
+	}
+
+	switch {
+	case quarantine && c.sync:
+		err = c.s.quarantine(c.ctx, batch)
+		release()
+		if err != nil {
+			c.err = ErrQueueUnavailable
+			return
+		}
+	case quarantine:
+		err = c.s.background(len(batch.Events), func() {
+			defer release()
+			_ = c.s.quarantine(context.Background(), batch)
+		})
+	case c.sync:
+		err = c.s.eventPool.Publish(c.ctx, batch)
+		release()
+		if err != nil {
+			log.Err(err).Str("BATCH_ID", batch.ID).Msg("Failed to queue events")
+			c.err = ErrQueueUnavailable
+			return
+		}
+		if c.result.BatchID == "" {
+			c.result.BatchID = batch.ID
+		}
+	default:
+		err = c.s.background(len(batch.Events), func() {
+			defer release()
+			c.s.eventPool.Process(batch)
+		})
+	}
+	if err != nil {
+		release()
+		c.logger.Warn().Err(err).Str("BATCH_ID", batch.ID).Msg("Turned events away")
+		c.err = err
+		return
+	}
+
+	if quarantine {
+		c.result.Quarantined = append(c.result.Quarantined, lines...)
+	} else {
+		c.result.Accepted = append(c.result.Accepted, lines...)
+	}
+}
+
+func (c *collector) reject(line int, code domain.ValidationCode, err error) {
+	c.result.Rejected = append(c.result.Rejected, RejectedLine{
+		Line:   line,
+		Code:   code,
+		Reason: err.Error(),
+	})
+}
+
+func (c *collector) rejectTooLong(line, max int) {
+	c.result.Rejected = append(c.result.Rejected, RejectedLine{
+		Line:   line,
+		Code:   domain.CodeTooLong,
+		Reason: fmt.Sprintf("is longer than %d bytes", max),
+	})
+}
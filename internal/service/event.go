@@ -1,40 +1,105 @@
 package service
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"io"
+	"net/netip"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/schema"
 )
 
+// ErrQueueUnavailable is returned when accepted events could not be queued.
+var ErrQueueUnavailable = errors.New("event queue is unavailable")
+
+// Event ingests request bodies. Events are decoded as the body is read and queued
+// in chunks, so the events of chunks queued before a read error stay queued and are
+// listed in the Result returned with the error.
 type Event interface {
-	ProcessEvent(buf *bytes.Buffer, clientIP string, serverTime time.Time)
+	ProcessEvent(ctx context.Context, body io.Reader, format Format, origin Origin) (Result, error)
+	ProcessEventSync(ctx context.Context, body io.Reader, format Format, origin Origin) (Result, error)
+	QueueStats() QueueStats
+}
+
+// Origin describes the request the events came with. It is stamped onto every event.
+type Origin struct {
+	// ProjectID is the project of the API key, empty without authentication.
+	ProjectID  string
+	IP         netip.Addr
+	ServerTime time.Time
+	// UserAgent and AcceptLanguage are the request headers, parsed into
+	// domain.Client when the service has a parser.
+	UserAgent      string
+	AcceptLanguage string
 }
 
-func (s *Service) ProcessEvent(buf *bytes.Buffer, clientIP string, serverTime time.Time) {
-	l := log.Logger.With().Str("Service", "ProcessEvent").Logger()
-	scanner := bufio.NewScanner(buf)
-	scanner.Split(bufio.ScanLines)
+// errNoSchema is the dead-letter reason of quarantined events.
+var errNoSchema = errors.New("quarantined: event type has no schema")
 
-	batch := domain.EventBatch{
-		Events: make([]domain.Event, 0),
-	}
-	for scanner.Scan() {
-		event := &domain.Event{}
-		data := scanner.Bytes()
-		if err := json.Unmarshal(data, event); err != nil {
-			l.Err(err).Str("raw_event", string(data)).Msg("Failed to decode event")
-			continue
-		}
-		event.EnrichWith(clientIP, serverTime)
-		batch.Events = append(batch.Events, *event)
+// Result tells a client which lines of its request were accepted. Lines are 1-based
+// line numbers of NDJSON bodies and positions of the events in other formats.
+// Quarantined lines are accepted too, but parked until their event type is declared.
+// Dropped lines were valid but deliberately not queued, such as events of bots.
+type Result struct {
+	BatchID     string         `json:"batch_id,omitempty"`
+	Accepted    []int          `json:"accepted"`
+	Quarantined []int          `json:"quarantined,omitempty"`
+	Dropped     []int          `json:"dropped,omitempty"`
+	Rejected    []RejectedLine `json:"rejected"`
+}
+
+// RejectedLine is a problem with one line; a line may be rejected for several fields.
+type RejectedLine struct {
+	Line   int                   `json:"line"`
+	Field  string                `json:"field,omitempty"`
+	Code   domain.ValidationCode `json:"code"`
+	Reason string                `json:"reason"`
+}
+
+// ProcessEvent decodes and validates the events and queues the valid ones in the
+// background. ctx only bounds the wait for in-flight memory.
+func (s *Service) ProcessEvent(
+	ctx context.Context,
+	body io.Reader,
+	format Format,
+	origin Origin,
+) (Result, error) {
+	c := s.newCollector(ctx, false, origin)
+	err := c.decode(body, format)
+	return c.result, err
+}
+
+// ProcessEventSync returns once the decoded events are acknowledged by the queue.
+// Failed chunks are not dead-lettered: the client is expected to retry.
+func (s *Service) ProcessEventSync(
+	ctx context.Context,
+	body io.Reader,
+	format Format,
+	origin Origin,
+) (Result, error) {
+	c := s.newCollector(ctx, true, origin)
+	err := c.decode(body, format)
+	return c.result, err
+}
+
+func (s *Service) quarantine(ctx context.Context, batch domain.EventBatch) error {
+	err := s.eventPool.Quarantine(ctx, batch, errNoSchema)
+	if err != nil {
+		log.Err(err).Str("BATCH_ID", batch.ID).Int("EVENTS", len(batch.Events)).Msg("Failed to quarantine events")
 	}
-	if len(batch.Events) > 0 {
-		batch.ID = batch.Events[0].DeviceID
-		s.eventPool.Process(batch)
+	return err
+}
+
+// applySchema types the event properties, against the registry when there is one.
+func (s *Service) applySchema(event *domain.Event) (schema.Verdict, []domain.FieldError) {
+	if s.schemas == nil {
+		event.TypedProperties = domain.TypedProperties{}
+		schema.Infer(event)
+		return schema.Accept, nil
 	}
+	return s.schemas.Apply(event)
 }
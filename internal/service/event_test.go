@@ -0,0 +1,521 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/schema"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
+	"github.com/leshachaplin/datalog/internal/useragent"
+	"github.com/leshachaplin/datalog/internal/worker"
+)
+
+var clientIP = netip.MustParseAddr("10.0.0.1")
+
+type publishPool struct {
+	worker.WorkerPool
+	published     []domain.EventBatch
+	quarantined   []domain.EventBatch
+	err           error
+	quarantineErr error
+}
+
+func (p *publishPool) Quarantine(_ context.Context, batch domain.EventBatch, _ error) error {
+	if p.quarantineErr != nil {
+		return p.quarantineErr
+	}
+	p.quarantined = append(p.quarantined, batch)
+	return nil
+}
+
+func (p *publishPool) Publish(_ context.Context, batch domain.EventBatch) error {
+	p.published = append(p.published, batch)
+	return p.err
+}
+
+func TestService_ProcessEventSync(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":
+{"device_id":"d1","event":"app_close","client_time":"2023-05-31 10:00:01"}
+{"event":"app_close","client_time":"2023-05-31 10:00:01","sequence":-1}
+`
+	serverTime := time.Now()
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{ProjectID: "shop", IP: clientIP, ServerTime: serverTime})
+	require.NoError(t, err)
+
+	require.Equal(t, "d1", result.BatchID)
+	require.Equal(t, []int{1, 3}, result.Accepted)
+	require.Equal(t, []RejectedLine{
+		{Line: 2, Code: domain.CodeInvalidJSON, Reason: "unexpected end of JSON input"},
+		{Line: 4, Field: "device_id", Code: domain.CodeRequired, Reason: "is required"},
+		{Line: 4, Field: "sequence", Code: domain.CodeOutOfRange, Reason: "must be between 0 and 32767, got -1"},
+	}, result.Rejected)
+
+	require.Len(t, pool.published, 1)
+	require.Len(t, pool.published[0].Events, 2)
+	require.Equal(t, clientIP, pool.published[0].Events[0].IP)
+	require.Equal(t, "shop", pool.published[0].Events[0].ProjectID)
+
+	pool.err = errors.New("produce sync: context deadline exceeded")
+	_, err = s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: serverTime})
+	require.ErrorIs(t, err, ErrQueueUnavailable)
+}
+
+func TestService_ProcessEventSync_Stages(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d1","event":"debug","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d1","event":"purchase","client_time":"2023-05-31 10:00:00"}
+`
+	var seen []netip.Addr
+	locate := pipeline.StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		seen = append(seen, event.IP)
+		event.Geo.Country = "DE"
+		emit(event)
+	})
+	dropDebug, err := pipeline.Filter(`event == "debug"`, true)
+	require.NoError(t, err)
+	splitPurchase := pipeline.StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		emit(event)
+		if event.Event == "purchase" {
+			receipt := *event
+			receipt.Event = "receipt"
+			emit(&receipt)
+		}
+	})
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+	WithStages(locate)(s)
+	WithStages(dropDebug, splitPurchase)(s)
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1, 4}, result.Accepted)
+	require.Equal(t, []int{3}, result.Dropped)
+	require.Equal(t, []netip.Addr{clientIP, clientIP, clientIP}, seen)
+
+	var names []string
+	for _, event := range pool.published[0].Events {
+		require.Equal(t, "DE", event.Geo.Country)
+		names = append(names, event.Event)
+	}
+	require.Equal(t, []string{"app_open", "purchase", "receipt"}, names)
+}
+
+func TestService_ProcessEventSync_Privacy(t *testing.T) {
+	body := `{"device_id":"d1","event":"purchase","client_time":"2023-05-31 10:00:00","privacy_version":"forged"}
+`
+	var seen []string
+	split := pipeline.StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		seen = append(seen, event.DeviceID)
+		receipt := *event
+		receipt.Event = "receipt"
+		emit(event)
+		emit(&receipt)
+	})
+	redact := pipeline.StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		event.DeviceID = "redacted"
+		event.PrivacyVersion = "v1"
+		emit(event)
+	})
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+	WithPrivacy(redact)(s)
+	WithStages(split)(s)
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, []string{"d1"}, seen)
+	require.Len(t, pool.published[0].Events, 2)
+	for _, event := range pool.published[0].Events {
+		require.Equal(t, "redacted", event.DeviceID)
+		require.Equal(t, "v1", event.PrivacyVersion)
+	}
+}
+
+func TestService_ProcessEventSync_UserAgents(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00","client":{"bot":"forged"}}
+{"event":"app_open","client_time":"2023-05-31 10:00:00"}
+`
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36"
+
+	parser, err := useragent.New(useragent.Config{Bots: useragent.BotsDrop})
+	require.NoError(t, err)
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+	WithUserAgents(parser)(s)
+
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{
+		IP: clientIP, ServerTime: time.Now(), UserAgent: chrome, AcceptLanguage: "en-GB",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, domain.Client{
+		Browser: "Chrome", BrowserVersion: "120.0", OS: "Windows", OSVersion: "10",
+		Device: useragent.DeviceDesktop, Language: "en-GB",
+	}, pool.published[0].Events[0].Client)
+
+	// Events of bots are dropped once they are valid.
+	result, err = s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{
+		IP: clientIP, ServerTime: time.Now(), UserAgent: "curl/8.4.0",
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Accepted)
+	require.Equal(t, []int{1}, result.Dropped)
+	require.Len(t, result.Rejected, 1)
+	require.Len(t, pool.published, 1)
+}
+
+func TestService_ProcessEventSync_Schemas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schemas.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+events:
+  - name: purchase
+    properties:
+      amount: {type: float, required: true}
+`), 0o600))
+	registry, err := schema.NewRegistry(schema.Config{Path: path, UnknownEvents: schema.UnknownQuarantine})
+	require.NoError(t, err)
+
+	body := `{"device_id":"d1","event":"purchase","client_time":"2023-05-31 10:00:00","properties":{"amount":9.99}}
+{"device_id":"d1","event":"purchase","client_time":"2023-05-31 10:00:00","properties":{"amount":"9.99"}}
+{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00","properties":{"screen":"main"}}
+`
+	pool := &publishPool{}
+	s := &Service{eventPool: pool, schemas: registry}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, []int{3}, result.Quarantined)
+	require.Equal(t, []RejectedLine{
+		{Line: 2, Field: "properties.amount", Code: domain.CodeInvalidType, Reason: "expected float, got string"},
+	}, result.Rejected)
+
+	require.Equal(t, map[string]float64{"amount": 9.99}, pool.published[0].Events[0].TypedProperties.Float)
+	require.Equal(t, map[string]string{"screen": "main"}, pool.quarantined[0].Events[0].TypedProperties.String)
+
+	pool = &publishPool{quarantineErr: errors.New("dead-letter topic is unavailable")}
+	s = &Service{eventPool: pool, schemas: registry}
+	result, err = s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.ErrorIs(t, err, ErrQueueUnavailable)
+	require.Empty(t, result.Quarantined)
+}
+
+func TestService_ProcessEventSync_Protobuf(t *testing.T) {
+	body, err := domain.EventBatch{Events: []domain.Event{
+		{DeviceID: "d1", Event: "purchase", ClientTime: "2023-05-31 10:00:00", Properties: map[string]any{"amount": 9.99}},
+		{Event: "app_open", ClientTime: "2023-05-31 10:00:00"},
+	}}.MarshalProto()
+	require.NoError(t, err)
+	// A third event with a truncated field.
+	body = append(body, 0x12, 0x02, 0x12, 0x05)
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+	result, err := s.ProcessEventSync(context.Background(), bytes.NewReader(body), FormatProtobuf, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Len(t, result.Rejected, 2)
+	require.Equal(t, RejectedLine{Line: 2, Field: "device_id", Code: domain.CodeRequired, Reason: "is required"}, result.Rejected[0])
+	require.Equal(t, 3, result.Rejected[1].Line)
+	require.Equal(t, domain.CodeInvalidProto, result.Rejected[1].Code)
+
+	require.Equal(t, map[string]float64{"amount": 9.99}, pool.published[0].Events[0].TypedProperties.Float)
+	require.Equal(t, clientIP, pool.published[0].Events[0].IP)
+}
+
+func TestService_ProcessEventSync_Formats(t *testing.T) {
+	const (
+		openEvent  = `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}`
+		closeEvent = `{"device_id":"d1","event":"app_close","client_time":"2023-05-31 10:00:01"}`
+	)
+
+	cases := map[string]struct {
+		format           Format
+		body             string
+		expectedAccepted []int
+		expectedRejected []int
+	}{
+		"auto array": {
+			format:           FormatAuto,
+			body:             "\n [" + openEvent + ",\n" + closeEvent + "]",
+			expectedAccepted: []int{1, 2},
+		},
+		"auto ndjson": {
+			format:           FormatAuto,
+			body:             openEvent + "\n\n" + closeEvent + "\n",
+			expectedAccepted: []int{1, 3},
+		},
+		"json object": {
+			format:           FormatJSON,
+			body:             "{\n  \"device_id\": \"d1\",\n  \"event\": \"app_open\",\n  \"client_time\": \"2023-05-31 10:00:00\"\n}",
+			expectedAccepted: []int{1},
+		},
+		"json objects": {
+			format:           FormatJSON,
+			body:             openEvent + closeEvent,
+			expectedAccepted: []int{1, 2},
+		},
+		"json array with an invalid element": {
+			format:           FormatJSON,
+			body:             "[" + openEvent + `, {"device_id": 1}, ` + closeEvent + "]",
+			expectedAccepted: []int{1, 3},
+			expectedRejected: []int{2},
+		},
+		"json array with a syntax error": {
+			format:           FormatJSON,
+			body:             "[" + openEvent + `, {"device_id": }, ` + closeEvent + "]",
+			expectedAccepted: []int{1},
+			expectedRejected: []int{2},
+		},
+		"json array with trailing data": {
+			format:           FormatJSON,
+			body:             "[" + openEvent + "] {}",
+			expectedAccepted: []int{1},
+			expectedRejected: []int{2},
+		},
+		"json scalar": {
+			format:           FormatJSON,
+			body:             `"app_open"`,
+			expectedAccepted: []int{},
+			expectedRejected: []int{1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &Service{eventPool: &publishPool{}}
+			result, err := s.ProcessEventSync(context.Background(), strings.NewReader(tc.body), tc.format, Origin{IP: clientIP, ServerTime: time.Now()})
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAccepted, result.Accepted)
+
+			rejected := make([]int, 0)
+			for _, line := range result.Rejected {
+				rejected = append(rejected, line.Line)
+			}
+			if tc.expectedRejected == nil {
+				tc.expectedRejected = []int{}
+			}
+			require.Equal(t, tc.expectedRejected, rejected)
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("request body is too large")
+}
+
+func TestService_ProcessEvent_ReadError(t *testing.T) {
+	pool := &publishPool{}
+	s := &Service{eventPool: pool}
+
+	body := io.MultiReader(strings.NewReader(`[{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"},`), failingReader{})
+	_, err := s.ProcessEvent(context.Background(), body, FormatJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.EqualError(t, err, "request body is too large")
+	require.Empty(t, pool.published)
+}
+
+func TestService_ProcessEventSync_Chunks(t *testing.T) {
+	const event = `{"device_id":"d%d","event":"app_open","client_time":"2023-05-31 10:00:00"}` + "\n"
+	var body strings.Builder
+	for i := 1; i <= 5; i++ {
+		body.WriteString(fmt.Sprintf(event, i))
+	}
+	body.WriteString(`{"device_id":"long","event":"` + strings.Repeat("x", 200) + `"}` + "\n")
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool, limits: Config{ChunkSize: 2, MaxLineSize: 128}}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body.String()), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1, 2, 3, 4, 5}, result.Accepted)
+	require.Equal(t, []RejectedLine{{Line: 6, Code: domain.CodeTooLong, Reason: "is longer than 128 bytes"}}, result.Rejected)
+	require.Equal(t, "d1", result.BatchID)
+
+	require.Len(t, pool.published, 3)
+	require.Len(t, pool.published[0].Events, 2)
+	require.Equal(t, "d3", pool.published[1].ID)
+	require.Len(t, pool.published[2].Events, 1)
+}
+
+func TestService_ProcessEventSync_ChunkFailure(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d2","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d3","event":"app_open","client_time":"2023-05-31 10:00:00"}
+`
+	pool := &failingPool{failAfter: 1}
+	s := &Service{eventPool: pool, limits: Config{ChunkSize: 1}}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.ErrorIs(t, err, ErrQueueUnavailable)
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, 2, pool.calls, "decoding must stop after the failed chunk")
+}
+
+func TestService_ProcessEvent_InFlightLimit(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}`
+
+	s := &Service{
+		eventPool: &publishPool{},
+		limits:    Config{MaxInFlightBytes: 10},
+		inFlight:  semaphore.NewWeighted(10),
+	}
+	// Another request holds the whole budget.
+	require.NoError(t, s.inFlight.Acquire(context.Background(), 10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := s.ProcessEvent(ctx, strings.NewReader(body), FormatJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.ErrorIs(t, err, ErrQueueUnavailable)
+	require.Empty(t, result.Accepted)
+}
+
+type failingPool struct {
+	worker.WorkerPool
+	failAfter int
+	calls     int
+}
+
+func (p *failingPool) Publish(context.Context, domain.EventBatch) error {
+	p.calls++
+	if p.calls > p.failAfter {
+		return errors.New("produce sync: context deadline exceeded")
+	}
+	return nil
+}
+
+func TestService_ProcessEventSync_LongLines(t *testing.T) {
+	// Lines beyond the 64KB bufio.Scanner limit and the 4KB reader buffer.
+	long := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00","param_str":"` + strings.Repeat("x", 100<<10) + `"}`
+	body := long + "\n" + long + "x\n" + `{"device_id":"d3","event":"app_open","client_time":"2023-05-31 10:00:00"}`
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool, limits: Config{MaxLineSize: len(long)}, validation: domain.ValidationRules{MaxParamStrLength: 200 << 10}}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatAuto, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1, 3}, result.Accepted)
+	require.Len(t, result.Rejected, 1)
+	require.Equal(t, 2, result.Rejected[0].Line)
+	require.Equal(t, domain.CodeTooLong, result.Rejected[0].Code)
+}
+
+func TestService_ProcessEventSync_LongElement(t *testing.T) {
+	const event = `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}`
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader(`[`+event+`,{"device_id":"long","event":"`),
+		strings.NewReader(strings.Repeat("x", 10<<20)),
+		strings.NewReader(`"},`+event+`]`),
+	)}
+
+	pool := &publishPool{}
+	s := &Service{eventPool: pool, limits: Config{MaxLineSize: 128}}
+	result, err := s.ProcessEventSync(context.Background(), body, FormatJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, []RejectedLine{{Line: 2, Code: domain.CodeTooLong, Reason: "is longer than 128 bytes"}}, result.Rejected)
+	require.Less(t, body.n, 64<<10, "the long element must not be buffered")
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestService_ProcessEvent_Saturated(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d2","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d3","event":"app_open","client_time":"2023-05-31 10:00:00"}
+`
+	// Without workers the queue holds a single chunk.
+	s := &Service{eventPool: &publishPool{}, limits: Config{ChunkSize: 1}, executor: newExecutor(0, 1)}
+	result, err := s.ProcessEvent(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.ErrorIs(t, err, ErrSaturated)
+	require.Equal(t, []int{1}, result.Accepted)
+	require.Equal(t, QueueStats{Depth: 1, Capacity: 1}, s.QueueStats())
+	require.True(t, s.QueueStats().Saturated())
+}
+
+type blockingPool struct {
+	worker.WorkerPool
+	release   chan struct{}
+	processed atomic.Int32
+}
+
+func (p *blockingPool) Process(domain.EventBatch) {
+	<-p.release
+	p.processed.Add(1)
+}
+
+func TestService_Drain(t *testing.T) {
+	body := `{"device_id":"d1","event":"app_open","client_time":"2023-05-31 10:00:00"}
+{"device_id":"d2","event":"app_open","client_time":"2023-05-31 10:00:00"}
+`
+	pool := &blockingPool{release: make(chan struct{})}
+	s := &Service{eventPool: pool, limits: Config{ChunkSize: 1}, executor: newExecutor(1, 4)}
+	result, err := s.ProcessEvent(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, result.Accepted)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, 2, s.Drain(ctx), "both events are still being queued")
+
+	_, err = s.ProcessEvent(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.ErrorIs(t, err, ErrStopped)
+
+	close(pool.release)
+	require.Equal(t, 0, s.Drain(context.Background()))
+	require.EqualValues(t, 2, pool.processed.Load())
+}
+
+func TestService_ProcessEvent_RejectedNotLogged(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&logs).Level(zerolog.DebugLevel)
+	t.Cleanup(func() { log.Logger = logger })
+
+	body := `{"device_id":"secret@example.com","event":"app_open","client_time":"2023-05-31 10:00:00"
+{"device_id":"secret@example.com","event":"app_open","client_time":"secret@example.com"}
+`
+	s := &Service{eventPool: &publishPool{}}
+	result, err := s.ProcessEventSync(context.Background(), strings.NewReader(body), FormatNDJSON, Origin{IP: clientIP, ServerTime: time.Now()})
+	require.NoError(t, err)
+	require.Len(t, result.Rejected, 2)
+
+	require.Contains(t, logs.String(), "Failed to decode event")
+	require.Contains(t, logs.String(), "client_time: invalid_time")
+	require.NotContains(t, logs.String(), "secret")
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrSaturated is returned when the ingestion queue is full. Clients should retry
+	// later.
+	ErrSaturated = errors.New("ingestion queue is full")
+	// ErrStopped is returned once the service drains for shutdown.
+	ErrStopped = errors.New("ingestion is shutting down")
+)
+
+// QueueStats describes the ingestion executor, which queues accepted chunks in the
+// background.
+type QueueStats struct {
+	// Depth is the number of chunks waiting for a worker.
+	Depth int `json:"depth"`
+	// Capacity is the most chunks that can wait.
+	Capacity int `json:"capacity"`
+	// Active is the number of chunks being queued.
+	Active  int `json:"active"`
+	Workers int `json:"workers"`
+}
+
+// Saturated reports whether new chunks are turned away.
+func (s QueueStats) Saturated() bool {
+	return s.Capacity > 0 && s.Depth >= s.Capacity
+}
+
+// executor runs background tasks on a fixed number of workers. Tasks wait in a
+// bounded queue and are refused when it is full, instead of piling up goroutines.
+type executor struct {
+	mu      sync.RWMutex
+	stopped bool
+	tasks   chan func()
+	workers int
+	active  atomic.Int64
+	wg      sync.WaitGroup
+}
+
+func newExecutor(workers, depth int) *executor {
+	e := &executor{
+		tasks:   make(chan func(), depth),
+		workers: workers,
+	}
+
+	e.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.work()
+	}
+	return e
+}
+
+func (e *executor) work() {
+	defer e.wg.Done()
+	for task := range e.tasks {
+		e.active.Add(1)
+		task()
+		e.active.Add(-1)
+	}
+}
+
+// submit queues the task, or returns ErrSaturated without blocking. It returns
+// ErrStopped after stop.
+func (e *executor) submit(task func()) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.stopped {
+		return ErrStopped
+	}
+
+	select {
+	case e.tasks <- task:
+		return nil
+	default:
+		return ErrSaturated
+	}
+}
+
+// stop refuses new tasks and waits, until ctx is done, for the queued ones to run.
+func (e *executor) stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.stopped {
+		e.stopped = true
+		close(e.tasks)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *executor) stats() QueueStats {
+	return QueueStats{
+		Depth:    len(e.tasks),
+		Capacity: cap(e.tasks),
+		Active:   int(e.active.Load()),
+		Workers:  e.workers,
+	}
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Path is a YAML file of stages, see Load. No stages run when it is empty.
+	Path string `mapstructure:"path"`
+}
+
+// file declares stages, each with its scope and exactly one of the built-ins:
+//
+//	stages:
+//	  - events: [purchase]
+//	    rename: {from: param_str, to: properties.sku}
+//	  - projects: [shop]
+//	    tag: {field: properties.env, value: prod}
+//	  - filter: {drop: 'event == "debug" or properties.internal'}
+//	  - hash: {fields: [properties.email], key_env: DATALOG_HASH_KEY}
+type file struct {
+	Stages []stageSpec `yaml:"stages"`
+}
+
+type stageSpec struct {
+	Scope  `yaml:",inline"`
+	Rename *struct {
+		From string `yaml:"from"`
+		To   string `yaml:"to"`
+	} `yaml:"rename"`
+	Tag *struct {
+		Field string `yaml:"field"`
+		Value any    `yaml:"value"`
+	} `yaml:"tag"`
+	Filter *struct {
+		Keep string `yaml:"keep"`
+		Drop string `yaml:"drop"`
+	} `yaml:"filter"`
+	Hash *HashSpec `yaml:"hash"`
+}
+
+// HashSpec declares a Hash stage. The privacy rules declare theirs the same way.
+type HashSpec struct {
+	Fields []string `yaml:"fields"`
+	Key    string   `yaml:"key"`
+	// KeyEnv names an environment variable holding the key, so that it can stay out
+	// of the file.
+	KeyEnv string `yaml:"key_env"`
+}
+
+// Load reads the stages declared in a file.
+func Load(path string) (Chain, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pipeline %s: %w", path, err)
+	}
+
+	var f file
+	if err = yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode pipeline %s: %w", path, err)
+	}
+
+	chain := make(Chain, 0, len(f.Stages))
+	for i, spec := range f.Stages {
+		stage, err := spec.build()
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: stage %d: %w", path, i, err)
+		}
+		chain = append(chain, Scoped(spec.Scope, stage))
+	}
+	return chain, nil
+}
+
+func (s stageSpec) build() (Stage, error) {
+	n := 0
+	for _, set := range []bool{s.Rename != nil, s.Tag != nil, s.Filter != nil, s.Hash != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, errors.New("must declare exactly one of rename, tag, filter and hash")
+	}
+
+	switch {
+	case s.Rename != nil:
+		from, err := ParseField(s.Rename.From)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		to, err := ParseField(s.Rename.To)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		return Rename(from, to)
+	case s.Tag != nil:
+		field, err := ParseField(s.Tag.Field)
+		if err != nil {
+			return nil, fmt.Errorf("tag: %w", err)
+		}
+		value := s.Tag.Value
+		if n, ok := value.(int); ok {
+			value = int64(n)
+		}
+		return Tag(field, value)
+	case s.Filter != nil:
+		if (s.Filter.Keep == "") == (s.Filter.Drop == "") {
+			return nil, errors.New("filter: must declare exactly one of keep and drop")
+		}
+		if s.Filter.Keep != "" {
+			return Filter(s.Filter.Keep, false)
+		}
+		return Filter(s.Filter.Drop, true)
+	default:
+		return s.Hash.Build()
+	}
+}
+
+// Build returns the Hash stage, with errors prefixed by "hash: ".
+func (s HashSpec) Build() (Stage, error) {
+	fields, err := ParseFields(s.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("hash: %w", err)
+	}
+
+	key := s.Key
+	if s.KeyEnv != "" {
+		if key != "" {
+			return nil, errors.New("hash: key and key_env are mutually exclusive")
+		}
+		var ok bool
+		if key, ok = os.LookupEnv(s.KeyEnv); !ok || key == "" {
+			return nil, fmt.Errorf("hash: environment variable %s is not set", s.KeyEnv)
+		}
+	}
+	stage, err := Hash(fields, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("hash: %w", err)
+	}
+	return stage, nil
+}
+
+// ParseFields parses each of the paths with ParseField.
+func ParseFields(paths []string) ([]Field, error) {
+	fields := make([]Field, 0, len(paths))
+	for _, path := range paths {
+		field, err := ParseField(path)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func writePipeline(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_HASH_KEY", "secret")
+	path := writePipeline(t, `
+stages:
+  - events: [purchase]
+    rename: {from: param_str, to: properties.sku}
+  - projects: [shop]
+    tag: {field: properties.env, value: prod}
+  - tag: {field: properties.version, value: 2}
+  - filter: {drop: 'event == "debug"'}
+  - hash: {fields: [device_id], key_env: TEST_HASH_KEY}
+`)
+	chain, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, chain, 5)
+
+	out := run(chain, domain.Event{ProjectID: "shop", Event: "purchase", DeviceID: "d1", ParamStr: "a-1"})
+	require.Len(t, out, 1)
+	require.Equal(t, hashValue("d1", []byte("secret")), out[0].DeviceID)
+	require.Empty(t, out[0].ParamStr)
+	require.Equal(t, map[string]string{"sku": "a-1", "env": "prod"}, out[0].TypedProperties.String)
+	require.Equal(t, map[string]int64{"version": 2}, out[0].TypedProperties.Int)
+
+	out = run(chain, domain.Event{ProjectID: "blog", Event: "app_open", ParamStr: "a-1"})
+	require.Equal(t, "a-1", out[0].ParamStr)
+	require.Equal(t, map[string]int64{"version": 2}, out[0].TypedProperties.Int)
+	require.Empty(t, out[0].TypedProperties.String)
+
+	require.Empty(t, run(chain, domain.Event{Event: "debug"}))
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]string{
+		"stages:\n  - {}\n": "stage 0: must declare exactly one of rename, tag, filter and hash",
+		"stages:\n  - rename: {from: param_str, to: properties.sku}\n    tag: {field: event, value: x}\n": "stage 0: must declare exactly one",
+		"stages:\n  - rename: {from: client_ip, to: properties.ip}\n":                                     `rename: unknown field "client_ip"`,
+		"stages:\n  - filter: {keep: 'event == 1', drop: 'event == 2'}\n":                                 "filter: must declare exactly one of keep and drop",
+		"stages:\n  - filter: {keep: 'event =='}\n":                                                       `filter "event ==": expected a literal`,
+		"stages:\n  - tag: {field: properties.list, value: [a, b]}\n":                                     "cannot tag properties.list with []interface {}",
+		"stages:\n  - hash: {fields: [device_id], key_env: TEST_UNSET_HASH_KEY}\n":                        "environment variable TEST_UNSET_HASH_KEY is not set",
+		"stages:\n  - hash: {fields: [device_id]}\n":                                                      "hash: missing key",
+		"stages: [": "decode pipeline",
+	}
+	for content, want := range tests {
+		t.Run(want, func(t *testing.T) {
+			_, err := Load(writePipeline(t, content))
+			require.ErrorContains(t, err, want)
+		})
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "read pipeline")
+}
@@ -0,0 +1,345 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+// An expression tests an event, for example
+//
+//	event == "purchase" and (properties.amount >= 100 or not user_properties.vip)
+//
+// Comparisons take a field on the left and a literal on the right: a quoted string,
+// a number, true or false. The operators are ==, !=, <, <=, >, >= and =~, which
+// matches a regular expression. A bare field is true when it is set and not false.
+// Comparisons on unset fields are false, except for !=.
+type expr interface {
+	eval(e *domain.Event) bool
+}
+
+type (
+	andExpr     struct{ left, right expr }
+	orExpr      struct{ left, right expr }
+	notExpr     struct{ x expr }
+	truthyExpr  struct{ field Field }
+	compareExpr struct {
+		field   Field
+		op      string
+		literal any
+		pattern *regexp.Regexp
+	}
+)
+
+func (x andExpr) eval(e *domain.Event) bool { return x.left.eval(e) && x.right.eval(e) }
+func (x orExpr) eval(e *domain.Event) bool  { return x.left.eval(e) || x.right.eval(e) }
+func (x notExpr) eval(e *domain.Event) bool { return !x.x.eval(e) }
+
+func (x truthyExpr) eval(e *domain.Event) bool {
+	v, ok := x.field.Get(e)
+	if b, isBool := v.(bool); isBool {
+		return b
+	}
+	return ok
+}
+
+func (x compareExpr) eval(e *domain.Event) bool {
+	v, ok := x.field.Get(e)
+	if !ok {
+		return x.op == "!="
+	}
+	if x.pattern != nil {
+		s, isString := v.(string)
+		return isString && x.pattern.MatchString(s)
+	}
+
+	c, comparable := compare(v, x.literal)
+	if !comparable {
+		return x.op == "!="
+	}
+	switch x.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// compare orders a field value against a literal of a matching type. Timestamps
+// compare against RFC 3339 strings.
+func compare(value, literal any) (int, bool) {
+	switch l := literal.(type) {
+	case string:
+		switch v := value.(type) {
+		case string:
+			return strings.Compare(v, l), true
+		case time.Time:
+			t, err := time.Parse(time.RFC3339Nano, l)
+			if err != nil {
+				return 0, false
+			}
+			return v.Compare(t), true
+		}
+	case float64:
+		var n float64
+		switch v := value.(type) {
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return 0, false
+		}
+		switch {
+		case n < l:
+			return -1, true
+		case n > l:
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		if v, ok := value.(bool); ok {
+			if v == l {
+				return 0, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// parseExpr parses an expression.
+func parseExpr(s string) (expr, error) {
+	p := &exprParser{tokens: tokenize(s)}
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %s", tok)
+	}
+	return x, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenParen
+	tokenInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{tokenParen, s[i : i+1]})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return append(tokens, token{tokenInvalid, s[i:]})
+			}
+			tokens = append(tokens, token{tokenString, s[i : j+1]})
+			i = j + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(s) && (s[j] == '=' || (c == '=' && s[j] == '~')) {
+				j++
+			}
+			tokens = append(tokens, token{tokenOp, s[i:j]})
+			i = j
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || s[j] == 'e' || s[j] == 'E' || s[j] == '+' || s[j] == '-' || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || s[j] == '-' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:j]})
+			i = j
+		default:
+			return append(tokens, token{tokenInvalid, s[i : i+1]})
+		}
+	}
+	return tokens
+}
+
+type exprParser struct {
+	tokens []token
+}
+
+func (p *exprParser) peek() token {
+	if len(p.tokens) == 0 {
+		return token{kind: tokenEnd}
+	}
+	return p.tokens[0]
+}
+
+func (p *exprParser) next() token {
+	tok := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *exprParser) keyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokenIdent && tok.text == word {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) or() (expr, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("or") {
+		var right expr
+		if right, err = p.and(); err == nil {
+			left = orExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) and() (expr, error) {
+	left, err := p.not()
+	for err == nil && p.keyword("and") {
+		var right expr
+		if right, err = p.not(); err == nil {
+			left = andExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) not() (expr, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		return notExpr{x}, err
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenParen && tok.text == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.text != ")" {
+			return nil, fmt.Errorf("expected \")\", got %s", closing)
+		}
+		return x, nil
+	case tok.kind != tokenIdent:
+		return nil, fmt.Errorf("expected a field, got %s", tok)
+	}
+
+	field, err := ParseField(tok.text)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenOp {
+		return truthyExpr{field}, nil
+	}
+
+	op := p.next().text
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+	literal, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+
+	x := compareExpr{field: field, op: op, literal: literal}
+	if op == "=~" {
+		s, ok := literal.(string)
+		if !ok {
+			return nil, errors.New("=~ takes a regular expression string")
+		}
+		if x.pattern, err = regexp.Compile(s); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (p *exprParser) literal() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		if tok.text[0] == '\'' {
+			return strings.ReplaceAll(tok.text[1:len(tok.text)-1], `\'`, `'`), nil
+		}
+		s, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", tok.text)
+		}
+		return s, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return n, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a literal, got %s", tok)
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func TestParseExpr(t *testing.T) {
+	event := &domain.Event{
+		Event:    "purchase",
+		DeviceOS: "ios",
+		Sequence: 3,
+		TypedProperties: domain.TypedProperties{
+			Float:     map[string]float64{"amount": 120.5},
+			Bool:      map[string]bool{"gift": false},
+			Timestamp: map[string]time.Time{"paid_at": time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		FlatUserProperties: domain.FlatProperties{String: map[string]string{"plan": "pro"}},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`event == "purchase"`, true},
+		{`event != 'purchase'`, false},
+		{`properties.amount >= 100 and sequence < 4`, true},
+		{`properties.amount > 200 or user_properties.plan == "pro"`, true},
+		{`not (device_os == "ios" or device_os == "android")`, false},
+		{`properties.gift`, false},
+		{`not properties.gift and properties.amount`, true},
+		{`properties.missing == "x"`, false},
+		{`properties.missing != "x"`, true},
+		{`properties.amount == "120.5"`, false},
+		{`properties.paid_at < "2023-06-01T00:00:00Z"`, true},
+		{`device_os =~ "^(ios|ipados)$"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			x, err := parseExpr(tt.expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, x.eval(event))
+		})
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	tests := map[string]string{
+		`event ==`:               "expected a literal, got end of expression",
+		`event == "a" event`:     `unexpected "event"`,
+		`(event == "a"`:          `expected ")", got end of expression`,
+		`event => "a"`:           `unknown operator "="`,
+		`event == "a" or nope`:   `unknown field "nope"`,
+		`event =~ 1`:             "=~ takes a regular expression string",
+		`event =~ "("`:           "missing closing )",
+		`event == "unterminated`: `expected a literal, got "\"unterminated"`,
+		`== "a"`:                 `expected a field, got "=="`,
+	}
+	for expr, want := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := parseExpr(expr)
+			require.ErrorContains(t, err, want)
+		})
+	}
+}
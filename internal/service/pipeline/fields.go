@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const (
+	propertiesPrefix     = "properties."
+	userPropertiesPrefix = "user_properties."
+)
+
+// Field is a path to a value of an event: a top-level field such as device_id or ip,
+// a property as properties.<key> or a user property as user_properties.<key>.
+// Values are string, int64, float64, bool, time.Time or []string.
+type Field struct {
+	path string
+	top  *topField
+	// userProps tells whether key is a user property rather than a property.
+	userProps bool
+	key       string
+}
+
+// topField accesses a column of an event. Fields without set are read-only.
+type topField struct {
+	get func(e *domain.Event) any
+	set func(e *domain.Event, value any) bool
+	// text tells whether the field can hold any string.
+	text bool
+}
+
+func stringField(v func(e *domain.Event) *string) *topField {
+	return &topField{
+		get: func(e *domain.Event) any { return *v(e) },
+		set: func(e *domain.Event, value any) bool {
+			*v(e) = stringify(value)
+			return true
+		},
+		text: true,
+	}
+}
+
+// ipField reads the client address as a string, and takes only addresses.
+var ipField = &topField{
+	get: func(e *domain.Event) any {
+		if !e.IP.IsValid() {
+			return ""
+		}
+		return e.IP.String()
+	},
+	set: func(e *domain.Event, value any) bool {
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		if s == "" {
+			e.IP = netip.Addr{}
+			return true
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return false
+		}
+		e.IP = addr
+		return true
+	},
+}
+
+func intField(v func(e *domain.Event) *int) *topField {
+	return &topField{
+		get: func(e *domain.Event) any { return int64(*v(e)) },
+		set: func(e *domain.Event, value any) bool {
+			switch n := value.(type) {
+			case int64:
+				*v(e) = int(n)
+			case float64:
+				if n != math.Trunc(n) {
+					return false
+				}
+				*v(e) = int(n)
+			default:
+				return false
+			}
+			return true
+		},
+	}
+}
+
+var topFields = map[string]*topField{
+	"project_id":  {get: func(e *domain.Event) any { return e.ProjectID }},
+	"ip":          ipField,
+	"event":       stringField(func(e *domain.Event) *string { return &e.Event }),
+	"device_id":   stringField(func(e *domain.Event) *string { return &e.DeviceID }),
+	"device_os":   stringField(func(e *domain.Event) *string { return &e.DeviceOS }),
+	"session":     stringField(func(e *domain.Event) *string { return &e.Session }),
+	"param_str":   stringField(func(e *domain.Event) *string { return &e.ParamStr }),
+	"client_time": stringField(func(e *domain.Event) *string { return (*string)(&e.ClientTime) }),
+	"sequence":    intField(func(e *domain.Event) *int { return &e.Sequence }),
+	"param_int":   intField(func(e *domain.Event) *int { return &e.ParamInt }),
+}
+
+// ParseField parses a field path.
+func ParseField(path string) (Field, error) {
+	f := Field{path: path}
+	switch {
+	case strings.HasPrefix(path, propertiesPrefix):
+		f.key = strings.TrimPrefix(path, propertiesPrefix)
+	case strings.HasPrefix(path, userPropertiesPrefix):
+		f.userProps, f.key = true, strings.TrimPrefix(path, userPropertiesPrefix)
+	default:
+		f.top = topFields[path]
+		if f.top == nil {
+			return Field{}, fmt.Errorf("unknown field %q", path)
+		}
+		return f, nil
+	}
+	if f.key == "" {
+		return Field{}, fmt.Errorf("field %q has no property name", path)
+	}
+	return f, nil
+}
+
+func (f Field) String() string {
+	return f.path
+}
+
+func (f Field) writable() bool {
+	return f.top == nil || f.top.set != nil
+}
+
+// textual reports whether the field holds any value as a string.
+func (f Field) textual() bool {
+	return f.top == nil || f.top.text
+}
+
+// Get returns the value of the field, and whether it is set. Top-level fields are
+// set unless they hold their zero value.
+func (f Field) Get(e *domain.Event) (any, bool) {
+	switch {
+	case f.top != nil:
+		v := f.top.get(e)
+		return v, v != "" && v != int64(0)
+	case f.userProps:
+		if v, ok := e.FlatUserProperties.String[f.key]; ok {
+			return v, true
+		}
+		if v, ok := e.FlatUserProperties.Float[f.key]; ok {
+			return v, true
+		}
+		return nil, false
+	}
+
+	p := &e.TypedProperties
+	if v, ok := p.String[f.key]; ok {
+		return v, true
+	}
+	if v, ok := p.Int[f.key]; ok {
+		return v, true
+	}
+	if v, ok := p.Float[f.key]; ok {
+		return v, true
+	}
+	if v, ok := p.Bool[f.key]; ok {
+		return v, true
+	}
+	if v, ok := p.Timestamp[f.key]; ok {
+		return v, true
+	}
+	if v, ok := p.Array[f.key]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// Set sets the field, converting the value to its type. Properties take the type of
+// the value. It reports false when the value does not fit the field.
+func (f Field) Set(e *domain.Event, value any) bool {
+	if f.top != nil {
+		return f.top.set != nil && f.top.set(e, value)
+	}
+
+	f.Delete(e)
+	if f.userProps {
+		switch v := value.(type) {
+		case int64:
+			setFlat(&e.FlatUserProperties.Float, f.key, float64(v))
+		case float64:
+			setFlat(&e.FlatUserProperties.Float, f.key, v)
+		default:
+			setFlat(&e.FlatUserProperties.String, f.key, stringify(v))
+		}
+		return true
+	}
+
+	p := &e.TypedProperties
+	switch v := value.(type) {
+	case string:
+		p.SetString(f.key, v)
+	case int64:
+		p.SetInt(f.key, v)
+	case float64:
+		p.SetFloat(f.key, v)
+	case bool:
+		p.SetBool(f.key, v)
+	case time.Time:
+		p.SetTimestamp(f.key, v)
+	case []string:
+		p.SetArray(f.key, v)
+	default:
+		return false
+	}
+	return true
+}
+
+// Delete unsets a property, or resets a top-level field to its zero value.
+func (f Field) Delete(e *domain.Event) {
+	switch {
+	case f.top != nil:
+		if f.top.set != nil {
+			if _, ok := f.top.get(e).(string); ok {
+				f.top.set(e, "")
+			} else {
+				f.top.set(e, int64(0))
+			}
+		}
+	case f.userProps:
+		delete(e.FlatUserProperties.String, f.key)
+		delete(e.FlatUserProperties.Float, f.key)
+	default:
+		p := &e.TypedProperties
+		delete(p.String, f.key)
+		delete(p.Int, f.key)
+		delete(p.Float, f.key)
+		delete(p.Bool, f.key)
+		delete(p.Timestamp, f.key)
+		delete(p.Array, f.key)
+	}
+}
+
+func setFlat[V any](m *map[string]V, key string, value V) {
+	if *m == nil {
+		*m = make(map[string]V)
+	}
+	(*m)[key] = value
+}
+
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
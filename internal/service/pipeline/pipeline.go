@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+// Stage transforms one valid event before it is queued. It passes the events to
+// keep on to emit: not calling emit drops the event, calling it more than once
+// splits it. A stage may change the event in place and emit it, but must not keep
+// it after returning. Emitted events are not validated again.
+type Stage interface {
+	Process(event *domain.Event, emit func(*domain.Event))
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(event *domain.Event, emit func(*domain.Event))
+
+func (f StageFunc) Process(event *domain.Event, emit func(*domain.Event)) {
+	f(event, emit)
+}
+
+// Chain runs stages in order, each on the events emitted by the previous one.
+type Chain []Stage
+
+// Run passes the event through the chain and the events that come out of it to emit.
+func (c Chain) Run(event *domain.Event, emit func(*domain.Event)) {
+	if len(c) == 0 {
+		emit(event)
+		return
+	}
+	c[0].Process(event, func(e *domain.Event) {
+		c[1:].Run(e, emit)
+	})
+}
+
+// Enricher adds fields to an event and never drops it.
+type Enricher interface {
+	Enrich(event *domain.Event)
+}
+
+// Enrich adapts an Enricher to a Stage.
+func Enrich(enricher Enricher) Stage {
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		enricher.Enrich(event)
+		emit(event)
+	})
+}
+
+// Scope limits a stage to some projects and event types. Empty lists match all.
+type Scope struct {
+	Projects []string `yaml:"projects"`
+	Events   []string `yaml:"events"`
+}
+
+func (s Scope) matches(event *domain.Event) bool {
+	return contains(s.Projects, event.ProjectID) && contains(s.Events, event.Event)
+}
+
+func contains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Scoped runs the stage on the events in scope and passes the others through.
+func Scoped(scope Scope, stage Stage) Stage {
+	if len(scope.Projects) == 0 && len(scope.Events) == 0 {
+		return stage
+	}
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		if !scope.matches(event) {
+			emit(event)
+			return
+		}
+		stage.Process(event, emit)
+	})
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"net/netip"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func run(chain Chain, event domain.Event) []domain.Event {
+	var out []domain.Event
+	chain.Run(&event, func(e *domain.Event) {
+		out = append(out, *e)
+	})
+	return out
+}
+
+func field(t *testing.T, path string) Field {
+	t.Helper()
+	f, err := ParseField(path)
+	require.NoError(t, err)
+	return f
+}
+
+func TestChain_Run(t *testing.T) {
+	split := StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		for _, name := range []string{"a", "b"} {
+			e := *event
+			e.Event += "_" + name
+			emit(&e)
+		}
+	})
+	dropB, err := Filter(`event =~ "_b$"`, true)
+	require.NoError(t, err)
+
+	out := run(Chain{split, dropB, split}, domain.Event{Event: "x"})
+	require.Equal(t, []domain.Event{{Event: "x_a_a"}, {Event: "x_a_b"}}, out)
+
+	require.Equal(t, []domain.Event{{Event: "x"}}, run(nil, domain.Event{Event: "x"}))
+}
+
+func TestScoped(t *testing.T) {
+	tag, err := Tag(field(t, "properties.env"), "prod")
+	require.NoError(t, err)
+	chain := Chain{Scoped(Scope{Projects: []string{"shop"}, Events: []string{"purchase", "refund"}}, tag)}
+
+	out := run(chain, domain.Event{ProjectID: "shop", Event: "refund"})
+	require.Equal(t, map[string]string{"env": "prod"}, out[0].TypedProperties.String)
+
+	for _, event := range []domain.Event{
+		{ProjectID: "blog", Event: "refund"},
+		{ProjectID: "shop", Event: "app_open"},
+	} {
+		out = run(chain, event)
+		require.Equal(t, []domain.Event{event}, out)
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		event    domain.Event
+		want     domain.Event
+	}{
+		{
+			name:  "top-level to property",
+			from:  "param_str",
+			to:    "properties.sku",
+			event: domain.Event{ParamStr: "a-1"},
+			want:  domain.Event{TypedProperties: domain.TypedProperties{String: map[string]string{"sku": "a-1"}}},
+		},
+		{
+			name:  "property keeps its type",
+			from:  "properties.qty",
+			to:    "properties.quantity",
+			event: domain.Event{TypedProperties: domain.TypedProperties{Int: map[string]int64{"qty": 2}}},
+			want:  domain.Event{TypedProperties: domain.TypedProperties{Int: map[string]int64{"quantity": 2}}},
+		},
+		{
+			name:  "user property",
+			from:  "user_properties.plan",
+			to:    "user_properties.tier",
+			event: domain.Event{FlatUserProperties: domain.FlatProperties{String: map[string]string{"plan": "pro"}}},
+			want:  domain.Event{FlatUserProperties: domain.FlatProperties{String: map[string]string{"tier": "pro"}}},
+		},
+		{
+			name:  "missing",
+			from:  "properties.sku",
+			to:    "param_str",
+			event: domain.Event{ParamStr: "kept"},
+			want:  domain.Event{ParamStr: "kept"},
+		},
+		{
+			name:  "value does not fit",
+			from:  "properties.price",
+			to:    "param_int",
+			event: domain.Event{TypedProperties: domain.TypedProperties{Float: map[string]float64{"price": 9.99}}},
+			want:  domain.Event{TypedProperties: domain.TypedProperties{Float: map[string]float64{"price": 9.99}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := Rename(field(t, tt.from), field(t, tt.to))
+			require.NoError(t, err)
+			require.Equal(t, []domain.Event{tt.want}, run(Chain{stage}, tt.event))
+		})
+	}
+
+	_, err := Rename(field(t, "project_id"), field(t, "param_str"))
+	require.ErrorContains(t, err, "read-only field")
+}
+
+func TestHash(t *testing.T) {
+	// HMAC-SHA256 of test@example.com with the key secret.
+	const emailHash = "49e43229ee99dca2565241719b8341b04e71dd4de0628f991b5bea30a526e153"
+
+	stage, err := Hash([]Field{field(t, "device_id"), field(t, "properties.email"), field(t, "properties.missing")}, []byte("secret"))
+	require.NoError(t, err)
+	out := run(Chain{stage}, domain.Event{
+		DeviceID:        "test@example.com",
+		TypedProperties: domain.TypedProperties{String: map[string]string{"email": "test@example.com"}},
+	})
+	require.Equal(t, emailHash, out[0].DeviceID)
+	require.Equal(t, map[string]string{"email": emailHash}, out[0].TypedProperties.String)
+
+	_, err = Hash([]Field{field(t, "device_id")}, nil)
+	require.EqualError(t, err, "missing key")
+
+	_, err = Hash([]Field{field(t, "sequence")}, []byte("secret"))
+	require.ErrorContains(t, err, "cannot hash sequence")
+}
+
+func TestTag(t *testing.T) {
+	stage, err := Tag(field(t, "user_properties.cohort"), int64(7))
+	require.NoError(t, err)
+	out := run(Chain{stage}, domain.Event{})
+	require.Equal(t, map[string]float64{"cohort": 7}, out[0].FlatUserProperties.Float)
+
+	_, err = Tag(field(t, "sequence"), "high")
+	require.ErrorContains(t, err, `cannot tag sequence with high`)
+}
+
+func TestScrub(t *testing.T) {
+	stage, err := Scrub([]Field{field(t, "param_str"), field(t, "properties.count")},
+		[]*regexp.Regexp{regexp.MustCompile(`\d+`)}, "#")
+	require.NoError(t, err)
+	out := run(Chain{stage}, domain.Event{
+		ParamStr:        "room 42, floor 3",
+		TypedProperties: domain.TypedProperties{Int: map[string]int64{"count": 42}},
+	})
+	require.Equal(t, "room #, floor #", out[0].ParamStr)
+	require.Equal(t, map[string]int64{"count": 42}, out[0].TypedProperties.Int)
+}
+
+func TestTruncateIP(t *testing.T) {
+	stage, err := TruncateIP(16, 32)
+	require.NoError(t, err)
+	for addr, want := range map[string]string{
+		"198.51.100.7":   "198.51.0.0",
+		"2001:db8:ff::1": "2001:db8::",
+	} {
+		out := run(Chain{stage}, domain.Event{IP: netip.MustParseAddr(addr)})
+		require.Equal(t, netip.MustParseAddr(want), out[0].IP)
+	}
+	require.Equal(t, []domain.Event{{}}, run(Chain{stage}, domain.Event{}))
+}
+
+func TestRemove(t *testing.T) {
+	stage, err := Remove([]Field{field(t, "ip"), field(t, "param_int"), field(t, "properties.email")})
+	require.NoError(t, err)
+	out := run(Chain{stage}, domain.Event{
+		IP:              netip.MustParseAddr("198.51.100.7"),
+		ParamInt:        7,
+		TypedProperties: domain.TypedProperties{String: map[string]string{"email": "a@b.c", "sku": "a-1"}},
+	})
+	require.False(t, out[0].IP.IsValid())
+	require.Zero(t, out[0].ParamInt)
+	require.Equal(t, map[string]string{"sku": "a-1"}, out[0].TypedProperties.String)
+}
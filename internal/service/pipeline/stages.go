@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+// Rename moves the value of from to to. Events without from, or whose value does not
+// fit to, are passed on unchanged.
+func Rename(from, to Field) (Stage, error) {
+	if !from.writable() || !to.writable() {
+		return nil, fmt.Errorf("cannot rename %s to %s: read-only field", from, to)
+	}
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		if value, ok := from.Get(event); ok && from.path != to.path && to.Set(event, value) {
+			from.Delete(event)
+		}
+		emit(event)
+	}), nil
+}
+
+// Tag sets the field to a constant: a string, int64, float64 or bool.
+func Tag(field Field, value any) (Stage, error) {
+	if !field.writable() {
+		return nil, fmt.Errorf("cannot tag %s: read-only field", field)
+	}
+	switch value.(type) {
+	case string, int64, float64, bool:
+	default:
+		return nil, fmt.Errorf("cannot tag %s with %T", field, value)
+	}
+	if !field.Set(&domain.Event{}, value) {
+		return nil, fmt.Errorf("cannot tag %s with %v", field, value)
+	}
+
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		field.Set(event, value)
+		emit(event)
+	}), nil
+}
+
+// Filter keeps the events matching the expression, or drops them if drop is set.
+// See expr for the syntax.
+func Filter(expression string, drop bool) (Stage, error) {
+	x, err := parseExpr(expression)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", expression, err)
+	}
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		if x.eval(event) != drop {
+			emit(event)
+		}
+	}), nil
+}
+
+// Hash replaces the values of the fields with the hex HMAC-SHA256 of their string
+// form. The same value always hashes the same, so hashed fields can still be counted
+// and joined on.
+func Hash(fields []Field, key []byte) (Stage, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("no fields to hash")
+	}
+	// Unkeyed hashes of ids and addresses are reversed by hashing every candidate,
+	// so the key is required.
+	if len(key) == 0 {
+		return nil, errors.New("missing key")
+	}
+	for _, f := range fields {
+		if !f.textual() {
+			return nil, fmt.Errorf("cannot hash %s: it cannot hold a string", f)
+		}
+	}
+
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		for _, f := range fields {
+			if value, ok := f.Get(event); ok {
+				f.Set(event, hashValue(stringify(value), key))
+			}
+		}
+		emit(event)
+	}), nil
+}
+
+func hashValue(value string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Remove unsets the fields: properties are deleted and top-level fields reset to
+// their zero value.
+func Remove(fields []Field) (Stage, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("no fields to remove")
+	}
+	for _, f := range fields {
+		if !f.writable() {
+			return nil, fmt.Errorf("cannot remove %s: read-only field", f)
+		}
+	}
+
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		for _, f := range fields {
+			f.Delete(event)
+		}
+		emit(event)
+	}), nil
+}
+
+// Scrub replaces the matches of the patterns in the string values of the fields.
+func Scrub(fields []Field, patterns []*regexp.Regexp, replacement string) (Stage, error) {
+	if len(fields) == 0 || len(patterns) == 0 {
+		return nil, errors.New("no fields or patterns to scrub")
+	}
+	for _, f := range fields {
+		if !f.textual() {
+			return nil, fmt.Errorf("cannot scrub %s: it cannot hold a string", f)
+		}
+	}
+
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		for _, f := range fields {
+			value, ok := f.Get(event)
+			s, isString := value.(string)
+			if !ok || !isString {
+				continue
+			}
+			scrubbed := s
+			for _, p := range patterns {
+				scrubbed = p.ReplaceAllLiteralString(scrubbed, replacement)
+			}
+			if scrubbed != s {
+				f.Set(event, scrubbed)
+			}
+		}
+		emit(event)
+	}), nil
+}
+
+// TruncateIP zeroes the host part of the client address, keeping the first v4Bits
+// of IPv4 and v6Bits of IPv6 addresses.
+func TruncateIP(v4Bits, v6Bits int) (Stage, error) {
+	if v4Bits < 0 || v4Bits > 32 || v6Bits < 0 || v6Bits > 128 {
+		return nil, fmt.Errorf("cannot keep %d bits of IPv4 and %d bits of IPv6", v4Bits, v6Bits)
+	}
+	return StageFunc(func(event *domain.Event, emit func(*domain.Event)) {
+		event.IP = TruncateAddr(event.IP, v4Bits, v6Bits)
+		emit(event)
+	}), nil
+}
+
+// TruncateAddr keeps the first v4Bits of an IPv4 and v6Bits of an IPv6 address. An
+// invalid address is returned as is.
+func TruncateAddr(addr netip.Addr, v4Bits, v6Bits int) netip.Addr {
+	bits := v6Bits
+	if addr.Is4() {
+		bits = v4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+	return prefix.Addr()
+}
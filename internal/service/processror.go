@@ -2,8 +2,14 @@ package service
 
 import (
 	"context"
+	"sync/atomic"
+
+	"golang.org/x/sync/semaphore"
 
 	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/schema"
+	"github.com/leshachaplin/datalog/internal/service/pipeline"
+	"github.com/leshachaplin/datalog/internal/useragent"
 	"github.com/leshachaplin/datalog/internal/worker"
 )
 
@@ -17,15 +23,137 @@ type Processor interface {
 }
 
 type Service struct {
+	validation domain.ValidationRules
+	clientTime domain.ClientTimeRules
+	limits     Config
+	inFlight   *semaphore.Weighted
+	executor   *executor
+	// pending counts the events handed to the executor and not yet queued.
+	pending      atomic.Int64
+	schemas      *schema.Registry
+	stages       pipeline.Chain
+	privacy      pipeline.Stage
+	userAgents   *useragent.Parser
 	eventPool    worker.WorkerPool
 	eventStorage Storage
 }
 
-func New(eventPool worker.WorkerPool, eventStorage Storage) *Service {
+type Option func(*Service)
+
+// WithSchemas checks events against the registry. Without it, event properties are
+// stored as strings and numbers, see schema.Infer.
+func WithSchemas(registry *schema.Registry) Option {
+	return func(s *Service) {
+		s.schemas = registry
+	}
+}
+
+// WithStages runs the stages on every valid event, in order, after the origin is
+// stamped onto it. They are appended to the stages of earlier options.
+func WithStages(stages ...pipeline.Stage) Option {
+	return func(s *Service) {
+		s.stages = append(s.stages, stages...)
+	}
+}
+
+// WithPrivacy runs the privacy rules on every event that comes out of the stages,
+// so that no stage sees what they remove and nothing is queued before they apply.
+func WithPrivacy(rules pipeline.Stage) Option {
+	return func(s *Service) {
+		s.privacy = rules
+	}
+}
+
+// WithUserAgents sets domain.Client on every valid event from the request headers,
+// and drops the events of bots if the parser says so.
+func WithUserAgents(parser *useragent.Parser) Option {
+	return func(s *Service) {
+		s.userAgents = parser
+	}
+}
+
+func New(cfg Config, eventPool worker.WorkerPool, eventStorage Storage, options ...Option) *Service {
 	eventPool.Start(eventStorage.StoreEvents)
 
-	return &Service{
+	cfg = cfg.withDefaults()
+	s := &Service{
+		validation:   cfg.Validation,
+		clientTime:   cfg.ClientTime,
+		limits:       cfg,
+		inFlight:     semaphore.NewWeighted(cfg.MaxInFlightBytes),
+		executor:     newExecutor(cfg.IngestWorkers, cfg.IngestQueueDepth),
 		eventPool:    eventPool,
 		eventStorage: eventStorage,
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *Service) maxLineSize() int {
+	if s.limits.MaxLineSize <= 0 {
+		return defaultMaxLineSize
+	}
+	return s.limits.MaxLineSize
+}
+
+func (s *Service) chunkSize() int {
+	if s.limits.ChunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return s.limits.ChunkSize
+}
+
+// acquire reserves n bytes of in-flight memory until release is called. A chunk
+// larger than the whole budget waits for all of it.
+func (s *Service) acquire(ctx context.Context, n int64) (func(), error) {
+	if s.inFlight == nil {
+		return func() {}, nil
+	}
+	if n > s.limits.MaxInFlightBytes {
+		n = s.limits.MaxInFlightBytes
+	}
+	if err := s.inFlight.Acquire(ctx, n); err != nil {
+		return nil, err
+	}
+	return func() { s.inFlight.Release(n) }, nil
+}
+
+// background runs task, which queues the given number of events, on the ingestion
+// executor. Without one, as in tests, it runs in its own goroutine.
+func (s *Service) background(events int, task func()) error {
+	s.pending.Add(int64(events))
+	run := func() {
+		defer s.pending.Add(-int64(events))
+		task()
+	}
+
+	if s.executor == nil {
+		go run()
+		return nil
+	}
+	if err := s.executor.submit(run); err != nil {
+		s.pending.Add(-int64(events))
+		return err
+	}
+	return nil
+}
+
+// Drain stops taking events in the asynchronous mode and waits, until ctx is done,
+// for the events already taken to be queued. It returns the number of events that
+// were not queued by then. Requests must no longer be served when it is called.
+func (s *Service) Drain(ctx context.Context) int {
+	if s.executor != nil {
+		_ = s.executor.stop(ctx)
+	}
+	return int(s.pending.Load())
+}
+
+// QueueStats describes the ingestion executor.
+func (s *Service) QueueStats() QueueStats {
+	if s.executor == nil {
+		return QueueStats{}
+	}
+	return s.executor.stats()
 }
@@ -1,8 +1,10 @@
 package clickhouse
 
 type Config struct {
-	Addr     string
-	DB       string
-	Username string
-	Password string
+	Addr     string `mapstructure:"addr"`
+	DB       string `mapstructure:"db"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// MigrateOnStart applies pending migrations before the server starts.
+	MigrateOnStart bool `mapstructure:"migrate_on_start"`
 }
@@ -2,13 +2,14 @@ package clickhouse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
-	//"github.com/golang-migrate/migrate/v4"
-	//ch "github.com/golang-migrate/migrate/v4/database/clickhouse"
+
+	"github.com/leshachaplin/datalog/assets"
 )
 
 type Clickhouse struct {
@@ -55,19 +56,15 @@ func (c *Clickhouse) Close() error {
 	return c.conn.Close()
 }
 
+// Migrate applies the pending migrations of assets/migrations.
 func (c *Clickhouse) Migrate(ctx context.Context) error {
-	return c.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS events
-		(
-    		client_time DATETIME,
-    		server_time DATETIME,
-    		ip          IPv4,
-    		device_id   String,
-    		device_os   String,
-    		session     String,
-    		sequence    Int16,
-    		event_type  String,
-    		param_int   Int32,
-    		param_str   String
-		) Engine = MergeTree
-		ORDER BY server_time`)
+	migrator, err := NewMigrator(c, assets.Migrations())
+	if err != nil {
+		return err
+	}
+
+	if err = migrator.Up(ctx); err != nil && !errors.Is(err, ErrNoChange) {
+		return err
+	}
+	return nil
 }
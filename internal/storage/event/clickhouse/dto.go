@@ -1,6 +1,7 @@
 package clickhouse
 
 import (
+	"net/netip"
 	"time"
 
 	"github.com/leshachaplin/datalog/internal/domain"
@@ -11,25 +12,60 @@ type eventBatch struct {
 }
 
 type event struct {
-	IP         string `ch:"ip"`
-	ServerTime string `ch:"server_time"`
-	ClientTime string `ch:"client_time"`
-	DeviceID   string `ch:"device_id"`
-	DeviceOS   string `ch:"device_os"`
-	Session    string `ch:"session"`
-	Sequence   int16  `ch:"sequence"`
-	EventType  string `ch:"event_type"`
-	ParamsInt  int32  `ch:"param_int"`
-	ParamStr   string `ch:"param_str"`
+	ProjectID string `ch:"project_id"`
+	// IP is stored as IPv6; the driver maps IPv4 addresses into it.
+	IP         netip.Addr `ch:"ip"`
+	ServerTime string     `ch:"server_time"`
+	ClientTime time.Time  `ch:"client_time"`
+	DeviceID   string     `ch:"device_id"`
+	DeviceOS   string     `ch:"device_os"`
+	Session    string     `ch:"session"`
+	Sequence   int16      `ch:"sequence"`
+	EventType  string     `ch:"event_type"`
+	ParamsInt  int32      `ch:"param_int"`
+	ParamStr   string     `ch:"param_str"`
+
+	// ClockSkewMs is how far ahead of the server the client clock was.
+	ClockSkewMs    int64  `ch:"clock_skew_ms"`
+	ClientTimeFlag string `ch:"client_time_flag"`
+
+	PropertiesString    map[string]string    `ch:"properties_string"`
+	PropertiesInt       map[string]int64     `ch:"properties_int"`
+	PropertiesFloat     map[string]float64   `ch:"properties_float"`
+	PropertiesBool      map[string]bool      `ch:"properties_bool"`
+	PropertiesTimestamp map[string]time.Time `ch:"properties_timestamp"`
+	PropertiesArray     map[string][]string  `ch:"properties_array"`
+
+	UserPropertiesString map[string]string  `ch:"user_properties_string"`
+	UserPropertiesFloat  map[string]float64 `ch:"user_properties_float"`
+
+	GeoCountry string `ch:"geo_country"`
+	GeoRegion  string `ch:"geo_region"`
+	GeoCity    string `ch:"geo_city"`
+	GeoASN     uint32 `ch:"geo_asn"`
+	GeoASOrg   string `ch:"geo_as_org"`
+
+	ClientBrowser        string `ch:"client_browser"`
+	ClientBrowserVersion string `ch:"client_browser_version"`
+	ClientOS             string `ch:"client_os"`
+	ClientOSVersion      string `ch:"client_os_version"`
+	ClientDevice         string `ch:"client_device"`
+	ClientLanguage       string `ch:"client_language"`
+	ClientBot            string `ch:"client_bot"`
+
+	PrivacyVersion string `ch:"privacy_version"`
 }
 
 func eventFromService(batch domain.EventBatch) eventBatch {
 	events := make([]event, len(batch.Events))
 	for i := 0; i < len(batch.Events); i++ {
+		props := batch.Events[i].TypedProperties
+		clientTime, clockSkew, clientTimeFlag := clientTimeOf(&batch.Events[i])
 		events[i] = event{
+			ProjectID:  batch.Events[i].ProjectID,
 			IP:         batch.Events[i].IP,
 			ServerTime: batch.Events[i].ServerTime.Format(time.DateTime),
-			ClientTime: batch.Events[i].ClientTime,
+			ClientTime: clientTime,
 			DeviceID:   batch.Events[i].DeviceID,
 			DeviceOS:   batch.Events[i].DeviceOS,
 			Session:    batch.Events[i].Session,
@@ -37,9 +73,58 @@ func eventFromService(batch domain.EventBatch) eventBatch {
 			EventType:  batch.Events[i].Event,
 			ParamsInt:  int32(batch.Events[i].ParamInt),
 			ParamStr:   batch.Events[i].ParamStr,
+
+			ClockSkewMs:    clockSkew.Milliseconds(),
+			ClientTimeFlag: clientTimeFlag,
+
+			PropertiesString:    orEmpty(props.String),
+			PropertiesInt:       orEmpty(props.Int),
+			PropertiesFloat:     orEmpty(props.Float),
+			PropertiesBool:      orEmpty(props.Bool),
+			PropertiesTimestamp: orEmpty(props.Timestamp),
+			PropertiesArray:     orEmpty(props.Array),
+
+			UserPropertiesString: orEmpty(batch.Events[i].FlatUserProperties.String),
+			UserPropertiesFloat:  orEmpty(batch.Events[i].FlatUserProperties.Float),
+
+			GeoCountry: batch.Events[i].Geo.Country,
+			GeoRegion:  batch.Events[i].Geo.Region,
+			GeoCity:    batch.Events[i].Geo.City,
+			GeoASN:     batch.Events[i].Geo.ASN,
+			GeoASOrg:   batch.Events[i].Geo.ASOrg,
+
+			ClientBrowser:        batch.Events[i].Client.Browser,
+			ClientBrowserVersion: batch.Events[i].Client.BrowserVersion,
+			ClientOS:             batch.Events[i].Client.OS,
+			ClientOSVersion:      batch.Events[i].Client.OSVersion,
+			ClientDevice:         batch.Events[i].Client.Device,
+			ClientLanguage:       batch.Events[i].Client.Language,
+			ClientBot:            batch.Events[i].Client.Bot,
+
+			PrivacyVersion: batch.Events[i].PrivacyVersion,
 		}
 	}
 	return eventBatch{
 		Events: events,
 	}
 }
+
+// clientTimeOf returns the normalized client time of the event. Events queued
+// before ClientTimestamp was set, such as those replayed from the topic or the
+// dead-letter queue, are normalized here with the default rules.
+func clientTimeOf(e *domain.Event) (time.Time, time.Duration, string) {
+	if e.ClientTimestamp.IsZero() {
+		normalized := domain.Event{ClientTime: e.ClientTime, ServerTime: e.ServerTime}
+		normalized.NormalizeClientTime(domain.ClientTimeRules{})
+		e = &normalized
+	}
+	return e.ClientTimestamp, e.ClockSkew, e.ClientTimeFlag
+}
+
+// orEmpty replaces nil maps, which the driver does not accept for Map columns.
+func orEmpty[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return map[string]V{}
+	}
+	return m
+}
@@ -0,0 +1,29 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func TestEventFromService_ClientTime(t *testing.T) {
+	clientTimestamp := time.Date(2023, 5, 31, 10, 0, 0, 0, time.UTC)
+	serverTime := time.Date(2023, 5, 31, 10, 0, 1, 0, time.UTC)
+	batch := eventFromService(domain.EventBatch{Events: []domain.Event{
+		{ClientTime: "2023-05-31 09:00:00", ClientTimestamp: clientTimestamp, ClockSkew: -time.Second, ServerTime: serverTime},
+		// Queued before ClientTimestamp was set.
+		{ClientTime: "2023-05-31T12:00:00.250+02:00", ServerTime: serverTime},
+		{ClientTime: "yesterday", ServerTime: serverTime},
+	}})
+
+	require.Equal(t, clientTimestamp, batch.Events[0].ClientTime)
+	require.Equal(t, int64(-1000), batch.Events[0].ClockSkewMs)
+	require.Equal(t, time.Date(2023, 5, 31, 10, 0, 0, 250e6, time.UTC), batch.Events[1].ClientTime)
+	require.Equal(t, int64(-750), batch.Events[1].ClockSkewMs)
+	require.Empty(t, batch.Events[1].ClientTimeFlag)
+	require.Equal(t, serverTime, batch.Events[2].ClientTime)
+	require.Equal(t, domain.ClientTimeInvalid, batch.Events[2].ClientTimeFlag)
+}
@@ -0,0 +1,145 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leshachaplin/datalog/internal/erasure"
+)
+
+const erasureColumns = `id, device_id, requested_by, reason, tables, status, reissued, error, requested_at, updated_at`
+
+type erasureRow struct {
+	ID          string    `ch:"id"`
+	DeviceID    string    `ch:"device_id"`
+	RequestedBy string    `ch:"requested_by"`
+	Reason      string    `ch:"reason"`
+	Tables      []string  `ch:"tables"`
+	Status      string    `ch:"status"`
+	Reissued    bool      `ch:"reissued"`
+	Error       string    `ch:"error"`
+	RequestedAt time.Time `ch:"requested_at"`
+	UpdatedAt   time.Time `ch:"updated_at"`
+}
+
+func (r erasureRow) erasure() erasure.Erasure {
+	return erasure.Erasure{
+		ID:          r.ID,
+		DeviceID:    r.DeviceID,
+		RequestedBy: r.RequestedBy,
+		Reason:      r.Reason,
+		Tables:      r.Tables,
+		Status:      erasure.Status(r.Status),
+		Reissued:    r.Reissued,
+		Error:       r.Error,
+		RequestedAt: r.RequestedAt.UTC(),
+		UpdatedAt:   r.UpdatedAt.UTC(),
+	}
+}
+
+// DeleteDevice implements erasure.Store. The table is a name of the current
+// database, checked by config validation.
+func (c *Clickhouse) DeleteDevice(ctx context.Context, table, deviceID string, lightweight bool) error {
+	query := `ALTER TABLE ` + table + ` DELETE WHERE device_id = ?`
+	if lightweight {
+		query = `DELETE FROM ` + table + ` WHERE device_id = ?`
+	}
+	return c.conn.Exec(ctx, query, deviceID)
+}
+
+// DeleteProgress implements erasure.Store. Both kinds of deletes are mutations, and
+// they are told apart from others by the quoted device ID in their command.
+func (c *Clickhouse) DeleteProgress(ctx context.Context, table, deviceID string) (bool, string, error) {
+	var (
+		running uint64
+		failure string
+	)
+	row := c.conn.QueryRow(ctx, `
+		SELECT countIf(NOT is_done), anyIf(latest_fail_reason, NOT is_done AND latest_fail_reason != '')
+		FROM system.mutations
+		WHERE database = currentDatabase() AND table = ? AND position(command, ?) > 0`,
+		table, quote(deviceID))
+	if err := row.Scan(&running, &failure); err != nil {
+		return false, "", fmt.Errorf("query mutations: %w", err)
+	}
+	return running > 0, failure, nil
+}
+
+// SaveErasure implements erasure.Store. Records of an erasure replace each other
+// by updated_at.
+func (c *Clickhouse) SaveErasure(ctx context.Context, e erasure.Erasure) error {
+	batch, err := c.conn.PrepareBatch(ctx, `INSERT INTO erasures (`+erasureColumns+`)`)
+	if err != nil {
+		return err
+	}
+	err = batch.AppendStruct(&erasureRow{
+		ID:          e.ID,
+		DeviceID:    e.DeviceID,
+		RequestedBy: e.RequestedBy,
+		Reason:      e.Reason,
+		Tables:      e.Tables,
+		Status:      string(e.Status),
+		Reissued:    e.Reissued,
+		Error:       e.Error,
+		RequestedAt: e.RequestedAt,
+		UpdatedAt:   e.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return batch.Send()
+}
+
+// Erasure implements erasure.Store.
+func (c *Clickhouse) Erasure(ctx context.Context, id string) (erasure.Erasure, error) {
+	var rows []erasureRow
+	if err := c.conn.Select(ctx, &rows, `SELECT `+erasureColumns+` FROM erasures FINAL WHERE id = ?`, id); err != nil {
+		return erasure.Erasure{}, fmt.Errorf("query erasure: %w", err)
+	}
+	if len(rows) == 0 {
+		return erasure.Erasure{}, erasure.ErrNotFound
+	}
+	return rows[0].erasure(), nil
+}
+
+// Erasures implements erasure.Store.
+func (c *Clickhouse) Erasures(ctx context.Context, since time.Time) ([]erasure.Erasure, error) {
+	var rows []erasureRow
+	if err := c.conn.Select(ctx, &rows, `SELECT `+erasureColumns+` FROM erasures FINAL WHERE requested_at >= ?`, since); err != nil {
+		return nil, fmt.Errorf("query erasures: %w", err)
+	}
+
+	erasures := make([]erasure.Erasure, 0, len(rows))
+	for _, row := range rows {
+		erasures = append(erasures, row.erasure())
+	}
+	return erasures, nil
+}
+
+// ClaimReissue implements erasure.Store. Claims are inserted with the time of the
+// server, and the earliest of them wins; a later claim sees the earlier one when
+// the claims are read back.
+func (c *Clickhouse) ClaimReissue(ctx context.Context, id, claim string) (bool, error) {
+	if err := c.conn.Exec(ctx, `INSERT INTO erasure_claims (id, claim) VALUES (?, ?)`, id, claim); err != nil {
+		return false, fmt.Errorf("insert claim: %w", err)
+	}
+
+	var first string
+	row := c.conn.QueryRow(ctx, `
+		SELECT claim
+		FROM erasure_claims
+		WHERE id = ?
+		ORDER BY claimed_at, claim
+		LIMIT 1`, id)
+	if err := row.Scan(&first); err != nil {
+		return false, fmt.Errorf("query claims: %w", err)
+	}
+	return first == claim, nil
+}
+
+// quote writes a string literal the way ClickHouse formats it in commands.
+func quote(s string) string {
+	return `'` + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + `'`
+}
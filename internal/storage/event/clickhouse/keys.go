@@ -0,0 +1,36 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeysTable reads API keys from a table shaped like api_keys in assets/migrations.
+// A key is revoked by inserting it again with revoked set.
+type KeysTable struct {
+	c     *Clickhouse
+	table string
+}
+
+func NewKeysTable(c *Clickhouse, table string) *KeysTable {
+	return &KeysTable{c: c, table: table}
+}
+
+// Keys implements auth.Source.
+func (k *KeysTable) Keys(ctx context.Context) (map[string]string, error) {
+	rows, err := k.c.conn.Query(ctx, `SELECT key, project_id FROM `+k.table+` FINAL WHERE NOT revoked`)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]string)
+	for rows.Next() {
+		var key, projectID string
+		if err = rows.Scan(&key, &projectID); err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys[key] = projectID
+	}
+	return keys, rows.Err()
+}
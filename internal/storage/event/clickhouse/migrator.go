@@ -1,42 +1,485 @@
 package clickhouse
 
-//type Migrator struct {
-//	migrator *chmigrate.Migrator
-//}
-//
-//func NewMigrator(cfg Config) (*Migrator, error) {
-//	db := ch.Connect(
-//		ch.WithDSN(fmt.Sprintf("clickhouse://%s/%s?sslmode=disable", cfg.Addr, cfg.DB)),
-//		ch.WithUser(cfg.Username),
-//		ch.WithPassword(cfg.Password),
-//	)
-//	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-//	defer cancel()
-//	if err := db.Ping(ctx); err != nil {
-//		return nil, err
-//	}
-//
-//	return &Migrator{
-//		migrator: chmigrate.NewMigrator(db, migrations.Migrations),
-//	}, nil
-//}
-//
-//func (m *Migrator) MigrateDB(ctx context.Context) error {
-//	if err := m.migrator.Lock(ctx); err != nil {
-//		return err
-//	}
-//	defer m.migrator.Unlock(ctx)
-//
-//	group, err := m.migrator.Migrate(ctx)
-//	if err != nil {
-//		return err
-//	}
-//
-//	if group.IsZero() {
-//		log.Info().Msg("there are no new migrations to run (database is up to date)")
-//		return nil
-//	}
-//	log.Info().Msg(fmt.Sprintf("migrated to %s", group))
-//
-//	return nil
-//}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	migrationsTable = "schema_migrations"
+	lockTable       = "schema_migrations_lock"
+
+	defaultLockTimeout = 5 * time.Minute
+	// staleLockAge is the age after which a lock is considered abandoned by a
+	// replica that crashed while migrating.
+	staleLockAge      = 30 * time.Minute
+	lockRetryInterval = time.Second
+)
+
+var (
+	ErrDirty    = errors.New("database is dirty")
+	ErrNoChange = errors.New("no change")
+
+	migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+)
+
+// Migration is a versioned schema change with its rollback.
+type Migration struct {
+	Version uint32
+	Name    string
+	up      []string
+	down    []string
+}
+
+// MigrationStatus is a known migration and its state in the database.
+type MigrationStatus struct {
+	Version uint32
+	Name    string
+	Applied bool
+	Dirty   bool
+	// Done is how many statements of a dirty migration completed. They are skipped
+	// when a migration up is resumed.
+	Done      uint32
+	UpdatedAt time.Time
+}
+
+// Migrator applies the migrations of an fs.FS and tracks applied versions in the
+// schema_migrations table. Every operation holds a lock, so replicas starting at the
+// same time do not apply a migration twice.
+type Migrator struct {
+	conn        driver.Conn
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+func NewMigrator(c *Clickhouse, migrations fs.FS) (*Migrator, error) {
+	parsed, err := parseMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		conn:        c.conn,
+		migrations:  parsed,
+		lockTimeout: defaultLockTimeout,
+	}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return ErrNoChange
+	}
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		current, err := m.current(ctx)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return ErrNoChange
+		}
+
+		var target uint32
+		for _, migration := range m.migrations {
+			if migration.Version < current {
+				target = migration.Version
+			}
+		}
+		return m.migrate(ctx, current, target)
+	})
+}
+
+// To migrates up or down until version is the latest applied migration. Version 0
+// rolls everything back.
+func (m *Migrator) To(ctx context.Context, version uint32) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migration %d does not exist", version)
+	}
+
+	return m.locked(ctx, func(ctx context.Context) error {
+		resumed, err := m.resume(ctx, version)
+		if err != nil {
+			return err
+		}
+		current, err := m.current(ctx)
+		if err != nil {
+			return err
+		}
+		if current == version {
+			if resumed {
+				return nil
+			}
+			return ErrNoChange
+		}
+		return m.migrate(ctx, current, version)
+	})
+}
+
+// resume finishes a migration up that stopped halfway, from its first statement
+// that did not complete, when migrating up to at least its version. Other dirty
+// states are left to be fixed by hand.
+func (m *Migrator) resume(ctx context.Context, target uint32) (bool, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for version, status := range applied {
+		if !status.Dirty || status.Applied || version > target {
+			continue
+		}
+		migration := m.find(version)
+		if migration == nil {
+			continue
+		}
+		log.Info().Uint32("version", version).Uint32("done", status.Done).Msg("Resuming a migration that stopped halfway.")
+		return true, m.apply(ctx, *migration, true, status.Done)
+	}
+	return false, nil
+}
+
+// Force marks version as the latest cleanly applied migration without running
+// anything. It is the way out of a dirty state after fixing the database by hand.
+func (m *Migrator) Force(ctx context.Context, version uint32) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migration %d does not exist", version)
+	}
+
+	return m.locked(ctx, func(ctx context.Context) error {
+		for _, migration := range m.migrations {
+			if err := m.record(ctx, migration, migration.Version <= version, false, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists the known migrations and whether they are applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = applied[migration.Version]
+		statuses[i].Version = migration.Version
+		statuses[i].Name = migration.Name
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, from, to uint32) error {
+	if to > from {
+		for _, migration := range m.migrations {
+			if migration.Version <= from || migration.Version > to {
+				continue
+			}
+			if err := m.apply(ctx, migration, true, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > from || migration.Version <= to {
+			continue
+		}
+		if err := m.apply(ctx, migration, false, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs one direction of a migration from its statement at index from.
+// ClickHouse DDL is not transactional, so the version is marked dirty first and
+// stays dirty if a statement fails. The statements that completed are recorded one
+// by one, since most of them cannot run twice: a migration up is resumed after them.
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool, from uint32) error {
+	statements, direction := migration.down, "down"
+	if up {
+		statements, direction = migration.up, "up"
+	}
+	if from > uint32(len(statements)) {
+		from = uint32(len(statements))
+	}
+
+	log.Info().Uint32("version", migration.Version).Str("name", migration.Name).Msgf("Migrating %s.", direction)
+	if err := m.record(ctx, migration, !up, true, from); err != nil {
+		return err
+	}
+
+	// Mutations, such as backfills, finish before the next statement runs.
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+	for i := from; i < uint32(len(statements)); i++ {
+		if err := m.conn.Exec(ctx, statements[i]); err != nil {
+			return fmt.Errorf("migration %d_%s %s: statement %d: %w", migration.Version, migration.Name, direction, i+1, err)
+		}
+		if err := m.record(ctx, migration, !up, true, i+1); err != nil {
+			return err
+		}
+	}
+
+	return m.record(ctx, migration, up, false, 0)
+}
+
+func (m *Migrator) current(ctx context.Context) (uint32, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var current uint32
+	for version, status := range applied {
+		if status.Dirty {
+			return 0, fmt.Errorf("%w at version %d, fix it by hand and force a version", ErrDirty, version)
+		}
+		if status.Applied && version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[uint32]MigrationStatus, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, name, applied, dirty, done, updated_at
+		FROM `+migrationsTable+` FINAL`)
+	if err != nil {
+		return nil, fmt.Errorf("query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[uint32]MigrationStatus)
+	for rows.Next() {
+		var (
+			status         MigrationStatus
+			isApplied, dty uint8
+		)
+		if err = rows.Scan(&status.Version, &status.Name, &isApplied, &dty, &status.Done, &status.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan migration: %w", err)
+		}
+		status.Applied, status.Dirty = isApplied == 1, dty == 1
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) record(ctx context.Context, migration Migration, applied, dirty bool, done uint32) error {
+	err := m.conn.Exec(ctx, `INSERT INTO `+migrationsTable+` (version, name, applied, dirty, done, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		migration.Version, migration.Name, boolToUInt8(applied), boolToUInt8(dirty), done, time.Now())
+	if err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// ensureTable creates the migrations table, and adds the done column to tables
+// created without it.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	err := m.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+`
+		(
+			version    UInt32,
+			name       String,
+			applied    UInt8,
+			dirty      UInt8,
+			done       UInt32,
+			updated_at DateTime64(3)
+		) Engine = ReplacingMergeTree(updated_at)
+		ORDER BY version`)
+	if err != nil {
+		return err
+	}
+	return m.conn.Exec(ctx, `ALTER TABLE `+migrationsTable+` ADD COLUMN IF NOT EXISTS done UInt32 AFTER dirty`)
+}
+
+// locked runs fn while holding the migration lock. ClickHouse has no advisory locks,
+// but CREATE TABLE without IF NOT EXISTS succeeds for exactly one caller, so the lock
+// is a table that exists while a migration runs.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	owner := uuid.NewString()
+	if err := m.lock(ctx, owner); err != nil {
+		return err
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := m.conn.Exec(unlockCtx, `DROP TABLE IF EXISTS `+lockTable); err != nil {
+			log.Error().Err(err).Msg("Failed to release the migration lock.")
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func (m *Migrator) lock(ctx context.Context, owner string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+
+	for {
+		err := m.conn.Exec(ctx, `CREATE TABLE `+lockTable+` (owner String, locked_at DateTime) Engine = Log`)
+		if err == nil {
+			if err = m.conn.Exec(ctx, `INSERT INTO `+lockTable+` VALUES (?, ?)`, owner, time.Now()); err != nil {
+				return fmt.Errorf("record migration lock: %w", err)
+			}
+			return nil
+		}
+		if !isTableExists(err) {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+
+		if m.releaseStaleLock(ctx) {
+			continue
+		}
+
+		log.Info().Msg("Waiting for another replica to finish migrating.")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("acquire migration lock: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// releaseStaleLock drops a lock left behind by a crashed process. The lock table is
+// empty between its creation and the insert of its row, and min(locked_at) is then
+// 1970-01-01, so an empty lock is aged by the creation of the table instead.
+func (m *Migrator) releaseStaleLock(ctx context.Context) bool {
+	var (
+		rows     uint64
+		lockedAt time.Time
+	)
+	if err := m.conn.QueryRow(ctx, `SELECT count(), min(locked_at) FROM `+lockTable).Scan(&rows, &lockedAt); err != nil {
+		return false
+	}
+	if rows == 0 {
+		err := m.conn.QueryRow(ctx, `
+			SELECT metadata_modification_time FROM system.tables
+			WHERE database = currentDatabase() AND name = ?`, lockTable).Scan(&lockedAt)
+		if err != nil {
+			return false
+		}
+	}
+	if lockedAt.Unix() <= 0 || time.Since(lockedAt) < staleLockAge {
+		return false
+	}
+
+	log.Warn().Time("locked_at", lockedAt).Msg("Releasing a stale migration lock.")
+	return m.conn.Exec(ctx, `DROP TABLE IF EXISTS `+lockTable) == nil
+}
+
+func (m *Migrator) find(version uint32) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func parseMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint32]*Migration)
+	for _, entry := range entries {
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+
+		migration, ok := byVersion[uint32(version)]
+		if !ok {
+			migration = &Migration{Version: uint32(version), Name: match[2]}
+			byVersion[uint32(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		b, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			migration.up = splitStatements(string(b))
+		} else {
+			migration.down = splitStatements(string(b))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == nil || migration.down == nil {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements splits a file on semicolons, since the native protocol runs one
+// statement per query. Migrations must not contain semicolons inside literals.
+func splitStatements(sql string) []string {
+	statements := make([]string, 0)
+	for _, statement := range strings.Split(sql, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+func isTableExists(err error) bool {
+	const tableAlreadyExists = 57
+
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception) && exception.Code == tableAlreadyExists
+}
+
+func boolToUInt8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,223 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/assets"
+)
+
+func TestParseMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_second.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN a UInt8;\nALTER TABLE t ADD COLUMN b UInt8;\n")},
+		"002_second.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN b; ALTER TABLE t DROP COLUMN a;")},
+		"001_first.up.sql":    {Data: []byte("CREATE TABLE t (id UInt8) Engine = Memory")},
+		"001_first.down.sql":  {Data: []byte("DROP TABLE t;")},
+		"README.md":           {Data: []byte("not a migration")},
+	}
+
+	migrations, err := parseMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, uint32(1), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, []string{"CREATE TABLE t (id UInt8) Engine = Memory"}, migrations[0].up)
+	assert.Equal(t, []string{"DROP TABLE t"}, migrations[0].down)
+
+	assert.Equal(t, uint32(2), migrations[1].Version)
+	assert.Equal(t, []string{"ALTER TABLE t ADD COLUMN a UInt8", "ALTER TABLE t ADD COLUMN b UInt8"}, migrations[1].up)
+}
+
+func TestParseMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"001_first.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"zero version": {
+			"000_first.up.sql":   {Data: []byte("SELECT 1")},
+			"000_first.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"conflicting names": {
+			"001_first.up.sql":   {Data: []byte("SELECT 1")},
+			"001_other.down.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseMigrations(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseMigrations_Embedded(t *testing.T) {
+	migrations, err := parseMigrations(assets.Migrations())
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, uint32(i+1), migration.Version, "versions must be contiguous")
+		assert.NotEmpty(t, migration.up)
+		assert.NotEmpty(t, migration.down)
+	}
+}
+
+func TestMigrator_ReleaseStaleLock(t *testing.T) {
+	epoch := time.Unix(0, 0).UTC()
+	tests := map[string]struct {
+		rows      uint64
+		lockedAt  time.Time
+		createdAt time.Time
+		released  bool
+	}{
+		"fresh":  {rows: 1, lockedAt: time.Now().Add(-time.Minute)},
+		"stale":  {rows: 1, lockedAt: time.Now().Add(-time.Hour), released: true},
+		"empty":  {lockedAt: epoch, createdAt: time.Now()},
+		"zombie": {lockedAt: epoch, createdAt: time.Now().Add(-time.Hour), released: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := &lockConn{rows: tt.rows, lockedAt: tt.lockedAt, createdAt: tt.createdAt}
+			m := &Migrator{conn: conn}
+			require.Equal(t, tt.released, m.releaseStaleLock(context.Background()))
+			require.Equal(t, tt.released, conn.dropped)
+		})
+	}
+}
+
+// lockConn answers the queries of releaseStaleLock.
+type lockConn struct {
+	driver.Conn
+	rows      uint64
+	lockedAt  time.Time
+	createdAt time.Time
+	dropped   bool
+}
+
+func (c *lockConn) QueryRow(_ context.Context, query string, _ ...any) driver.Row {
+	if strings.Contains(query, "system.tables") {
+		return lockRow{values: []any{c.createdAt}}
+	}
+	return lockRow{values: []any{c.rows, c.lockedAt}}
+}
+
+func (c *lockConn) Exec(_ context.Context, query string, _ ...any) error {
+	c.dropped = strings.HasPrefix(query, "DROP TABLE")
+	return nil
+}
+
+type lockRow struct {
+	driver.Row
+	values []any
+}
+
+func (r lockRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *uint64:
+			*d = r.values[i].(uint64)
+		case *time.Time:
+			*d = r.values[i].(time.Time)
+		}
+	}
+	return nil
+}
+
+func TestMigrator_Resume(t *testing.T) {
+	conn := &migrationConn{statuses: make(map[uint32][]any), fail: "ALTER TABLE t ADD COLUMN c UInt8"}
+	m := &Migrator{conn: conn, lockTimeout: time.Second, migrations: []Migration{
+		{Version: 1, Name: "first", up: []string{"CREATE TABLE t (id UInt8) Engine = Memory"}},
+		{Version: 2, Name: "second", up: []string{
+			"ALTER TABLE t ADD COLUMN a UInt8",
+			"ALTER TABLE t RENAME COLUMN a TO b",
+			"ALTER TABLE t ADD COLUMN c UInt8",
+		}},
+	}}
+	ctx := context.Background()
+
+	require.ErrorContains(t, m.Up(ctx), "migration 2_second up: statement 3: table is read-only")
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[1].Dirty)
+	require.Equal(t, uint32(2), statuses[1].Done)
+	require.ErrorIs(t, m.Down(ctx), ErrDirty)
+
+	// The rename cannot run twice, so migrating up resumes after it.
+	conn.fail = ""
+	conn.executed = nil
+	require.NoError(t, m.Up(ctx))
+	require.Equal(t, []string{"ALTER TABLE t ADD COLUMN c UInt8"}, conn.executed)
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[1].Applied)
+	require.False(t, statuses[1].Dirty)
+	require.ErrorIs(t, m.Up(ctx), ErrNoChange)
+}
+
+// migrationConn keeps the records of the migrations table in memory, and records
+// the statements of migrations.
+type migrationConn struct {
+	driver.Conn
+	statuses map[uint32][]any
+	executed []string
+	// fail is a statement that fails.
+	fail string
+}
+
+func (c *migrationConn) Exec(_ context.Context, query string, args ...any) error {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO "+migrationsTable+" "):
+		c.statuses[args[0].(uint32)] = args
+	case strings.Contains(query, migrationsTable):
+	case query == c.fail:
+		return errors.New("table is read-only")
+	default:
+		c.executed = append(c.executed, query)
+	}
+	return nil
+}
+
+func (c *migrationConn) Query(context.Context, string, ...any) (driver.Rows, error) {
+	rows := &migrationRows{}
+	for _, status := range c.statuses {
+		rows.rows = append(rows.rows, status)
+	}
+	return rows, nil
+}
+
+type migrationRows struct {
+	driver.Rows
+	rows [][]any
+	row  []any
+}
+
+func (r *migrationRows) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	r.row, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *migrationRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.row[i]))
+	}
+	return nil
+}
+
+func (r *migrationRows) Close() error { return nil }
+
+func (r *migrationRows) Err() error { return nil }
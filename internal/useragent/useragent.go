@@ -0,0 +1,268 @@
+package useragent
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+// BotPolicy tells what happens to events sent by bots.
+type BotPolicy string
+
+const (
+	// BotsFlag keeps the events, with the name of the matching rule in Client.Bot.
+	BotsFlag BotPolicy = "flag"
+	BotsDrop BotPolicy = "drop"
+)
+
+// Device classes.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// maxHeaderLength bounds the part of a header that is parsed.
+const maxHeaderLength = 1024
+
+type Config struct {
+	// BotRules is a YAML file of bot rules, see Rule. It replaces the built-in rules.
+	BotRules string `mapstructure:"bot_rules"`
+	// Bots is flag or drop; flag by default.
+	Bots BotPolicy `mapstructure:"bots"`
+}
+
+// Rule flags a User-Agent matching Pattern, a regular expression, as the bot Name:
+//
+//	bots:
+//	  - name: googlebot
+//	    pattern: (?i)googlebot
+type Rule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// DefaultRules catch well-known crawlers, monitors and HTTP libraries. Mobile HTTP
+// stacks such as okhttp and CFNetwork are left out, since SDKs send events with them.
+var DefaultRules = []Rule{
+	{Name: "googlebot", Pattern: `(?i)googlebot|google-inspectiontool|adsbot-google|mediapartners-google`},
+	{Name: "bingbot", Pattern: `(?i)bingbot|bingpreview|msnbot`},
+	{Name: "yandexbot", Pattern: `(?i)yandex(bot|images|metrika)`},
+	{Name: "baiduspider", Pattern: `(?i)baiduspider`},
+	{Name: "duckduckbot", Pattern: `(?i)duckduckbot`},
+	{Name: "applebot", Pattern: `(?i)applebot`},
+	{Name: "facebook", Pattern: `(?i)facebookexternalhit|facebookcatalog|meta-externalagent`},
+	{Name: "twitterbot", Pattern: `(?i)twitterbot`},
+	{Name: "linkedinbot", Pattern: `(?i)linkedinbot`},
+	{Name: "slackbot", Pattern: `(?i)slackbot|slack-imgproxy`},
+	{Name: "seo", Pattern: `(?i)ahrefsbot|semrushbot|mj12bot|dotbot|petalbot`},
+	{Name: "headless", Pattern: `HeadlessChrome|PhantomJS|Lighthouse`},
+	{Name: "http-client", Pattern: `(?i)^(curl|wget|python-requests|python-urllib|go-http-client|java/|apache-httpclient|axios|node-fetch)`},
+	{Name: "crawler", Pattern: `(?i)bot/|\bbot\b|crawler|spider|scraper`},
+}
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Parser tells clients apart by their headers. It is safe for concurrent use.
+type Parser struct {
+	bots     []rule
+	dropBots bool
+}
+
+func New(cfg Config) (*Parser, error) {
+	p := &Parser{}
+	switch cfg.Bots {
+	case "", BotsFlag:
+	case BotsDrop:
+		p.dropBots = true
+	default:
+		return nil, fmt.Errorf("bots: must be %s or %s, got %q", BotsFlag, BotsDrop, cfg.Bots)
+	}
+
+	rules := DefaultRules
+	if cfg.BotRules != "" {
+		var err error
+		if rules, err = readRules(cfg.BotRules); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range rules {
+		if r.Name == "" || r.Pattern == "" {
+			return nil, fmt.Errorf("bot rule %d: name and pattern are required", i)
+		}
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bot rule %s: %w", r.Name, err)
+		}
+		p.bots = append(p.bots, rule{name: r.Name, pattern: pattern})
+	}
+	return p, nil
+}
+
+func readRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read bot rules %s: %w", path, err)
+	}
+
+	var f struct {
+		Bots []Rule `yaml:"bots"`
+	}
+	if err = yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode bot rules %s: %w", path, err)
+	}
+	return f.Bots, nil
+}
+
+// DropBots reports whether events of bots are dropped rather than flagged.
+func (p *Parser) DropBots() bool {
+	return p.dropBots
+}
+
+// Parse describes the client of a request from its User-Agent and Accept-Language
+// headers.
+func (p *Parser) Parse(userAgent, acceptLanguage string) domain.Client {
+	userAgent = truncate(userAgent)
+	client := domain.Client{Language: preferredLanguage(truncate(acceptLanguage))}
+	client.Browser, client.BrowserVersion = match(browsers, userAgent)
+	client.OS, client.OSVersion = match(systems, userAgent)
+
+	for _, r := range p.bots {
+		if r.pattern.MatchString(userAgent) {
+			client.Bot = r.name
+			break
+		}
+	}
+	client.Device = device(client, userAgent)
+	return client
+}
+
+func truncate(header string) string {
+	if len(header) > maxHeaderLength {
+		return header[:maxHeaderLength]
+	}
+	return header
+}
+
+// family is a browser or an operating system, recognized by a pattern whose first
+// group, if any, is the version.
+type family struct {
+	name    string
+	pattern *regexp.Regexp
+	// version rewrites the matched version, such as Windows NT 6.1 into 7.
+	version func(string) string
+}
+
+// browsers are checked in order: Chromium-based browsers claim to be Chrome and
+// Safari too, and Chrome claims to be Safari.
+var browsers = []family{
+	{name: "Edge", pattern: regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+	{name: "Opera", pattern: regexp.MustCompile(`(?:OPR|Opera)/([\d.]+)`)},
+	{name: "Samsung Internet", pattern: regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{name: "Yandex Browser", pattern: regexp.MustCompile(`YaBrowser/([\d.]+)`)},
+	{name: "Firefox", pattern: regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{name: "Chrome", pattern: regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{name: "Safari", pattern: regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{name: "Internet Explorer", pattern: regexp.MustCompile(`MSIE ([\d.]+)|Trident/.*rv:([\d.]+)`)},
+}
+
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+var systems = []family{
+	{name: "Windows", pattern: regexp.MustCompile(`Windows NT ([\d.]+)`), version: func(v string) string {
+		if name, ok := windowsVersions[v]; ok {
+			return name
+		}
+		return v
+	}},
+	{name: "iOS", pattern: regexp.MustCompile(`(?:iPhone|iPad|iPod).*? OS ([\d_]+)`)},
+	{name: "Android", pattern: regexp.MustCompile(`Android ([\d.]+)`)},
+	{name: "Chrome OS", pattern: regexp.MustCompile(`CrOS \S+ ([\d.]+)`)},
+	{name: "macOS", pattern: regexp.MustCompile(`Mac OS X ([\d_.]+)`)},
+	{name: "Linux", pattern: regexp.MustCompile(`Linux`)},
+}
+
+// match returns the first family matching the User-Agent and its version, cut to
+// major.minor so that the stored values stay few.
+func match(families []family, userAgent string) (string, string) {
+	for _, f := range families {
+		groups := f.pattern.FindStringSubmatch(userAgent)
+		if groups == nil {
+			continue
+		}
+
+		var version string
+		for _, g := range groups[1:] {
+			if g != "" {
+				version = g
+				break
+			}
+		}
+		version = majorMinor(strings.ReplaceAll(version, "_", "."))
+		if f.version != nil {
+			version = f.version(version)
+		}
+		return f.name, version
+	}
+	return "", ""
+}
+
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, ".")
+}
+
+var (
+	tablet = regexp.MustCompile(`iPad|Tablet|Kindle|Silk/|PlayBook`)
+	mobile = regexp.MustCompile(`Mobi|iPhone|iPod|Android|Windows Phone|BlackBerry|Opera Mini`)
+)
+
+func device(client domain.Client, userAgent string) string {
+	switch {
+	case client.Bot != "":
+		return DeviceBot
+	case tablet.MatchString(userAgent):
+		return DeviceTablet
+	case client.OS == "Android" && !strings.Contains(userAgent, "Mobile"):
+		// Android tablets leave Mobile out of their User-Agent.
+		return DeviceTablet
+	case mobile.MatchString(userAgent):
+		return DeviceMobile
+	case client.OS == "Windows" || client.OS == "macOS" || client.OS == "Linux" || client.OS == "Chrome OS":
+		return DeviceDesktop
+	}
+	return ""
+}
+
+// preferredLanguage returns the language tag with the highest quality, or "" when
+// the header is empty or malformed.
+func preferredLanguage(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return ""
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+	return tags[0].String()
+}
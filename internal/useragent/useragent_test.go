@@ -0,0 +1,117 @@
+package useragent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		want           domain.Client
+	}{
+		{
+			name:           "chrome on windows",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36",
+			acceptLanguage: "de-DE,de;q=0.9,en;q=0.8",
+			want: domain.Client{
+				Browser: "Chrome", BrowserVersion: "120.0", OS: "Windows", OSVersion: "10",
+				Device: DeviceDesktop, Language: "de-DE",
+			},
+		},
+		{
+			name:           "safari on iphone",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			acceptLanguage: "en;q=0.5, fr-CA",
+			want: domain.Client{
+				Browser: "Safari", BrowserVersion: "17.1", OS: "iOS", OSVersion: "17.1",
+				Device: DeviceMobile, Language: "fr-CA",
+			},
+		},
+		{
+			name:      "edge on macos",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.61",
+			want: domain.Client{
+				Browser: "Edge", BrowserVersion: "120.0", OS: "macOS", OSVersion: "10.15",
+				Device: DeviceDesktop,
+			},
+		},
+		{
+			name:      "firefox on android tablet",
+			userAgent: "Mozilla/5.0 (Android 13; Tablet; rv:121.0) Gecko/121.0 Firefox/121.0",
+			want: domain.Client{
+				Browser: "Firefox", BrowserVersion: "121.0", OS: "Android", OSVersion: "13",
+				Device: DeviceTablet,
+			},
+		},
+		{
+			name:      "samsung internet on android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			want: domain.Client{
+				Browser: "Samsung Internet", BrowserVersion: "23.0", OS: "Android", OSVersion: "14",
+				Device: DeviceMobile,
+			},
+		},
+		{
+			name:      "googlebot",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      domain.Client{Device: DeviceBot, Bot: "googlebot"},
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.4.0",
+			want:      domain.Client{Device: DeviceBot, Bot: "http-client"},
+		},
+		{
+			name:      "android sdk",
+			userAgent: "okhttp/4.12.0",
+			want:      domain.Client{},
+		},
+		{
+			name:           "malformed language",
+			acceptLanguage: "!!!",
+			want:           domain.Client{},
+		},
+	}
+
+	p, err := New(Config{})
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, p.Parse(tt.userAgent, tt.acceptLanguage))
+		})
+	}
+}
+
+func TestNew_BotRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bots.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+bots:
+  - name: monitor
+    pattern: ^UptimeChecker/
+  - name: empty
+    pattern: ^$
+`), 0o600))
+
+	p, err := New(Config{BotRules: path, Bots: BotsDrop})
+	require.NoError(t, err)
+	require.True(t, p.DropBots())
+	require.Equal(t, "monitor", p.Parse("UptimeChecker/1.0", "").Bot)
+	require.Equal(t, "empty", p.Parse("", "").Bot)
+	// The rules replace the built-in ones.
+	require.Empty(t, p.Parse("curl/8.4.0", "").Bot)
+
+	require.NoError(t, os.WriteFile(path, []byte("bots:\n  - name: broken\n    pattern: '('\n"), 0o600))
+	_, err = New(Config{BotRules: path})
+	require.ErrorContains(t, err, "bot rule broken")
+
+	_, err = New(Config{Bots: "block"})
+	require.ErrorContains(t, err, "bots: must be flag or drop")
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
+)
+
+// batch merges consumed messages into a single EventBatch, so that storage receives
+// one large insert instead of one insert per HTTP request.
+type batch struct {
+	messages []consumer.Message
+	events   int
+	size     int
+}
+
+func (b *batch) add(msg consumer.Message) {
+	b.messages = append(b.messages, msg)
+	b.events += len(msg.Batch.Events)
+	b.size += msg.Size
+}
+
+func (b *batch) empty() bool {
+	return len(b.messages) == 0
+}
+
+// full reports whether the batch reached the event count or the byte size limit.
+// A zero maxBytes disables the size limit.
+func (b *batch) full(maxEvents, maxBytes int) bool {
+	return b.events >= maxEvents || (maxBytes > 0 && b.size >= maxBytes)
+}
+
+func (b *batch) eventBatch() domain.EventBatch {
+	events := make([]domain.Event, 0, b.events)
+	for _, msg := range b.messages {
+		events = append(events, msg.Batch.Events...)
+	}
+
+	return domain.EventBatch{
+		ID:     uuid.NewString(),
+		Events: events,
+	}
+}
@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/worker/deadletter"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
+)
+
+type sliceQueue struct {
+	messages []consumer.Message
+}
+
+func (q *sliceQueue) Publish(context.Context, string, any) error {
+	return nil
+}
+
+func (q *sliceQueue) Consume(ctx context.Context, taskPayload chan<- consumer.Message, done <-chan struct{}) {
+	for _, msg := range q.messages {
+		taskPayload <- msg
+	}
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+}
+
+func newMessages(n, eventsPerMessage int) []consumer.Message {
+	messages := make([]consumer.Message, n)
+	for i := range messages {
+		messages[i] = consumer.Message{
+			Batch: domain.EventBatch{Events: make([]domain.Event, eventsPerMessage)},
+			Size:  100,
+		}
+	}
+	return messages
+}
+
+func TestPool_Batching(t *testing.T) {
+	cases := map[string]struct {
+		cfg      Config
+		messages []consumer.Message
+		expected []int
+	}{
+		"flush by event count and on stop": {
+			cfg:      Config{NumWorkers: 1, BatchSize: 10, BatchLinger: time.Hour},
+			messages: newMessages(25, 1),
+			expected: []int{10, 10, 5},
+		},
+		"flush by byte size": {
+			cfg:      Config{NumWorkers: 1, BatchSize: 1000, BatchBytes: 300, BatchLinger: time.Hour},
+			messages: newMessages(6, 2),
+			expected: []int{6, 6},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				stored []int
+			)
+			pool := New(context.Background(), tc.cfg, &sliceQueue{messages: tc.messages}, nil, zerolog.Nop())
+			pool.Start(func(ctx context.Context, batch domain.EventBatch) error {
+				mu.Lock()
+				defer mu.Unlock()
+				stored = append(stored, len(batch.Events))
+				return nil
+			})
+
+			require.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(stored) >= len(tc.expected)-1
+			}, time.Second, 10*time.Millisecond)
+
+			pool.GracefulStop()
+			require.Equal(t, tc.expected, stored)
+		})
+	}
+}
+
+func TestPool_BatchingLinger(t *testing.T) {
+	stored := make(chan domain.EventBatch, 1)
+	pool := New(context.Background(), Config{
+		NumWorkers:  1,
+		BatchSize:   1000,
+		BatchLinger: 50 * time.Millisecond,
+	}, &sliceQueue{messages: newMessages(3, 1)}, nil, zerolog.Nop())
+	defer pool.GracefulStop()
+
+	pool.Start(func(ctx context.Context, batch domain.EventBatch) error {
+		stored <- batch
+		return nil
+	})
+
+	select {
+	case batch := <-stored:
+		require.Len(t, batch.Events, 3)
+	case <-time.After(time.Second):
+		t.Fatal("incomplete batch was not flushed after linger")
+	}
+}
+
+type sliceDeadLetterQueue struct {
+	mu      sync.Mutex
+	letters []deadletter.Metadata
+	// failures is the number of publishes to fail before succeeding.
+	failures int
+	calls    int
+}
+
+func (q *sliceDeadLetterQueue) Publish(_ context.Context, _ domain.EventBatch, err error, meta deadletter.Metadata) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.calls++
+	if q.calls <= q.failures {
+		return errors.New("redpanda is down")
+	}
+	meta.Reason = err.Error()
+	q.letters = append(q.letters, meta)
+	return nil
+}
+
+func TestPool_DeadLetter(t *testing.T) {
+	messages := newMessages(2, 1)
+	for i := range messages {
+		messages[i].Record = consumer.Record{Record: &kgo.Record{Topic: "event", Partition: 1, Offset: int64(i)}}
+	}
+
+	dlq := &sliceDeadLetterQueue{}
+	pool := New(context.Background(), Config{
+		NumWorkers:    1,
+		BatchSize:     2,
+		StoreAttempts: 3,
+	}, &sliceQueue{messages: messages}, dlq, zerolog.Nop())
+
+	var attempts atomic.Int32
+	pool.Start(func(ctx context.Context, batch domain.EventBatch) error {
+		attempts.Add(1)
+		return errors.New("clickhouse is down")
+	})
+
+	require.Eventually(t, func() bool {
+		dlq.mu.Lock()
+		defer dlq.mu.Unlock()
+		return len(dlq.letters) == 2
+	}, time.Second, 10*time.Millisecond)
+	pool.GracefulStop()
+
+	require.EqualValues(t, 3, attempts.Load())
+	for i, meta := range dlq.letters {
+		require.Equal(t, "clickhouse is down", meta.Reason)
+		require.Equal(t, 3, meta.Attempts)
+		require.Equal(t, deadletter.Origin{Topic: "event", Partition: 1, Offset: int64(i)}, meta.Origin)
+	}
+}
+
+func TestPool_DeadLetterRetry(t *testing.T) {
+	cases := map[string]struct {
+		failures int
+		letters  int
+	}{
+		"published after retries": {failures: 2, letters: 1},
+		"given up":                {failures: 10, letters: 0},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			messages := newMessages(1, 1)
+			messages[0].Record = consumer.Record{Record: &kgo.Record{Topic: "event"}}
+
+			dlq := &sliceDeadLetterQueue{failures: tc.failures}
+			pool := New(context.Background(), Config{
+				NumWorkers:    1,
+				BatchSize:     1,
+				StoreAttempts: 3,
+			}, &sliceQueue{messages: messages}, dlq, zerolog.Nop())
+			pool.Start(func(ctx context.Context, batch domain.EventBatch) error {
+				return errors.New("clickhouse is down")
+			})
+
+			require.Eventually(t, func() bool {
+				dlq.mu.Lock()
+				defer dlq.mu.Unlock()
+				return dlq.calls == 3
+			}, time.Second, 10*time.Millisecond)
+			pool.GracefulStop()
+			require.Len(t, dlq.letters, tc.letters)
+		})
+	}
+}
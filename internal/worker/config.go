@@ -1,5 +1,26 @@
 package worker
 
+import "time"
+
+const (
+	defaultBatchSize   = 1000
+	defaultBatchLinger = time.Second
+
+	defaultStoreAttempts = 1
+)
+
 type Config struct {
 	NumWorkers int `mapstructure:"num_workers"`
+	// BatchSize is the number of events after which an accumulated batch is stored.
+	BatchSize int `mapstructure:"batch_size"`
+	// BatchBytes is the encoded size after which an accumulated batch is stored.
+	// Zero means no limit.
+	BatchBytes int `mapstructure:"batch_bytes"`
+	// BatchLinger is the longest time an incomplete batch waits for more events.
+	BatchLinger time.Duration `mapstructure:"batch_linger"`
+	// StoreAttempts is how many times a batch is stored before it goes to the
+	// dead-letter queue.
+	StoreAttempts int `mapstructure:"store_attempts"`
+	// StoreRetryDelay is the base delay between store attempts, growing linearly.
+	StoreRetryDelay time.Duration `mapstructure:"store_retry_delay"`
 }
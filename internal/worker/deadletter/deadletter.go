@@ -0,0 +1,152 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const (
+	HeaderReason    = "x-datalog-error-reason"
+	HeaderAttempts  = "x-datalog-attempts"
+	HeaderTopic     = "x-datalog-original-topic"
+	HeaderPartition = "x-datalog-original-partition"
+	HeaderOffset    = "x-datalog-original-offset"
+	HeaderTimestamp = "x-datalog-failed-at"
+)
+
+// Origin is the position of a failed batch in the event topic. Batches that failed
+// before they were published have no origin: Partition and Offset are -1.
+type Origin struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+var NoOrigin = Origin{Partition: -1, Offset: -1}
+
+// Metadata describes a failure; it travels in the record headers so the dead-letter
+// topic can be filtered without decoding the payload.
+type Metadata struct {
+	Reason   string
+	Attempts int
+	Origin   Origin
+	FailedAt time.Time
+}
+
+func (m Metadata) Headers() []kgo.RecordHeader {
+	return []kgo.RecordHeader{
+		{Key: HeaderReason, Value: []byte(m.Reason)},
+		{Key: HeaderAttempts, Value: []byte(strconv.Itoa(m.Attempts))},
+		{Key: HeaderTopic, Value: []byte(m.Origin.Topic)},
+		{Key: HeaderPartition, Value: []byte(strconv.FormatInt(int64(m.Origin.Partition), 10))},
+		{Key: HeaderOffset, Value: []byte(strconv.FormatInt(m.Origin.Offset, 10))},
+		{Key: HeaderTimestamp, Value: []byte(m.FailedAt.UTC().Format(time.RFC3339Nano))},
+	}
+}
+
+// MetadataFromHeaders is the inverse of Metadata.Headers. Missing headers are left at
+// their zero values.
+func MetadataFromHeaders(headers []kgo.RecordHeader) (Metadata, error) {
+	m := Metadata{Origin: NoOrigin}
+	for _, h := range headers {
+		var err error
+		value := string(h.Value)
+		switch h.Key {
+		case HeaderReason:
+			m.Reason = value
+		case HeaderAttempts:
+			m.Attempts, err = strconv.Atoi(value)
+		case HeaderTopic:
+			m.Origin.Topic = value
+		case HeaderPartition:
+			var p int64
+			p, err = strconv.ParseInt(value, 10, 32)
+			m.Origin.Partition = int32(p)
+		case HeaderOffset:
+			m.Origin.Offset, err = strconv.ParseInt(value, 10, 64)
+		case HeaderTimestamp:
+			m.FailedAt, err = time.Parse(time.RFC3339Nano, value)
+		}
+		if err != nil {
+			return Metadata{}, fmt.Errorf("header %s: %w", h.Key, err)
+		}
+	}
+	return m, nil
+}
+
+// Envelope is the value of a dead-letter record.
+type Envelope struct {
+	Payload domain.EventBatch `json:"payload"`
+	Error   *ErrorReason      `json:"error_reason"`
+}
+
+func (c *Envelope) SetErrorReason(err error) {
+	if c.Error == nil {
+		c.Error = new(ErrorReason)
+	}
+	c.Error.Reason = err
+}
+
+func (c *Envelope) GetErrorReason() error {
+	if c.Error != nil {
+		return c.Error.Reason
+	}
+	return nil
+}
+
+type ErrorReason struct {
+	Reason error
+}
+
+func (e ErrorReason) MarshalJSON() ([]byte, error) {
+	if e.Reason != nil {
+		return json.Marshal(e.Reason.Error())
+	}
+	return json.Marshal(nil)
+}
+
+func (e *ErrorReason) UnmarshalJSON(data []byte) error {
+	var reason string
+	if err := json.Unmarshal(data, &reason); err != nil {
+		return err
+	}
+	e.Reason = errors.New(reason)
+	return nil
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, key string, msg any, headers ...kgo.RecordHeader) error
+}
+
+// Queue publishes failed event batches to the dead-letter topic.
+type Queue struct {
+	publisher Publisher
+}
+
+func NewQueue(publisher Publisher) *Queue {
+	return &Queue{
+		publisher: publisher,
+	}
+}
+
+func (q *Queue) Publish(ctx context.Context, eventBatch domain.EventBatch, err error, meta Metadata) error {
+	e := Envelope{
+		Payload: eventBatch,
+	}
+	e.SetErrorReason(err)
+
+	meta.Reason = err.Error()
+	if meta.FailedAt.IsZero() {
+		meta.FailedAt = time.Now()
+	}
+
+	return q.publisher.Publish(ctx, eventBatch.ID, e, meta.Headers()...)
+}
@@ -0,0 +1,68 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+func TestMetadata_Headers(t *testing.T) {
+	meta := Metadata{
+		Reason:   "code: 60, message: Table test_db.events doesn't exist",
+		Attempts: 3,
+		Origin:   Origin{Topic: "event", Partition: 2, Offset: 42},
+		FailedAt: time.Date(2023, 5, 31, 10, 0, 0, 0, time.UTC),
+	}
+
+	decoded, err := MetadataFromHeaders(meta.Headers())
+	require.NoError(t, err)
+	require.Equal(t, meta, decoded)
+}
+
+func TestEnvelope_JSON(t *testing.T) {
+	e := Envelope{Payload: domain.EventBatch{ID: "batch"}}
+	e.SetErrorReason(errors.New("insert failed"))
+
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"payload":{"id":"batch","events":null},"error_reason":"insert failed"}`, string(b))
+
+	var decoded Envelope
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, "batch", decoded.Payload.ID)
+	require.EqualError(t, decoded.GetErrorReason(), "insert failed")
+}
+
+func TestFilter_Match(t *testing.T) {
+	failedAt := time.Date(2023, 5, 31, 10, 0, 0, 0, time.UTC)
+	letter := Letter{
+		Envelope: Envelope{Payload: domain.EventBatch{ID: "batch-1"}},
+		Metadata: Metadata{Reason: "dial tcp 10.0.0.1:9000: connection refused", FailedAt: failedAt},
+	}
+
+	cases := map[string]struct {
+		filter   Filter
+		expected bool
+	}{
+		"empty filter":       {filter: Filter{}, expected: true},
+		"in time range":      {filter: Filter{From: failedAt, To: failedAt.Add(time.Hour)}, expected: true},
+		"before time range":  {filter: Filter{From: failedAt.Add(time.Second)}, expected: false},
+		"to is exclusive":    {filter: Filter{To: failedAt}, expected: false},
+		"reason substring":   {filter: Filter{Reason: "connection refused"}, expected: true},
+		"other reason":       {filter: Filter{Reason: "UNKNOWN_TABLE"}, expected: false},
+		"batch id":           {filter: Filter{BatchIDs: []string{"batch-0", "batch-1"}}, expected: true},
+		"other batch id":     {filter: Filter{BatchIDs: []string{"batch-2"}}, expected: false},
+		"all criteria match": {filter: Filter{From: failedAt, Reason: "refused", BatchIDs: []string{"batch-1"}}, expected: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.filter.Match(letter))
+		})
+	}
+}
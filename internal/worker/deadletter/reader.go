@@ -0,0 +1,148 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
+)
+
+// errSkip leaves a letter unacknowledged and goes on reading.
+var errSkip = errors.New("letter skipped")
+
+// Letter is a dead-letter record read back from the topic.
+type Letter struct {
+	Envelope
+	Metadata
+
+	// Topic, Partition and Offset locate the letter in the dead-letter topic.
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// ReadFn handles a letter. err is set when the record could not be decoded; letter
+// then only carries its position. Returning an error stops reading.
+type ReadFn func(letter Letter, err error) error
+
+// Reader reads the dead-letter topic back for inspection and replay.
+type Reader struct {
+	consumer *consumer.Consumer
+}
+
+func NewReader(consumer *consumer.Consumer) *Reader {
+	return &Reader{
+		consumer: consumer,
+	}
+}
+
+// Read passes every letter to fn until ctx is cancelled, done is closed or fn
+// returns an error. A letter is acknowledged once fn returns nil for it.
+func (r *Reader) Read(ctx context.Context, done <-chan struct{}, fn ReadFn) error {
+	return r.read(ctx, done, r.consumer.ConsumeRecords, fn)
+}
+
+func (r *Reader) read(
+	ctx context.Context,
+	done <-chan struct{},
+	consume func(ctx context.Context, done <-chan struct{}, fn func(record consumer.Record) error),
+	fn ReadFn,
+) error {
+	var readErr error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	consume(ctx, done, func(record consumer.Record) error {
+		err := fn(decode(record))
+		if errors.Is(err, errSkip) {
+			return nil
+		}
+		if err != nil {
+			readErr = err
+			cancel()
+			return err
+		}
+
+		record.Ack()
+		return nil
+	})
+
+	return readErr
+}
+
+// ReadAll reads the letters written before the call and returns once the end of
+// every partition is reached, or once the consumer caught up, since the last
+// offsets may be control records that are never delivered. Partitions whose
+// committed offset is already at the end are not read.
+func (r *Reader) ReadAll(ctx context.Context, fn ReadFn) error {
+	end, err := r.consumer.EndOffsets(ctx)
+	if err != nil {
+		return err
+	}
+	committed, err := r.consumer.CommittedOffsets(ctx)
+	if err != nil {
+		return err
+	}
+
+	remaining := 0
+	for topic, partitions := range end {
+		for partition, last := range partitions {
+			if at, ok := committed[topic][partition]; ok && at >= last {
+				delete(partitions, partition)
+				continue
+			}
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		return nil
+	}
+
+	readCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	err = r.read(readCtx, nil, r.consumer.ConsumeAvailable, func(letter Letter, decodeErr error) error {
+		last, ok := end[letter.Topic][letter.Partition]
+		if !ok || letter.Offset >= last {
+			// Written after the call, left for the next read.
+			return errSkip
+		}
+
+		if err := fn(letter, decodeErr); err != nil {
+			return err
+		}
+
+		if letter.Offset == last-1 {
+			delete(end[letter.Topic], letter.Partition)
+			if remaining--; remaining == 0 {
+				stop()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func decode(record consumer.Record) (Letter, error) {
+	letter := Letter{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+	}
+
+	meta, err := MetadataFromHeaders(record.Headers)
+	if err != nil {
+		return letter, fmt.Errorf("decode headers at offset %d: %w", record.Offset, err)
+	}
+	letter.Metadata = meta
+
+	if err = json.Unmarshal(record.Value, &letter.Envelope); err != nil {
+		return letter, fmt.Errorf("decode envelope at offset %d: %w", record.Offset, err)
+	}
+	return letter, nil
+}
@@ -0,0 +1,123 @@
+package deadletter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+// Filter selects the letters to replay. Zero fields match everything.
+type Filter struct {
+	// From and To bound the failure time, To is exclusive.
+	From time.Time
+	To   time.Time
+	// Reason matches letters whose error reason contains it.
+	Reason string
+	// BatchIDs matches letters carrying one of the batches.
+	BatchIDs []string
+}
+
+func (f Filter) Match(letter Letter) bool {
+	if !f.From.IsZero() && letter.FailedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !letter.FailedAt.Before(f.To) {
+		return false
+	}
+	if f.Reason != "" && !strings.Contains(letter.reason(), f.Reason) {
+		return false
+	}
+	if len(f.BatchIDs) > 0 {
+		for _, id := range f.BatchIDs {
+			if id == letter.Payload.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// reason prefers the header, which is also set for envelopes without error_reason.
+func (l Letter) reason() string {
+	if l.Reason != "" {
+		return l.Reason
+	}
+	if err := l.GetErrorReason(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// ReplayFn puts a batch back into the pipeline, e.g. publishes it to the event topic
+// or stores it directly.
+type ReplayFn func(ctx context.Context, eventBatch domain.EventBatch) error
+
+// Failure is a letter that could not be decoded or replayed.
+type Failure struct {
+	Partition int32
+	Offset    int64
+	BatchID   string
+	Err       error
+}
+
+// Report summarises a replay run.
+type Report struct {
+	DryRun   bool
+	Read     int
+	Invalid  int
+	Matched  int
+	Replayed int
+	Events   int
+	// Batches lists the matched batch IDs in the order they were read.
+	Batches  []string
+	Failures []Failure
+}
+
+// Replay reads the dead-letter topic up to its current end and passes every letter
+// matching filter to replayFn. In dry-run mode replayFn is never called and the
+// report only tells what would be replayed.
+func Replay(ctx context.Context, reader *Reader, filter Filter, dryRun bool, replayFn ReplayFn) (Report, error) {
+	report := Report{DryRun: dryRun}
+
+	err := reader.ReadAll(ctx, func(letter Letter, err error) error {
+		report.Read++
+		if err != nil {
+			report.Invalid++
+			report.Failures = append(report.Failures, Failure{
+				Partition: letter.Partition,
+				Offset:    letter.Offset,
+				Err:       err,
+			})
+			return nil
+		}
+
+		if !filter.Match(letter) {
+			return nil
+		}
+		report.Matched++
+		report.Batches = append(report.Batches, letter.Payload.ID)
+
+		if dryRun {
+			report.Events += len(letter.Payload.Events)
+			return nil
+		}
+
+		if err = replayFn(ctx, letter.Payload); err != nil {
+			report.Failures = append(report.Failures, Failure{
+				Partition: letter.Partition,
+				Offset:    letter.Offset,
+				BatchID:   letter.Payload.ID,
+				Err:       err,
+			})
+			return ctx.Err()
+		}
+		report.Replayed++
+		report.Events += len(letter.Payload.Events)
+		return nil
+	})
+
+	return report, err
+}
@@ -3,14 +3,13 @@ package worker
 import (
 	"context"
 
-	"github.com/leshachaplin/datalog/internal/domain"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
 )
 
 type Queue interface {
 	Publish(ctx context.Context, key string, payload any) error
-	Consume(ctx context.Context, taskPayload chan<- domain.EventBatch, done <-chan struct{})
+	Consume(ctx context.Context, taskPayload chan<- consumer.Message, done <-chan struct{})
 }
 
 type RedpandaQueue struct {
@@ -32,6 +31,6 @@ func (r *RedpandaQueue) Publish(ctx context.Context, key string, payload any) er
 	return nil
 }
 
-func (r *RedpandaQueue) Consume(ctx context.Context, taskPayload chan<- domain.EventBatch, done <-chan struct{}) {
+func (r *RedpandaQueue) Consume(ctx context.Context, taskPayload chan<- consumer.Message, done <-chan struct{}) {
 	r.consumer.Consume(ctx, taskPayload, done)
 }
@@ -8,43 +8,103 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 
 	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
 )
 
+var errStopped = errors.New("consumer stopped")
+
 const (
 	defaultPollFetchesTimeout = 15 * time.Second
 	defaultRetryCount         = 10
+	commitTimeout             = 10 * time.Second
 )
 
 type Config struct {
-	Brokers            []string      `mapstructure:"brokers"`
+	Brokers []string `mapstructure:"brokers"`
+	// ConsumerGroup may be empty, then topics are read from the start and offsets
+	// are never committed.
 	ConsumerGroup      string        `mapstructure:"consumer_group"`
 	Topics             []string      `mapstructure:"topics"`
 	RetryCount         int           `mapstructure:"retry_count"`
 	PollFetchesTimeout time.Duration `mapstructure:"poll_fetches_timeout"`
 }
 
+// Record is a raw record read from a topic. Ack must be called once the record has
+// been handled; its offset is committed as soon as every earlier record of the same
+// partition has been acknowledged as well.
+type Record struct {
+	*kgo.Record
+
+	tracker *offsetTracker
+	tracked *trackedRecord
+}
+
+func (r Record) Ack() {
+	if r.tracker != nil {
+		r.tracker.ack(r.tracked)
+	}
+}
+
+// Message is an event batch decoded from a Record.
+type Message struct {
+	Record
+	Batch domain.EventBatch
+	// Size is the encoded size of the batch in bytes.
+	Size int
+}
+
 type Consumer struct {
 	client             *kgo.Client
+	group              string
+	topics             []string
+	offsets            *offsetTracker
 	retryCount         int
 	pollFetchesTimeout time.Duration
 	errChan            chan<- error
 }
 
 func NewConsumer(cfg Config, errChan chan<- error) (*Consumer, error) {
+	var offsets *offsetTracker
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(cfg.Brokers...),
-		kgo.ConsumerGroup(cfg.ConsumerGroup),
 		kgo.ConsumeTopics(cfg.Topics...),
-		kgo.DisableAutoCommit(),
+	}
+
+	if cfg.ConsumerGroup != "" {
+		offsets = newOffsetTracker(nil)
+		onRevoked := func(ctx context.Context, client *kgo.Client, revoked map[string][]int32) {
+			// The new owner starts from the last commit, so what was marked is
+			// committed before the partitions are handed over.
+			if err := client.CommitMarkedOffsets(ctx); err != nil {
+				log.Error().Err(err).Msg("Consume: commit marked offsets on revoke.")
+			}
+			offsets.revoke(revoked)
+		}
+		onLost := func(_ context.Context, _ *kgo.Client, lost map[string][]int32) {
+			offsets.revoke(lost)
+		}
+
+		opts = append(opts,
+			kgo.ConsumerGroup(cfg.ConsumerGroup),
+			kgo.AutoCommitMarks(),
+			kgo.OnPartitionsRevoked(onRevoked),
+			kgo.OnPartitionsLost(onLost),
+		)
+	} else {
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
 	}
 
 	client, err := kgo.NewClient(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("kgo new client: %w", err)
 	}
+	if offsets != nil {
+		offsets.mark = client.MarkCommitRecords
+	}
 
 	ctx, cansel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cansel()
@@ -54,6 +114,9 @@ func NewConsumer(cfg Config, errChan chan<- error) (*Consumer, error) {
 
 	consumer := &Consumer{
 		client:  client,
+		group:   cfg.ConsumerGroup,
+		topics:  cfg.Topics,
+		offsets: offsets,
 		errChan: errChan,
 	}
 
@@ -72,35 +135,158 @@ func NewConsumer(cfg Config, errChan chan<- error) (*Consumer, error) {
 	return consumer, nil
 }
 
+// Unacked returns the number of consumed records that were not acknowledged. They
+// are consumed again after a restart. It is always zero without a consumer group.
+func (c *Consumer) Unacked() int {
+	if c.offsets == nil {
+		return 0
+	}
+	return c.offsets.unacked()
+}
+
+// Close commits the offsets marked so far and leaves the group.
 func (c *Consumer) Close() error {
+	if c.offsets != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		defer cancel()
+		if err := c.client.CommitMarkedOffsets(ctx); err != nil {
+			log.Error().Err(err).Msg("Consume: commit marked offsets on close.")
+		}
+	}
 	c.client.Close()
 	return nil
 }
 
-func (c *Consumer) Consume(ctx context.Context, eventChan chan<- domain.EventBatch, done <-chan struct{}) {
-	c.consume(ctx, done, func(fetches kgo.Fetches) error {
-		for iter := fetches.RecordIter(); !iter.Done(); {
-			record := iter.Next()
+// Consume reads event batches until ctx is cancelled or done is closed. Offsets are
+// not committed here: the receiver acknowledges every message once it is stored, and
+// acknowledged offsets are committed in the background.
+func (c *Consumer) Consume(ctx context.Context, eventChan chan<- Message, done <-chan struct{}) {
+	c.ConsumeRecords(ctx, done, func(record Record) error {
+		msg := Message{
+			Record: record,
+			Size:   len(record.Value),
+		}
 
-			var event domain.EventBatch
-			if err := json.Unmarshal(record.Value, &event); err != nil {
-				log.Error().Str("record", string(record.Value)).Err(err).Msg("Consume: Unmarshal event value.")
+		if err := unmarshalBatch(record.Record, &msg.Batch); err != nil {
+			log.Error().Str("record", string(record.Value)).Err(err).Msg("Consume: Unmarshal event value.")
+			record.Ack()
+			return nil
+		}
 
-				if commitErr := c.client.CommitRecords(ctx, record); commitErr != nil {
-					return fmt.Errorf("commit record: %w", commitErr)
-				}
-				return err
+		select {
+		case eventChan <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return errStopped
+		}
+	})
+}
+
+// unmarshalBatch decodes a record written by producer.Publish in either encoding.
+func unmarshalBatch(record *kgo.Record, batch *domain.EventBatch) error {
+	for _, header := range record.Headers {
+		if header.Key == producer.HeaderContentType && string(header.Value) == domain.ContentTypeProtobuf {
+			return batch.UnmarshalProto(record.Value)
+		}
+	}
+	return json.Unmarshal(record.Value, batch)
+}
+
+// ConsumeRecords passes every record to fn until ctx is cancelled or done is closed.
+// The rest of the fetch is skipped once fn returns an error.
+func (c *Consumer) ConsumeRecords(ctx context.Context, done <-chan struct{}, fn func(record Record) error) {
+	c.consume(ctx, done, c.records(fn), false)
+}
+
+// ConsumeAvailable is ConsumeRecords that also returns once a poll finds no new
+// records, that is once the consumer caught up with the topics.
+func (c *Consumer) ConsumeAvailable(ctx context.Context, done <-chan struct{}, fn func(record Record) error) {
+	c.consume(ctx, done, c.records(fn), true)
+}
+
+func (c *Consumer) records(fn func(record Record) error) func(fetches kgo.Fetches) error {
+	return func(fetches kgo.Fetches) error {
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			record := iter.Next()
+			r := Record{Record: record}
+			if c.offsets != nil {
+				r.tracker = c.offsets
+				r.tracked = c.offsets.track(record)
 			}
-			eventChan <- event
-			if commitErr := c.client.CommitRecords(ctx, record); commitErr != nil {
-				return fmt.Errorf("commit record: %w", commitErr)
+			if err := fn(r); err != nil {
+				return err
 			}
 		}
 		return nil
+	}
+}
+
+// EndOffsets returns the end offset of every non-empty partition of the consumed
+// topics, which lets a reader stop once it has seen everything written so far.
+func (c *Consumer) EndOffsets(ctx context.Context) (map[string]map[int32]int64, error) {
+	adm := kadm.NewClient(c.client)
+
+	start, err := adm.ListStartOffsets(ctx, c.topics...)
+	if err != nil {
+		return nil, fmt.Errorf("list start offsets: %w", err)
+	}
+	if err = start.Error(); err != nil {
+		return nil, fmt.Errorf("list start offsets: %w", err)
+	}
+
+	end, err := adm.ListEndOffsets(ctx, c.topics...)
+	if err != nil {
+		return nil, fmt.Errorf("list end offsets: %w", err)
+	}
+	if err = end.Error(); err != nil {
+		return nil, fmt.Errorf("list end offsets: %w", err)
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	end.Each(func(o kadm.ListedOffset) {
+		if s, ok := start.Lookup(o.Topic, o.Partition); ok && s.Offset >= o.Offset {
+			return
+		}
+		if offsets[o.Topic] == nil {
+			offsets[o.Topic] = make(map[int32]int64)
+		}
+		offsets[o.Topic][o.Partition] = o.Offset
+	})
+	return offsets, nil
+}
+
+// CommittedOffsets returns the offsets committed by the consumer group for the
+// consumed topics, that is where it resumes. It is empty without a consumer group.
+func (c *Consumer) CommittedOffsets(ctx context.Context) (map[string]map[int32]int64, error) {
+	offsets := make(map[string]map[int32]int64)
+	if c.group == "" {
+		return offsets, nil
+	}
+
+	committed, err := kadm.NewClient(c.client).FetchOffsetsForTopics(ctx, c.group, c.topics...)
+	if err != nil {
+		return nil, fmt.Errorf("fetch committed offsets: %w", err)
+	}
+	if err = committed.Error(); err != nil {
+		return nil, fmt.Errorf("fetch committed offsets: %w", err)
+	}
+	committed.Each(func(o kadm.OffsetResponse) {
+		if o.At < 0 {
+			return
+		}
+		if offsets[o.Topic] == nil {
+			offsets[o.Topic] = make(map[int32]int64)
+		}
+		offsets[o.Topic][o.Partition] = o.At
 	})
+	return offsets, nil
 }
 
-func (c *Consumer) consume(ctx context.Context, done <-chan struct{}, fn func(fetches kgo.Fetches) error) {
+// consume polls until ctx is cancelled or done is closed, or until a poll times out
+// without records when untilIdle is set.
+func (c *Consumer) consume(ctx context.Context, done <-chan struct{}, fn func(fetches kgo.Fetches) error, untilIdle bool) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -123,6 +309,9 @@ func (c *Consumer) consume(ctx context.Context, done <-chan struct{}, fn func(fe
 				}
 
 				if errors.Is(err, context.DeadlineExceeded) {
+					if untilIdle {
+						return
+					}
 					continue
 				}
 
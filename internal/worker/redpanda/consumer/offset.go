@@ -0,0 +1,98 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// offsetTracker marks a record for commit only after it and every earlier record of
+// the same partition have been acknowledged, so batches finishing out of order never
+// move the committed offset past a record that has not been stored yet. Marking is
+// in memory: the client commits marked offsets in the background.
+type offsetTracker struct {
+	mu      sync.Mutex
+	mark    func(records ...*kgo.Record)
+	pending map[topicPartition][]*trackedRecord
+}
+
+type trackedRecord struct {
+	record *kgo.Record
+	acked  bool
+}
+
+func newOffsetTracker(mark func(records ...*kgo.Record)) *offsetTracker {
+	return &offsetTracker{
+		mark:    mark,
+		pending: make(map[topicPartition][]*trackedRecord),
+	}
+}
+
+func (t *offsetTracker) track(record *kgo.Record) *trackedRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tp := topicPartition{topic: record.Topic, partition: record.Partition}
+	tr := &trackedRecord{record: record}
+	t.pending[tp] = append(t.pending[tp], tr)
+	return tr
+}
+
+func (t *offsetTracker) ack(tr *trackedRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr.acked = true
+
+	tp := topicPartition{topic: tr.record.Topic, partition: tr.record.Partition}
+	pending := t.pending[tp]
+
+	var committable *kgo.Record
+	n := 0
+	for ; n < len(pending) && pending[n].acked; n++ {
+		committable = pending[n].record
+	}
+	if committable == nil {
+		return
+	}
+
+	if n == len(pending) {
+		delete(t.pending, tp)
+	} else {
+		t.pending[tp] = pending[n:]
+	}
+	t.mark(committable)
+}
+
+func (t *offsetTracker) unacked() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, pending := range t.pending {
+		for _, tr := range pending {
+			if !tr.acked {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// revoke forgets pending records of partitions that are no longer assigned to this
+// consumer; their offsets will be committed by the new owner.
+func (t *offsetTracker) revoke(revoked map[string][]int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for topic, partitions := range revoked {
+		for _, partition := range partitions {
+			delete(t.pending, topicPartition{topic: topic, partition: partition})
+		}
+	}
+}
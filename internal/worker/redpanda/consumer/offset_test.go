@@ -0,0 +1,34 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	var marked []int64
+	tracker := newOffsetTracker(func(records ...*kgo.Record) {
+		for _, record := range records {
+			marked = append(marked, record.Offset)
+		}
+	})
+
+	tracked := make([]*trackedRecord, 4)
+	for i := range tracked {
+		tracked[i] = tracker.track(&kgo.Record{Topic: "event", Partition: 0, Offset: int64(i)})
+	}
+	other := tracker.track(&kgo.Record{Topic: "event", Partition: 1, Offset: 7})
+
+	tracker.ack(tracked[1])
+	require.Empty(t, marked, "offset 0 is not acknowledged yet")
+	require.Equal(t, 4, tracker.unacked())
+
+	tracker.ack(tracked[0])
+	tracker.ack(other)
+	require.Equal(t, []int64{1, 7}, marked)
+
+	tracker.revoke(map[string][]int32{"event": {0}})
+	require.Equal(t, 0, tracker.unacked())
+}
@@ -9,19 +9,39 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/leshachaplin/datalog/internal/domain"
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+
+	// HeaderContentType is set on records that are not JSON, so consumers can read
+	// topics written with either encoding.
+	HeaderContentType = "content-type"
 )
 
+// ProtoMarshaler is implemented by messages with a protobuf encoding.
+type ProtoMarshaler interface {
+	MarshalProto() ([]byte, error)
+}
+
 type Config struct {
 	RetryAttempts int           `mapstructure:"retry_attempts"`
 	RetryDelay    time.Duration `mapstructure:"retry_delay"`
 	SleepDuration time.Duration `mapstructure:"sleep_duration"`
 	Brokers       []string      `mapstructure:"brokers"`
 	Topic         string        `mapstructure:"topic"`
+	// Encoding is json or protobuf; json by default. Messages without a protobuf
+	// encoding are always published as JSON.
+	Encoding string `mapstructure:"encoding"`
 }
 
 type Producer struct {
 	retryAttempts int
 	retryDelay    time.Duration
+	encoding      string
 	sleepDuration time.Duration
 	client        *kgo.Client
 	logger        zerolog.Logger
@@ -50,28 +70,38 @@ func NewProducer(
 		client:        client,
 		retryAttempts: cfg.RetryAttempts,
 		retryDelay:    cfg.RetryDelay,
+		encoding:      cfg.Encoding,
 		logger:        logger,
 	}
 
 	return producer, nil
 }
 
+// Flush waits until every buffered record is produced or ctx is done.
+func (p *Producer) Flush(ctx context.Context) error {
+	return p.client.Flush(ctx)
+}
+
 func (p *Producer) Close() error {
 	p.client.Close()
 	return nil
 }
 
-func (p *Producer) Publish(ctx context.Context, key string, msg any) error {
+func (p *Producer) Publish(ctx context.Context, key string, msg any, headers ...kgo.RecordHeader) error {
 	const publishTimeout = 5 * time.Second
 
-	b, err := json.Marshal(msg)
+	b, contentType, err := p.marshal(msg)
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
 
-	record := kgo.KeyStringRecord(key, string(b))
+	record := kgo.KeySliceRecord([]byte(key), b)
+	record.Headers = headers
+	if contentType != "" {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: HeaderContentType, Value: []byte(contentType)})
+	}
 
-	return linearBackOff(&p.logger, p.retryAttempts, p.retryDelay, func() error {
+	return linearBackOff(ctx, &p.logger, p.retryAttempts, p.retryDelay, func() error {
 		produceCtx, cancel := context.WithTimeout(ctx, publishTimeout)
 		res := p.client.ProduceSync(produceCtx, record)
 		cancel()
@@ -83,7 +113,18 @@ func (p *Producer) Publish(ctx context.Context, key string, msg any) error {
 	})
 }
 
-func linearBackOff(log *zerolog.Logger, attempts int, delay time.Duration, fn func() error) error {
+// marshal encodes msg and returns its content type, empty for JSON.
+func (p *Producer) marshal(msg any) ([]byte, string, error) {
+	if m, ok := msg.(ProtoMarshaler); ok && p.encoding == EncodingProtobuf {
+		b, err := m.MarshalProto()
+		return b, domain.ContentTypeProtobuf, err
+	}
+
+	b, err := json.Marshal(msg)
+	return b, "", err
+}
+
+func linearBackOff(ctx context.Context, log *zerolog.Logger, attempts int, delay time.Duration, fn func() error) error {
 	var err error
 	for i := 0; i < attempts; i++ {
 		if err = fn(); err != nil {
@@ -96,7 +137,11 @@ func linearBackOff(log *zerolog.Logger, attempts int, delay time.Duration, fn fu
 
 		log.Warn().Err(err).Msgf("Retry: %d.", i)
 
-		time.Sleep(delay * time.Duration(i+1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay * time.Duration(i+1)):
+		}
 	}
 	return err
 }
@@ -17,11 +17,12 @@ import (
 )
 
 const (
-	topic = "topic"
+	topic           = "topic"
+	deadLetterTopic = "dead-letter"
 )
 
 var (
-	defaultTopics = []string{topic}
+	defaultTopics = []string{topic, deadLetterTopic}
 )
 
 type IntegrationTestSuite struct {
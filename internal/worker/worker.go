@@ -2,46 +2,97 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/worker/deadletter"
+	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
 )
 
+var errNoDeadLetterQueue = errors.New("no dead-letter queue")
+
 type WorkerPool interface {
 	Start(executeFn func(ctx context.Context, batch domain.EventBatch) error)
 	GracefulStop()
 	Process(payload domain.EventBatch)
-	onFailure(payload domain.EventBatch, err error)
+	Publish(ctx context.Context, payload domain.EventBatch) error
+	Quarantine(ctx context.Context, payload domain.EventBatch, reason error) error
+	onFailure(payload domain.EventBatch, err error, meta deadletter.Metadata) error
+}
+
+// DeadLetterQueue receives batches that could not be published or stored.
+type DeadLetterQueue interface {
+	Publish(ctx context.Context, eventBatch domain.EventBatch, err error, meta deadletter.Metadata) error
 }
 
 type Pool struct {
 	numWorkers  int
-	taskPayload chan domain.EventBatch
+	batchSize   int
+	batchBytes  int
+	batchLinger time.Duration
+	attempts    int
+	retryDelay  time.Duration
+	taskPayload chan consumer.Message
+	batches     chan *batch
 	queue       Queue
-	errorQueue  Queue
+	errorQueue  DeadLetterQueue
 	start       sync.Once
 	stop        sync.Once
 	doneChan    chan struct{}
 	ctx         context.Context //TODO: maybe make some wrapper func for getting context
 	cancelFn    context.CancelFunc
+	storeCtx    context.Context
+	storeCancel context.CancelFunc
 	wg          *sync.WaitGroup
 	logger      zerolog.Logger
 }
 
-func New(ctx context.Context, cfg Config, queue Queue, logger zerolog.Logger) *Pool {
+func New(
+	ctx context.Context,
+	cfg Config,
+	queue Queue,
+	errorQueue DeadLetterQueue,
+	logger zerolog.Logger,
+) *Pool {
 	c, cancelFn := context.WithCancel(ctx)
+	// Stored batches outlive the parent context, so that batches accumulated before
+	// shutdown are still written during GracefulStop.
+	storeCtx, storeCancel := context.WithCancel(context.Background())
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	batchLinger := cfg.BatchLinger
+	if batchLinger <= 0 {
+		batchLinger = defaultBatchLinger
+	}
+	attempts := cfg.StoreAttempts
+	if attempts <= 0 {
+		attempts = defaultStoreAttempts
+	}
+
 	return &Pool{
 		numWorkers:  cfg.NumWorkers,
-		taskPayload: make(chan domain.EventBatch, cfg.NumWorkers),
+		batchSize:   batchSize,
+		batchBytes:  cfg.BatchBytes,
+		batchLinger: batchLinger,
+		attempts:    attempts,
+		retryDelay:  cfg.StoreRetryDelay,
+		taskPayload: make(chan consumer.Message, cfg.NumWorkers),
+		batches:     make(chan *batch),
 		doneChan:    make(chan struct{}),
 		queue:       queue,
+		errorQueue:  errorQueue,
 		ctx:         c,
 		cancelFn:    cancelFn,
+		storeCtx:    storeCtx,
+		storeCancel: storeCancel,
 		wg:          &sync.WaitGroup{},
 		logger:      logger,
 	}
@@ -54,95 +105,193 @@ func (w *Pool) Start(
 		for i := 0; i < w.numWorkers; i++ {
 			w.wg.Add(1)
 			l := w.logger.With().Interface("worker", i).Logger()
-			go w.work(w.ctx, l, executeFn)
+			go w.work(l, executeFn)
 		}
 
-		go w.queue.Consume(w.ctx, w.taskPayload, w.doneChan)
+		w.wg.Add(1)
+		go w.accumulate()
+
+		go func() {
+			defer close(w.taskPayload)
+			w.queue.Consume(w.ctx, w.taskPayload, w.doneChan)
+		}()
 	})
 }
 
+// GracefulStop stops consuming, stores the batch accumulated so far and waits for
+// the workers to finish.
 func (w *Pool) GracefulStop() {
 	w.stop.Do(func() {
 		close(w.doneChan)
 		w.cancelFn()
 		w.wg.Wait()
+		w.storeCancel()
 	})
 }
 
+// Process queues the batch and dead-letters it on failure. It uses the store context,
+// so that batches still being published when the app shuts down are not failed by the
+// cancelled parent context.
 func (w *Pool) Process(eventBatch domain.EventBatch) {
-	if err := w.queue.Publish(w.ctx, eventBatch.ID, eventBatch); err != nil {
-		w.onFailure(eventBatch, err)
+	if err := w.queue.Publish(w.storeCtx, eventBatch.ID, eventBatch); err != nil {
+		_ = w.onFailure(eventBatch, err, deadletter.Metadata{
+			Attempts: 1,
+			Origin:   deadletter.NoOrigin,
+		})
+	}
+}
+
+// Publish queues the batch and returns once the queue acknowledged it. Unlike
+// Process, a failed batch is returned to the caller instead of being dead-lettered.
+func (w *Pool) Publish(ctx context.Context, eventBatch domain.EventBatch) error {
+	return w.queue.Publish(ctx, eventBatch.ID, eventBatch)
+}
+
+// Quarantine parks the batch in the dead-letter queue without trying to store it,
+// so that it can be replayed once the reason is resolved.
+func (w *Pool) Quarantine(ctx context.Context, eventBatch domain.EventBatch, reason error) error {
+	if w.errorQueue == nil {
+		return errNoDeadLetterQueue
 	}
+	return w.errorQueue.Publish(ctx, eventBatch, reason, deadletter.Metadata{
+		Origin: deadletter.NoOrigin,
+	})
 }
 
-func (w *Pool) onFailure(eventBatch domain.EventBatch, err error) {
-	p := payload{
-		Payload: eventBatch,
+// onFailure sends the batch to the dead-letter queue. It uses the store context, so
+// that batches failing during GracefulStop are not lost to a cancelled context.
+func (w *Pool) onFailure(eventBatch domain.EventBatch, err error, meta deadletter.Metadata) error {
+	if w.errorQueue == nil {
+		log.Err(err).Interface("EventBatch", eventBatch).Msg("failed to process events, no dead-letter queue configured")
+		return errNoDeadLetterQueue
 	}
-	p.SetErrorReason(err)
-	if errPublish := w.errorQueue.Publish(w.ctx, eventBatch.ID, p); errPublish != nil {
-		log.Err(err).Interface("EventBatch", eventBatch).Msg("failed to process events")
+
+	if errPublish := w.errorQueue.Publish(w.storeCtx, eventBatch, err, meta); errPublish != nil {
+		log.Err(err).AnErr("publish_error", errPublish).Interface("EventBatch", eventBatch).Msg("failed to process events")
+		return errPublish
 	}
+	return nil
 }
 
-// TODO: сделать так чтобы батчи собирались в фиксированный размер из конфига - минимум 1000
-func (w *Pool) work(
-	ctx context.Context,
-	logger zerolog.Logger,
-	executeFn func(ctx context.Context, eventBatch domain.EventBatch) error,
-) {
+// accumulate merges consumed messages until the batch is full or has waited for
+// batchLinger, then hands it over to the workers. When the consumer stops, the
+// incomplete batch is flushed as well.
+func (w *Pool) accumulate() {
 	defer w.wg.Done()
+	defer close(w.batches)
+
+	var (
+		pending = &batch{}
+		linger  *time.Timer
+		lingerC <-chan time.Time
+	)
+
+	flush := func() {
+		if linger != nil {
+			linger.Stop()
+			linger, lingerC = nil, nil
+		}
+		if pending.empty() {
+			return
+		}
+		w.batches <- pending
+		pending = &batch{}
+	}
+
 	for {
 		select {
-		case <-ctx.Done():
-			return
-		case <-w.doneChan:
-			return
-		case pld, ok := <-w.taskPayload:
+		case msg, ok := <-w.taskPayload:
 			if !ok {
+				flush()
 				return
 			}
 
-			logger.Debug().Str("BATCH_ID", pld.ID).Interface("EVENTS", pld.Events).Msg("start processing events")
-			if err := executeFn(ctx, pld); err != nil {
-				w.onFailure(pld, err)
+			pending.add(msg)
+			if pending.full(w.batchSize, w.batchBytes) {
+				flush()
+			} else if linger == nil {
+				linger = time.NewTimer(w.batchLinger)
+				lingerC = linger.C
 			}
-			logger.Debug().Str("BATCH_ID", pld.ID).Msg("end processing events")
+		case <-lingerC:
+			linger, lingerC = nil, nil
+			flush()
 		}
 	}
 }
 
-type payload struct {
-	Payload domain.EventBatch `json:"payload"`
-	Error   *errorReason      `json:"error_reason"`
-}
+// work stores accumulated batches. A message is acknowledged, and so its offset
+// becomes committable, once its events are stored or dead-lettered. A message that
+// could not be dead-lettered either is logged and acknowledged as well: leaving it
+// unacknowledged would block the commits of its partition until a restart.
+func (w *Pool) work(
+	logger zerolog.Logger,
+	executeFn func(ctx context.Context, eventBatch domain.EventBatch) error,
+) {
+	defer w.wg.Done()
+	for b := range w.batches {
+		eventBatch := b.eventBatch()
 
-func (c *payload) SetErrorReason(err error) {
-	if c.Error == nil {
-		c.Error = new(errorReason)
+		logger.Debug().Str("BATCH_ID", eventBatch.ID).Int("EVENTS", len(eventBatch.Events)).Msg("start processing events")
+		attempts, err := w.store(logger, eventBatch, executeFn)
+		for _, msg := range b.messages {
+			if err != nil {
+				w.deadLetter(logger, msg, err, attempts)
+			}
+			msg.Ack()
+		}
+		logger.Debug().Str("BATCH_ID", eventBatch.ID).Msg("end processing events")
 	}
-	c.Error.Reason = err
 }
 
-func (c *payload) GetErrorReason() error {
-	if c.Error != nil {
-		return c.Error.Reason
+// deadLetter sends a message that could not be stored to the dead-letter queue,
+// retrying the publish as many times as the store.
+func (w *Pool) deadLetter(logger zerolog.Logger, msg consumer.Message, err error, attempts int) {
+	meta := deadletter.Metadata{
+		Attempts: attempts,
+		Origin: deadletter.Origin{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		},
 	}
-	return nil
-}
-
-type errorReason struct {
-	Reason error
-}
+	for i := 1; i <= w.attempts; i++ {
+		errFailure := w.onFailure(msg.Batch, err, meta)
+		if errFailure == nil || errors.Is(errFailure, errNoDeadLetterQueue) || i == w.attempts {
+			return
+		}
 
-func (e errorReason) MarshalJSON() ([]byte, error) {
-	if e.Reason != nil {
-		return json.Marshal(e.Reason.Error())
+		logger.Warn().Err(errFailure).Str("BATCH_ID", msg.Batch.ID).Msgf("Dead-letter retry: %d.", i)
+		select {
+		case <-w.storeCtx.Done():
+			return
+		case <-time.After(w.retryDelay * time.Duration(i)):
+		}
 	}
-	return json.Marshal(nil)
 }
 
-func (e *errorReason) UnmarshalJSON(data []byte) error {
-	e.Reason = errors.New(string(data))
-	return nil
+// store calls executeFn until it succeeds or the attempts are exhausted and returns
+// the number of attempts made.
+func (w *Pool) store(
+	logger zerolog.Logger,
+	eventBatch domain.EventBatch,
+	executeFn func(ctx context.Context, eventBatch domain.EventBatch) error,
+) (int, error) {
+	var err error
+	for i := 1; i <= w.attempts; i++ {
+		if err = executeFn(w.storeCtx, eventBatch); err == nil {
+			return i, nil
+		}
+		if i == w.attempts {
+			return i, err
+		}
+
+		logger.Warn().Err(err).Str("BATCH_ID", eventBatch.ID).Msgf("Retry: %d.", i)
+		select {
+		case <-w.storeCtx.Done():
+			return i, err
+		case <-time.After(w.retryDelay * time.Duration(i)):
+		}
+	}
+	return w.attempts, err
 }
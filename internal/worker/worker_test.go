@@ -2,16 +2,19 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 
 	"github.com/leshachaplin/datalog/internal/domain"
+	"github.com/leshachaplin/datalog/internal/worker/deadletter"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/consumer"
 	"github.com/leshachaplin/datalog/internal/worker/redpanda/producer"
 )
@@ -60,7 +63,7 @@ func (i *IntegrationTestSuite) TestWorker_RedpandaQueue() {
 			}
 
 			l := log.With().Str("WORKER", "PROCESS").Logger()
-			worker := New(ctx, tc.cfg, NewRedpandaQueue(producer, consumer), l)
+			worker := New(ctx, tc.cfg, NewRedpandaQueue(producer, consumer), nil, l)
 			worker.Start(execFn)
 
 			wg := &sync.WaitGroup{}
@@ -73,7 +76,7 @@ func (i *IntegrationTestSuite) TestWorker_RedpandaQueue() {
 						return
 					case payload, ok := <-payloadChan:
 						require.True(t, ok)
-						require.Equal(t, "test_id", payload.ID)
+						require.NotEmpty(t, payload.Events)
 					}
 				}
 			}(i.T())
@@ -100,3 +103,42 @@ func (i *IntegrationTestSuite) TestWorker_RedpandaQueue() {
 		})
 	}
 }
+
+func (i *IntegrationTestSuite) TestDeadLetter_ReadAll() {
+	producerCfg := i.producerCfg
+	producerCfg.Topic = deadLetterTopic
+	producer, err := producer.NewProducer(i.ctx, producerCfg, zerolog.Nop())
+	i.Require().NoError(err)
+	defer producer.Close()
+
+	queue := deadletter.NewQueue(producer)
+	for k := 0; k < 3; k++ {
+		batch := domain.EventBatch{ID: uuid.NewString()}
+		i.Require().NoError(queue.Publish(i.ctx, batch, errors.New("clickhouse is down"), deadletter.Metadata{}))
+	}
+
+	readAll := func() int {
+		consumer, err := consumer.NewConsumer(consumer.Config{
+			Brokers:            []string{i.broker},
+			ConsumerGroup:      "dead-letter-cg",
+			Topics:             []string{deadLetterTopic},
+			PollFetchesTimeout: time.Second,
+		}, make(chan error, 10))
+		i.Require().NoError(err)
+		defer consumer.Close()
+
+		read := 0
+		ctx, cancel := context.WithTimeout(i.ctx, 30*time.Second)
+		defer cancel()
+		i.Require().NoError(deadletter.NewReader(consumer).ReadAll(ctx, func(letter deadletter.Letter, err error) error {
+			read++
+			return err
+		}))
+		return read
+	}
+
+	i.Equal(3, readAll())
+	// The offsets committed by the first run are at the end, so the second returns
+	// at once instead of waiting for a letter it never sees.
+	i.Equal(0, readAll())
+}